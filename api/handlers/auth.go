@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timam/uttarawave-backend/api/response"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type AuthHandler struct {
+	employeeRepo repositories.EmployeeRepository
+}
+
+func NewAuthHandler(er repositories.EmployeeRepository) *AuthHandler {
+	return &AuthHandler{
+		employeeRepo: er,
+	}
+}
+
+func (h *AuthHandler) Login() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Mobile   string `json:"mobile"`
+			Password string `json:"password"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+		if request.Mobile == "" || request.Password == "" {
+			response.Error(c, http.StatusBadRequest, "Missing required fields", "mobile and password are required")
+			return
+		}
+
+		employee, err := h.employeeRepo.GetEmployeeByMobile(c.Request.Context(), request.Mobile)
+		if err != nil {
+			logger.Error("Failed to get employee", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to log in", err.Error())
+			return
+		}
+		if employee == nil || !auth.CheckPassword(employee.PasswordHash, request.Password) {
+			logger.Warn("Failed login attempt", zap.String("mobile", request.Mobile))
+			response.Error(c, http.StatusUnauthorized, "Invalid credentials", "mobile or password is incorrect")
+			return
+		}
+		if !employee.IsActive {
+			response.Error(c, http.StatusForbidden, "Account is inactive", "this employee account has been deactivated")
+			return
+		}
+
+		token, expiresAt, err := auth.GenerateToken(employee.ID, employee.Name, string(employee.Role))
+		if err != nil {
+			logger.Error("Failed to generate token", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to log in", err.Error())
+			return
+		}
+
+		if err := h.employeeRepo.UpdateLastLogin(c.Request.Context(), employee.ID, time.Now()); err != nil {
+			logger.Warn("Failed to record last login", zap.Error(err), zap.String("id", employee.ID))
+		}
+
+		logger.Info("Employee logged in", zap.String("id", employee.ID), zap.String("role", string(employee.Role)))
+		response.Success(c, http.StatusOK, "Logged in successfully", gin.H{
+			"token":     token,
+			"tokenType": "Bearer",
+			"expiresAt": expiresAt,
+			"employee":  employee,
+		})
+	}
+}
+
+func (h *AuthHandler) Me() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := auth.ClaimsFromContext(c.Request.Context())
+		if !ok {
+			response.Error(c, http.StatusUnauthorized, "Unauthorized", "missing credentials")
+			return
+		}
+
+		employee, err := h.employeeRepo.GetEmployeeByID(c.Request.Context(), claims.EmployeeID)
+		if err != nil {
+			logger.Error("Failed to get employee", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to get employee", err.Error())
+			return
+		}
+		if employee == nil {
+			response.Error(c, http.StatusNotFound, "Employee not found", "no employee found for this token")
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Employee retrieved successfully", employee)
+	}
+}
@@ -7,23 +7,29 @@ import (
 	"github.com/timam/uttarawave-backend/api/response"
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
 	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
 	"go.uber.org/zap"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type CustomerHandler struct {
 	repo         repositories.CustomerRepository
 	buildingRepo repositories.BuildingRepository
+	ledger       *services.LedgerService
 }
 
 func NewCustomerHandler(
 	cr repositories.CustomerRepository,
-	br repositories.BuildingRepository) *CustomerHandler {
+	br repositories.BuildingRepository,
+	ls *services.LedgerService) *CustomerHandler {
 	return &CustomerHandler{
 		repo:         cr,
 		buildingRepo: br,
+		ledger:       ls,
 	}
 }
 
@@ -259,3 +265,57 @@ func (h *CustomerHandler) DeleteCustomer() gin.HandlerFunc {
 		response.Success(c, http.StatusOK, "Customer deleted successfully", nil)
 	}
 }
+
+// GetStatement returns the customer's account statement for an inclusive from/to date range
+// (YYYY-MM-DD), defaulting to the current month up to today
+func (h *CustomerHandler) GetStatement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		customer, err := h.repo.GetCustomer(id)
+		if err != nil {
+			logger.Error("Failed to find customer", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to find customer", err.Error())
+			return
+		}
+		if customer == nil {
+			response.Error(c, http.StatusNotFound, "Customer not found", "customer not found")
+			return
+		}
+
+		now := time.Now()
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		if value := c.Query("from"); value != "" {
+			if from, err = time.ParseInLocation(invoiceDateLayout, value, time.Local); err != nil {
+				response.Error(c, http.StatusBadRequest, "Invalid from", "expected YYYY-MM-DD")
+				return
+			}
+		}
+		if value := c.Query("to"); value != "" {
+			if to, err = time.ParseInLocation(invoiceDateLayout, value, time.Local); err != nil {
+				response.Error(c, http.StatusBadRequest, "Invalid to", "expected YYYY-MM-DD")
+				return
+			}
+		}
+		if to.Before(from) {
+			response.Error(c, http.StatusBadRequest, "Invalid date range", "to must not be before from")
+			return
+		}
+
+		currency := money.Currency(c.DefaultQuery("currency", string(money.DefaultCurrency)))
+		if !currency.IsValid() {
+			response.Error(c, http.StatusBadRequest, "Invalid currency", "unsupported currency")
+			return
+		}
+
+		statement, err := h.ledger.GetStatement(c.Request.Context(), customer.ID, currency, from, to.AddDate(0, 0, 1))
+		if err != nil {
+			logger.Error("Failed to get customer statement", zap.Error(err), zap.String("customerID", customer.ID))
+			response.Error(c, http.StatusInternalServerError, "Failed to get customer statement", err.Error())
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Statement retrieved successfully", statement)
+	}
+}
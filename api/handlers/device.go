@@ -1,25 +1,32 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/timam/uttarawave-backend/api/response"
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
 	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type DeviceHandler struct {
-	repo repositories.DeviceRepository
+	repo          repositories.DeviceRepository
+	deviceService *services.DeviceService
 }
 
-func NewDeviceHandler(repo repositories.DeviceRepository) *DeviceHandler {
+func NewDeviceHandler(repo repositories.DeviceRepository, deviceService *services.DeviceService) *DeviceHandler {
 	return &DeviceHandler{
-		repo: repo,
+		repo:          repo,
+		deviceService: deviceService,
 	}
 }
 func (h *DeviceHandler) CreateDevice() gin.HandlerFunc {
@@ -46,6 +53,10 @@ func (h *DeviceHandler) CreateDevice() gin.HandlerFunc {
 		if device.Status == "" {
 			device.Status = models.InStock
 		}
+		if !device.Status.IsValid() || device.Status.IsAssigned() || device.Status == models.WrittenOff {
+			c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Invalid input", "New devices start in stock, damaged or under repair; assign them afterwards"))
+			return
+		}
 
 		if device.Usage == "" {
 			device.Usage = models.CompanyUse
@@ -93,26 +104,9 @@ func (h *DeviceHandler) UpdateDevice() gin.HandlerFunc {
 			return
 		}
 
-		existingDevice, err := h.repo.GetDeviceByID(c.Request.Context(), id)
-		if err != nil {
-			logger.Error("Failed to get existing device", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, response.NewDeviceResponse(http.StatusInternalServerError, "Failed to get existing device", nil))
-			return
-		}
-
-		existingDevice.Brand = updatedDevice.Brand
-		existingDevice.Model = updatedDevice.Model
-		existingDevice.SerialNumber = updatedDevice.SerialNumber
-		existingDevice.Type = updatedDevice.Type
-		existingDevice.Status = updatedDevice.Status
-		existingDevice.Usage = updatedDevice.Usage
-		existingDevice.PurchasePrice = updatedDevice.PurchasePrice
-		existingDevice.PurchaseDate = updatedDevice.PurchaseDate
-
-		err = h.repo.UpdateDevice(c.Request.Context(), existingDevice)
+		existingDevice, err := h.deviceService.UpdateDevice(c.Request.Context(), id, updatedDevice)
 		if err != nil {
-			logger.Error("Failed to update device", zap.Error(err))
-			response.Error(c, http.StatusInternalServerError, "Failed to update device", err.Error())
+			respondDeviceError(c, "Failed to update device", err)
 			return
 		}
 
@@ -123,11 +117,8 @@ func (h *DeviceHandler) UpdateDevice() gin.HandlerFunc {
 
 func (h *DeviceHandler) DeleteDevice() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		err := h.repo.DeleteDevice(c.Request.Context(), id)
-		if err != nil {
-			logger.Error("Failed to delete device", zap.Error(err))
-			response.Error(c, http.StatusInternalServerError, "Failed to delete device", err.Error())
+		if err := h.deviceService.DeleteDevice(c.Request.Context(), c.Param("id")); err != nil {
+			respondDeviceError(c, "Failed to delete device", err)
 			return
 		}
 
@@ -169,10 +160,9 @@ func (h *DeviceHandler) AssignDevice() gin.HandlerFunc {
 			return
 		}
 
-		err := h.repo.AssignDevice(c.Request.Context(), deviceID, request.AssignmentType, request.AssignmentID)
+		err := h.deviceService.AssignDevice(c.Request.Context(), deviceID, request.AssignmentType, request.AssignmentID)
 		if err != nil {
-			logger.Error("Failed to assign device", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, response.NewDeviceResponse(http.StatusInternalServerError, "Failed to assign device", nil))
+			respondDeviceError(c, "Failed to assign device", err)
 			return
 		}
 
@@ -184,10 +174,9 @@ func (h *DeviceHandler) UnassignDevice() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		deviceID := c.Param("id")
 
-		err := h.repo.UnassignDevice(c.Request.Context(), deviceID)
+		err := h.deviceService.UnassignDevice(c.Request.Context(), deviceID)
 		if err != nil {
-			logger.Error("Failed to unassign device", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, response.NewDeviceResponse(http.StatusInternalServerError, "Failed to unassign device", nil))
+			respondDeviceError(c, "Failed to unassign device", err)
 			return
 		}
 
@@ -195,6 +184,259 @@ func (h *DeviceHandler) UnassignDevice() gin.HandlerFunc {
 	}
 }
 
+func (h *DeviceHandler) MarkDeviceStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Status models.DeviceStatus `json:"status" binding:"required"`
+			Note   string              `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Invalid input", "Status is required"))
+			return
+		}
+
+		err := h.deviceService.MarkDeviceStatus(c.Request.Context(), c.Param("id"), request.Status, request.Note)
+		if err != nil {
+			respondDeviceError(c, "Failed to change device status", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, response.NewDeviceResponse(http.StatusOK, "Device status changed successfully", nil))
+	}
+}
+
+// maxDeviceImportFile limits the size of an uploaded import file
+const maxDeviceImportFile = 10 << 20
+
+// ImportDevices takes a CSV or XLSX file uploaded as "file". With dryRun=true the file is only
+// checked. A file with invalid rows is rejected as a whole, with the problems listed per row.
+func (h *DeviceHandler) ImportDevices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid input", "A CSV or XLSX file is required in the file field")
+			return
+		}
+		if header.Size > maxDeviceImportFile {
+			response.Error(c, http.StatusRequestEntityTooLarge, "File too large", "Import files are limited to 10 MB")
+			return
+		}
+		dryRun, _ := strconv.ParseBool(c.DefaultQuery("dryRun", "false"))
+
+		file, err := header.Open()
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+
+		result, err := h.deviceService.ImportDevices(c.Request.Context(), header.Filename, data, dryRun)
+		if errors.Is(err, services.ErrInvalidImport) {
+			response.Error(c, http.StatusBadRequest, "Invalid import file", err.Error())
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to import devices", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to import devices", err.Error())
+			return
+		}
+
+		switch {
+		case !result.Valid():
+			c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Import rejected, no devices were added", result))
+		case dryRun:
+			response.Success(c, http.StatusOK, "Import file is valid", result)
+		default:
+			response.Success(c, http.StatusCreated, "Devices imported successfully", result)
+		}
+	}
+}
+
+func (h *DeviceHandler) GetDeviceHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		events, err := h.deviceService.GetHistory(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			respondDeviceError(c, "Failed to get device history", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Device history retrieved successfully", gin.H{"events": events})
+	}
+}
+
+// WriteOffDevice takes a damaged device off the books and posts its remaining book value as a loss
+func (h *DeviceHandler) WriteOffDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Note string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Invalid input", err.Error()))
+			return
+		}
+
+		expense, err := h.deviceService.WriteOffDevice(c.Request.Context(), c.Param("id"), request.Note)
+		if err != nil {
+			respondDeviceError(c, "Failed to write off device", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Device written off successfully", gin.H{"lossExpense": expense})
+	}
+}
+
+// OpenRepair sends a damaged device to a vendor or technician; sentDate (YYYY-MM-DD) defaults to today
+func (h *DeviceHandler) OpenRepair() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Fault    string `json:"fault"`
+			Vendor   string `json:"vendor"`
+			SentDate string `json:"sentDate"`
+			Note     string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Invalid input", err.Error()))
+			return
+		}
+
+		request := services.RepairRequest{Fault: input.Fault, Vendor: input.Vendor, SentDate: time.Now(), Note: input.Note}
+		if input.SentDate != "" {
+			var err error
+			if request.SentDate, err = time.ParseInLocation(invoiceDateLayout, input.SentDate, time.Local); err != nil {
+				c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Invalid input", "sentDate must be YYYY-MM-DD"))
+				return
+			}
+		}
+
+		ticket, err := h.deviceService.OpenRepair(c.Request.Context(), c.Param("id"), request)
+		if err != nil {
+			respondDeviceError(c, "Failed to open repair ticket", err)
+			return
+		}
+
+		response.Success(c, http.StatusCreated, "Repair ticket opened successfully", ticket)
+	}
+}
+
+// CloseRepair records the outcome of a repair ticket; returnedDate (YYYY-MM-DD) defaults to today
+func (h *DeviceHandler) CloseRepair() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Outcome                 models.RepairOutcome `json:"outcome"`
+			ReturnedDate            string               `json:"returnedDate"`
+			Cost                    money.Amount         `json:"cost"`
+			ReplacementSerialNumber string               `json:"replacementSerialNumber"`
+			Note                    string               `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Invalid input", err.Error()))
+			return
+		}
+
+		closure := services.RepairClosure{
+			Outcome:                 models.RepairOutcome(strings.ToUpper(string(input.Outcome))),
+			ReturnedDate:            time.Now(),
+			Cost:                    input.Cost,
+			ReplacementSerialNumber: input.ReplacementSerialNumber,
+			Note:                    input.Note,
+		}
+		if input.ReturnedDate != "" {
+			var err error
+			if closure.ReturnedDate, err = time.ParseInLocation(invoiceDateLayout, input.ReturnedDate, time.Local); err != nil {
+				c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Invalid input", "returnedDate must be YYYY-MM-DD"))
+				return
+			}
+		}
+
+		ticket, err := h.deviceService.CloseRepair(c.Request.Context(), c.Param("id"), c.Param("ticketId"), closure)
+		if err != nil {
+			respondDeviceError(c, "Failed to close repair ticket", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Repair ticket closed successfully", ticket)
+	}
+}
+
+func (h *DeviceHandler) GetDeviceRepairs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tickets, err := h.deviceService.GetRepairs(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			respondDeviceError(c, "Failed to get repair tickets", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Repair tickets retrieved successfully", gin.H{"tickets": tickets})
+	}
+}
+
+// GetRepairs lists repair tickets across devices, e.g. ?status=open for devices still with vendors
+func (h *DeviceHandler) GetRepairs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := models.RepairTicketStatus(strings.ToUpper(c.Query("status")))
+		tickets, err := h.deviceService.ListRepairs(c.Request.Context(), status)
+		if err != nil {
+			respondDeviceError(c, "Failed to get repair tickets", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Repair tickets retrieved successfully", gin.H{"tickets": tickets})
+	}
+}
+
+func (h *DeviceHandler) GetInventoryReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		asOf, ok := reportDate(c)
+		if !ok {
+			return
+		}
+
+		report, err := h.deviceService.InventoryReport(c.Request.Context(), asOf)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Failed to build inventory report", err.Error())
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Inventory report retrieved successfully", report)
+	}
+}
+
+func (h *DeviceHandler) GetDepreciationReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		asOf, ok := reportDate(c)
+		if !ok {
+			return
+		}
+
+		report, err := h.deviceService.DepreciationReport(c.Request.Context(), asOf)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Failed to build depreciation report", err.Error())
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Depreciation report retrieved successfully", report)
+	}
+}
+
+// reportDate reads the asOf date devices are depreciated to, today by default
+func reportDate(c *gin.Context) (time.Time, bool) {
+	now := time.Now()
+	asOf := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if value := c.Query("asOf"); value != "" {
+		var err error
+		if asOf, err = time.ParseInLocation(invoiceDateLayout, value, time.Local); err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid asOf", "expected YYYY-MM-DD")
+			return time.Time{}, false
+		}
+	}
+	return asOf, true
+}
+
 func (h *DeviceHandler) GetDeviceByAssignment() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		assignmentType := c.Query("assignmentType")
@@ -220,3 +462,50 @@ func (h *DeviceHandler) GetDeviceByAssignment() gin.HandlerFunc {
 		c.JSON(http.StatusOK, response.NewDeviceResponse(http.StatusOK, "Device retrieved successfully", deviceItemResponse))
 	}
 }
+
+func (h *DeviceHandler) GetDevicesPendingCollection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupBy := c.DefaultQuery("groupBy", "area")
+		if groupBy != "area" && groupBy != "building" {
+			c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Invalid input", "groupBy must be either area or building"))
+			return
+		}
+
+		devices, err := h.repo.GetDevicesPendingCollection(c.Request.Context())
+		if err != nil {
+			logger.Error("Failed to get devices pending collection", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to get devices pending collection", err.Error())
+			return
+		}
+
+		groups := response.NewDeviceCollectionGroups(devices, groupBy)
+		response.Success(c, http.StatusOK, "Devices pending collection retrieved successfully", gin.H{
+			"groupBy": groupBy,
+			"total":   len(devices),
+			"groups":  groups,
+		})
+	}
+}
+
+// respondDeviceError maps unknown devices to 404, bad assignment requests to 400 and moves the
+// device cannot make to 409; anything else is logged and reported as a 500 with the given message
+func respondDeviceError(c *gin.Context, message string, err error) {
+	var validation *services.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, response.NewDeviceResponse(http.StatusBadRequest, "Validation failed", validation.Errors))
+	case errors.Is(err, services.ErrDeviceNotFound):
+		response.Error(c, http.StatusNotFound, "Device not found", "No device found with the given ID")
+	case errors.Is(err, services.ErrRepairTicketNotFound):
+		response.Error(c, http.StatusNotFound, "Repair ticket not found", "No repair ticket found with the given ID for this device")
+	case errors.Is(err, services.ErrRepairTicketClosed), errors.Is(err, services.ErrRepairInProgress):
+		response.Error(c, http.StatusConflict, message, err.Error())
+	case errors.Is(err, services.ErrInvalidAssignment):
+		response.Error(c, http.StatusBadRequest, "Invalid assignment", err.Error())
+	case errors.Is(err, services.ErrInvalidDeviceTransition), errors.Is(err, services.ErrAssignmentConflict):
+		response.Error(c, http.StatusConflict, message, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message, err.Error())
+	}
+}
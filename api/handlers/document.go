@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/timam/uttarawave-backend/internals/documents"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// documentRequest reads the ?format= (html or pdf) and ?lang= (en or bn) of a document request
+func documentRequest(c *gin.Context) (documents.Format, documents.Language, bool) {
+	format, err := documents.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", "", false
+	}
+	language, err := documents.ParseLanguage(c.Query("lang"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", "", false
+	}
+	return format, language, true
+}
+
+// renderDocument renders the template and writes it out, naming PDFs after filename
+func renderDocument(c *gin.Context, renderer *documents.Renderer, name string, format documents.Format,
+	language documents.Language, filename string, data any) {
+	document, err := renderer.Render(c.Request.Context(), name, format, language, data)
+	if errors.Is(err, documents.ErrPDFNotConfigured) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to render document", zap.Error(err), zap.String("template", name), zap.String("document", filename))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render document"})
+		return
+	}
+
+	if format == documents.FormatPDF {
+		c.Header("Content-Disposition", `inline; filename="`+filename+`.pdf"`)
+	}
+	c.Data(http.StatusOK, format.ContentType(), document)
+}
@@ -0,0 +1,489 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/api/response"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+type EmployeeHandler struct {
+	repo           repositories.EmployeeRepository
+	payrollService *services.PayrollService
+}
+
+func NewEmployeeHandler(er repositories.EmployeeRepository, ps *services.PayrollService) *EmployeeHandler {
+	return &EmployeeHandler{
+		repo:           er,
+		payrollService: ps,
+	}
+}
+
+// getEmployee loads the employee from the :id path parameter, writing the error response itself on failure
+func (h *EmployeeHandler) getEmployee(c *gin.Context) (*models.Employee, bool) {
+	id := c.Param("id")
+	employee, err := h.repo.GetEmployeeByID(c.Request.Context(), id)
+	if err != nil {
+		logger.Error("Failed to get employee", zap.Error(err), zap.String("id", id))
+		response.Error(c, http.StatusInternalServerError, "Failed to get employee", err.Error())
+		return nil, false
+	}
+	if employee == nil {
+		response.Error(c, http.StatusNotFound, "Employee not found", "no employee found with the given ID")
+		return nil, false
+	}
+	return employee, true
+}
+
+func isSelf(c *gin.Context, employeeID string) bool {
+	return auth.ActorID(c.Request.Context()) == employeeID
+}
+
+func (h *EmployeeHandler) CreateEmployee() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Name     string              `json:"name"`
+			Mobile   string              `json:"mobile"`
+			Email    *string             `json:"email,omitempty"`
+			Password string              `json:"password"`
+			Role     models.EmployeeRole `json:"role"`
+			Salary   money.Amount        `json:"salary"`
+			JoinDate *time.Time          `json:"joinDate,omitempty"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			logger.Error("Failed to bind JSON", zap.Error(err))
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+
+		if input.Name == "" || input.Mobile == "" || input.Password == "" {
+			response.Error(c, http.StatusBadRequest, "Missing required fields", "name, mobile and password are required")
+			return
+		}
+		if input.Role == "" {
+			input.Role = models.RoleEmployee
+		}
+		if !input.Role.IsValid() {
+			response.Error(c, http.StatusBadRequest, "Invalid role", "role must be either Admin or Employee")
+			return
+		}
+		if input.Salary < 0 {
+			response.Error(c, http.StatusBadRequest, "Invalid salary", "salary cannot be negative")
+			return
+		}
+
+		existing, err := h.repo.GetEmployeeByMobile(c.Request.Context(), input.Mobile)
+		if err != nil {
+			logger.Error("Failed to check employee mobile", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to create employee", err.Error())
+			return
+		}
+		if existing != nil {
+			response.Error(c, http.StatusConflict, "Mobile number already in use", "an employee with this mobile number already exists")
+			return
+		}
+
+		hash, err := auth.HashPassword(input.Password)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid password", err.Error())
+			return
+		}
+
+		employee := models.Employee{
+			ID:           uuid.New().String(),
+			Name:         input.Name,
+			Mobile:       input.Mobile,
+			Email:        input.Email,
+			PasswordHash: hash,
+			Role:         input.Role,
+			IsActive:     true,
+			JoinDate:     time.Now(),
+		}
+		if input.JoinDate != nil {
+			employee.JoinDate = *input.JoinDate
+		}
+
+		if err := h.payrollService.CreateEmployee(c.Request.Context(), &employee, input.Salary); err != nil {
+			logger.Error("Failed to create employee", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to create employee", err.Error())
+			return
+		}
+
+		logger.Info("Employee created successfully", zap.String("id", employee.ID), zap.String("role", string(employee.Role)))
+		response.Success(c, http.StatusCreated, "Employee created successfully", employee)
+	}
+}
+
+func (h *EmployeeHandler) GetEmployee() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		employee, ok := h.getEmployee(c)
+		if !ok {
+			return
+		}
+		response.Success(c, http.StatusOK, "Employee retrieved successfully", employee)
+	}
+}
+
+func (h *EmployeeHandler) GetAllEmployees() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "50"))
+
+		employees, totalCount, err := h.repo.GetEmployeesPaginated(c.Request.Context(), page, pageSize)
+		if err != nil {
+			logger.Error("Failed to get employees", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to get employees", err.Error())
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Employees retrieved successfully", gin.H{
+			"items": employees,
+			"pagination": response.PaginationInfo{
+				Total: totalCount,
+				Page:  page,
+				Size:  pageSize,
+			},
+		})
+	}
+}
+
+func (h *EmployeeHandler) UpdateEmployee() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Name     string  `json:"name"`
+			Mobile   string  `json:"mobile"`
+			Email    *string `json:"email,omitempty"`
+			Password string  `json:"password,omitempty"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			logger.Error("Failed to bind JSON", zap.Error(err))
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+
+		employee, ok := h.getEmployee(c)
+		if !ok {
+			return
+		}
+
+		if input.Mobile != "" && input.Mobile != employee.Mobile {
+			existing, err := h.repo.GetEmployeeByMobile(c.Request.Context(), input.Mobile)
+			if err != nil {
+				logger.Error("Failed to check employee mobile", zap.Error(err))
+				response.Error(c, http.StatusInternalServerError, "Failed to update employee", err.Error())
+				return
+			}
+			if existing != nil {
+				response.Error(c, http.StatusConflict, "Mobile number already in use", "an employee with this mobile number already exists")
+				return
+			}
+			employee.Mobile = input.Mobile
+		}
+		if input.Name != "" {
+			employee.Name = input.Name
+		}
+		if input.Email != nil {
+			employee.Email = input.Email
+		}
+		if input.Password != "" {
+			hash, err := auth.HashPassword(input.Password)
+			if err != nil {
+				response.Error(c, http.StatusBadRequest, "Invalid password", err.Error())
+				return
+			}
+			employee.PasswordHash = hash
+		}
+
+		if err := h.repo.UpdateEmployee(c.Request.Context(), employee); err != nil {
+			logger.Error("Failed to update employee", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to update employee", err.Error())
+			return
+		}
+
+		logger.Info("Employee updated successfully", zap.String("id", employee.ID))
+		response.Success(c, http.StatusOK, "Employee updated successfully", employee)
+	}
+}
+
+func (h *EmployeeHandler) DeleteEmployee() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		employee, ok := h.getEmployee(c)
+		if !ok {
+			return
+		}
+		if isSelf(c, employee.ID) {
+			response.Error(c, http.StatusConflict, "Cannot delete own account", "you cannot delete the account you are logged in with")
+			return
+		}
+
+		payouts, err := h.repo.CountSalaryPayouts(c.Request.Context(), employee.ID)
+		if err != nil {
+			logger.Error("Failed to count salary payouts", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to delete employee", err.Error())
+			return
+		}
+		if payouts > 0 {
+			response.Error(c, http.StatusConflict, "Employee has salary payouts", "employees with payroll history cannot be deleted, deactivate them instead")
+			return
+		}
+
+		if err := h.repo.DeleteEmployee(c.Request.Context(), employee.ID); err != nil {
+			logger.Error("Failed to delete employee", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to delete employee", err.Error())
+			return
+		}
+
+		logger.Info("Employee deleted successfully", zap.String("id", employee.ID))
+		response.Success(c, http.StatusOK, "Employee deleted successfully", nil)
+	}
+}
+
+func (h *EmployeeHandler) setActive(active bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		employee, ok := h.getEmployee(c)
+		if !ok {
+			return
+		}
+		if !active && isSelf(c, employee.ID) {
+			response.Error(c, http.StatusConflict, "Cannot deactivate own account", "you cannot deactivate the account you are logged in with")
+			return
+		}
+
+		employee.IsActive = active
+		if err := h.repo.UpdateEmployee(c.Request.Context(), employee); err != nil {
+			logger.Error("Failed to update employee status", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to update employee status", err.Error())
+			return
+		}
+
+		message := "Employee deactivated successfully"
+		if active {
+			message = "Employee activated successfully"
+		}
+		logger.Info(message, zap.String("id", employee.ID), zap.String("by", auth.ActorID(c.Request.Context())))
+		response.Success(c, http.StatusOK, message, employee)
+	}
+}
+
+func (h *EmployeeHandler) ActivateEmployee() gin.HandlerFunc {
+	return h.setActive(true)
+}
+
+func (h *EmployeeHandler) DeactivateEmployee() gin.HandlerFunc {
+	return h.setActive(false)
+}
+
+func (h *EmployeeHandler) AssignRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Role models.EmployeeRole `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			logger.Error("Failed to bind JSON", zap.Error(err))
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+		if !input.Role.IsValid() {
+			response.Error(c, http.StatusBadRequest, "Invalid role", "role must be either Admin or Employee")
+			return
+		}
+
+		employee, ok := h.getEmployee(c)
+		if !ok {
+			return
+		}
+		if isSelf(c, employee.ID) && input.Role != employee.Role {
+			response.Error(c, http.StatusConflict, "Cannot change own role", "you cannot change the role of the account you are logged in with")
+			return
+		}
+
+		employee.Role = input.Role
+		if err := h.repo.UpdateEmployee(c.Request.Context(), employee); err != nil {
+			logger.Error("Failed to assign role", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to assign role", err.Error())
+			return
+		}
+
+		logger.Info("Employee role assigned", zap.String("id", employee.ID), zap.String("role", string(employee.Role)))
+		response.Success(c, http.StatusOK, "Role assigned successfully", employee)
+	}
+}
+
+func (h *EmployeeHandler) UpdateSalary() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Salary        money.Amount `json:"salary"`
+			EffectiveFrom *time.Time   `json:"effectiveFrom,omitempty"`
+			Reason        string       `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			logger.Error("Failed to bind JSON", zap.Error(err))
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+		if input.Salary < 0 {
+			response.Error(c, http.StatusBadRequest, "Invalid salary", "salary cannot be negative")
+			return
+		}
+
+		employee, ok := h.getEmployee(c)
+		if !ok {
+			return
+		}
+
+		history := models.SalaryHistory{
+			ID:             uuid.New().String(),
+			EmployeeID:     employee.ID,
+			Salary:         input.Salary,
+			PreviousSalary: employee.Salary,
+			EffectiveFrom:  time.Now(),
+			Reason:         input.Reason,
+			ChangedBy:      auth.ActorID(c.Request.Context()),
+		}
+		if input.EffectiveFrom != nil {
+			history.EffectiveFrom = *input.EffectiveFrom
+		}
+
+		employee.Salary = input.Salary
+		if err := h.repo.UpdateSalary(c.Request.Context(), employee, &history); err != nil {
+			logger.Error("Failed to update salary", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to update salary", err.Error())
+			return
+		}
+
+		logger.Info("Employee salary updated", zap.String("id", employee.ID), zap.Stringer("salary", employee.Salary))
+		response.Success(c, http.StatusOK, "Salary updated successfully", history)
+	}
+}
+
+func (h *EmployeeHandler) GetSalaryHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		employee, ok := h.getEmployee(c)
+		if !ok {
+			return
+		}
+
+		history, err := h.repo.GetSalaryHistory(c.Request.Context(), employee.ID)
+		if err != nil {
+			logger.Error("Failed to get salary history", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to get salary history", err.Error())
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Salary history retrieved successfully", history)
+	}
+}
+
+func (h *EmployeeHandler) GetSalaryPayouts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		employee, ok := h.getEmployee(c)
+		if !ok {
+			return
+		}
+
+		payouts, err := h.repo.GetSalaryPayouts(c.Request.Context(), employee.ID)
+		if err != nil {
+			logger.Error("Failed to get salary payouts", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to get salary payouts", err.Error())
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Salary payouts retrieved successfully", payouts)
+	}
+}
+
+func parsePeriodInput(c *gin.Context) (services.BillingPeriod, bool) {
+	var input struct {
+		Period string `json:"period"`
+	}
+	// The body is optional; an empty one means the current period
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return services.BillingPeriod{}, false
+	}
+
+	if input.Period == "" {
+		return services.NewBillingPeriod(time.Now()), true
+	}
+
+	period, err := services.ParseBillingPeriod(input.Period)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid period", err.Error())
+		return services.BillingPeriod{}, false
+	}
+	return period, true
+}
+
+func (h *EmployeeHandler) PaySalary() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		period, ok := parsePeriodInput(c)
+		if !ok {
+			return
+		}
+
+		employee, ok := h.getEmployee(c)
+		if !ok {
+			return
+		}
+		if !employee.IsActive {
+			response.Error(c, http.StatusConflict, "Employee is inactive", "salary can only be paid to active employees")
+			return
+		}
+		if employee.Salary <= 0 {
+			response.Error(c, http.StatusConflict, "Employee has no salary", "set a salary before paying the employee")
+			return
+		}
+
+		payout, err := h.payrollService.PayEmployee(c.Request.Context(), employee, period)
+		if err != nil {
+			logger.Error("Failed to pay salary", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to pay salary", err.Error())
+			return
+		}
+		if payout == nil {
+			response.Error(c, http.StatusConflict, "Salary already paid", "the employee has already been paid for "+period.String())
+			return
+		}
+
+		logger.Info("Salary paid", zap.String("employeeID", employee.ID), zap.String("period", payout.Period))
+		response.Success(c, http.StatusCreated, "Salary paid successfully", payout)
+	}
+}
+
+func (h *EmployeeHandler) RunPayroll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		period, ok := parsePeriodInput(c)
+		if !ok {
+			return
+		}
+
+		result, err := h.payrollService.RunPayroll(c.Request.Context(), period)
+		if err != nil && result == nil {
+			logger.Error("Failed to run payroll", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to run payroll", err.Error())
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusMultiStatus, response.SuccessResponse{
+				Status:  http.StatusMultiStatus,
+				Message: err.Error(),
+				Data:    result,
+			})
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Payroll completed successfully", result)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/internals/repositories"
 	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
 	"go.uber.org/zap"
 	"net/http"
 	"time"
@@ -30,6 +31,10 @@ func (h *ExpenseHandler) CreateExpense() gin.HandlerFunc {
 			return
 		}
 
+		if expense.Currency == "" {
+			expense.Currency = money.DefaultCurrency
+		}
+
 		expense.ID = uuid.New().String()
 		expense.PaidAt = time.Now()
 
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/timam/uttarawave-backend/api/response"
+	"github.com/timam/uttarawave-backend/internals/services"
+	"github.com/timam/uttarawave-backend/pkg/gateway"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+)
+
+// maxWebhookBody bounds how much of a webhook request is read before verifying its signature
+const maxWebhookBody = 1 << 20
+
+type GatewayHandler struct {
+	gatewayService *services.GatewayService
+}
+
+func NewGatewayHandler(gs *services.GatewayService) *GatewayHandler {
+	return &GatewayHandler{
+		gatewayService: gs,
+	}
+}
+
+func (h *GatewayHandler) CreateCheckout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Gateway    string         `json:"gateway" binding:"required"`
+			InvoiceID  *string        `json:"invoiceId"`
+			CustomerID *string        `json:"customerId"`
+			Amount     money.Amount   `json:"amount"`
+			Currency   money.Currency `json:"currency"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+		if input.Amount < 0 {
+			response.Error(c, http.StatusBadRequest, "Invalid input", "amount cannot be negative")
+			return
+		}
+		if input.Currency != "" && !input.Currency.IsValid() {
+			response.Error(c, http.StatusBadRequest, "Invalid input", "unsupported currency")
+			return
+		}
+
+		transaction, err := h.gatewayService.CreateCheckout(c.Request.Context(), services.CheckoutRequest{
+			Gateway:    input.Gateway,
+			InvoiceID:  input.InvoiceID,
+			CustomerID: input.CustomerID,
+			Amount:     input.Amount,
+			Currency:   input.Currency,
+		})
+		if err != nil {
+			respondGatewayError(c, "Failed to create checkout", err)
+			return
+		}
+
+		response.Success(c, http.StatusCreated, "Checkout created successfully", transaction)
+	}
+}
+
+// Webhook receives payment callbacks from a gateway. It is not behind authentication;
+// the gateway's signature on the body is what authenticates it.
+func (h *GatewayHandler) Webhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid webhook", err.Error())
+			return
+		}
+
+		transaction, err := h.gatewayService.HandleWebhook(c.Request.Context(), c.Param("gateway"), c.Request.Header, body)
+		if err != nil {
+			respondGatewayError(c, "Failed to process webhook", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Webhook processed successfully", transaction)
+	}
+}
+
+// GetTransaction returns a checkout, first asking the gateway for its status while it is pending
+func (h *GatewayHandler) GetTransaction() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transaction, err := h.gatewayService.RefreshStatus(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			respondGatewayError(c, "Failed to get gateway transaction", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Gateway transaction retrieved successfully", transaction)
+	}
+}
+
+// Simulate completes or fails a checkout on a test gateway such as the fake one
+func (h *GatewayHandler) Simulate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			TransactionID string         `json:"transactionId" binding:"required"`
+			Status        gateway.Status `json:"status"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+		if input.Status == "" {
+			input.Status = gateway.StatusCompleted
+		}
+		if !input.Status.IsFinal() {
+			response.Error(c, http.StatusBadRequest, "Invalid input", "status must be COMPLETED, FAILED or CANCELLED")
+			return
+		}
+
+		transaction, err := h.gatewayService.Simulate(c.Request.Context(), c.Param("gateway"), input.TransactionID, input.Status)
+		if err != nil {
+			respondGatewayError(c, "Failed to simulate payment", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Payment simulated successfully", transaction)
+	}
+}
+
+func respondGatewayError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownGateway),
+		errors.Is(err, services.ErrGatewayTransactionNotFound),
+		errors.Is(err, services.ErrInvoiceNotFound):
+		response.Error(c, http.StatusNotFound, message, err.Error())
+	case errors.Is(err, gateway.ErrInvalidSignature):
+		response.Error(c, http.StatusUnauthorized, message, err.Error())
+	case errors.Is(err, gateway.ErrInvalidPayload),
+		errors.Is(err, services.ErrInvalidCheckout),
+		errors.Is(err, services.ErrInvalidPayment),
+		errors.Is(err, services.ErrCurrencyMismatch),
+		errors.Is(err, services.ErrSimulationNotSupported):
+		response.Error(c, http.StatusBadRequest, message, err.Error())
+	case errors.Is(err, services.ErrInvoiceAlreadyPaid),
+		errors.Is(err, services.ErrInvoiceNotOpen):
+		response.Error(c, http.StatusConflict, message, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message, err.Error())
+	}
+}
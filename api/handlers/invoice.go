@@ -1,24 +1,42 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/api/response"
+	"github.com/timam/uttarawave-backend/internals/documents"
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
 	"github.com/timam/uttarawave-backend/pkg/logger"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const invoiceDateLayout = "2006-01-02"
+
 type InvoiceHandler struct {
-	invoiceRepo      repositories.InvoiceRepository
-	subscriptionRepo repositories.SubscriptionRepository
+	invoiceRepo     repositories.InvoiceRepository
+	invoiceService  *services.InvoiceService
+	documentService *services.DocumentService
+	renderer        *documents.Renderer
 }
 
-func NewInvoiceHandler(ir repositories.InvoiceRepository, sr repositories.SubscriptionRepository) *InvoiceHandler {
+func NewInvoiceHandler(
+	ir repositories.InvoiceRepository,
+	is *services.InvoiceService,
+	ds *services.DocumentService,
+	renderer *documents.Renderer) *InvoiceHandler {
 	return &InvoiceHandler{
-		invoiceRepo:      ir,
-		subscriptionRepo: sr,
+		invoiceRepo:     ir,
+		invoiceService:  is,
+		documentService: ds,
+		renderer:        renderer,
 	}
 }
 
@@ -31,31 +49,219 @@ func (h *InvoiceHandler) CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
-		invoice.ID = uuid.New().String()
-		invoice.Status = models.InvoicePending
+		err := h.invoiceService.CreateInvoice(c.Request.Context(), &invoice)
+		if errors.Is(err, services.ErrInvalidInvoice) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invoice"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, invoice)
+	}
+}
+
+func (h *InvoiceHandler) GetInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		detail, err := h.invoiceService.GetInvoiceDetail(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			logger.Error("Failed to get invoice", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to get invoice", err.Error())
+			return
+		}
+		if detail == nil {
+			response.Error(c, http.StatusNotFound, "Invoice not found", "no invoice with this ID")
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Invoice retrieved successfully", detail)
+	}
+}
+
+// GetAllInvoices lists invoices filtered by status, number, customerId, subscriptionId and an
+// inclusive dueFrom/dueTo date range (YYYY-MM-DD)
+func (h *InvoiceHandler) GetAllInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "50"))
+
+		filter := repositories.InvoiceFilter{
+			Status:         models.InvoiceStatus(strings.ToUpper(c.Query("status"))),
+			Number:         strings.ToUpper(c.Query("number")),
+			CustomerID:     c.Query("customerId"),
+			SubscriptionID: c.Query("subscriptionId"),
+		}
+		if filter.Status != "" && !filter.Status.IsValid() {
+			response.Error(c, http.StatusBadRequest, "Invalid status", "unknown invoice status "+c.Query("status"))
+			return
+		}
 
-		if invoice.SubscriptionID != nil {
-			subscription, err := h.subscriptionRepo.GetSubscription(c.Request.Context(), *invoice.SubscriptionID)
+		if value := c.Query("dueFrom"); value != "" {
+			dueFrom, err := time.ParseInLocation(invoiceDateLayout, value, time.Local)
+			if err != nil {
+				response.Error(c, http.StatusBadRequest, "Invalid dueFrom", "expected YYYY-MM-DD")
+				return
+			}
+			filter.DueFrom = &dueFrom
+		}
+		if value := c.Query("dueTo"); value != "" {
+			dueTo, err := time.ParseInLocation(invoiceDateLayout, value, time.Local)
 			if err != nil {
-				logger.Error("Failed to get subscription", zap.Error(err))
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription"})
+				response.Error(c, http.StatusBadRequest, "Invalid dueTo", "expected YYYY-MM-DD")
 				return
 			}
+			dueTo = dueTo.AddDate(0, 0, 1)
+			filter.DueTo = &dueTo
+		}
+
+		invoices, totalCount, err := h.invoiceRepo.GetInvoicesPaginated(c.Request.Context(), filter, page, pageSize)
+		if err != nil {
+			logger.Error("Failed to get invoices", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "Failed to get invoices", err.Error())
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Invoices retrieved successfully", gin.H{
+			"items": invoices,
+			"pagination": response.PaginationInfo{
+				Total: totalCount,
+				Page:  page,
+				Size:  pageSize,
+			},
+		})
+	}
+}
 
-			invoice.CustomerID = subscription.CustomerID
-			invoice.Amount = getMonthlyPrice(subscription)
-			invoice.DueDate = subscription.RenewalDate
+// RenderInvoice renders the printable invoice as HTML or, with ?format=pdf, as a PDF, in
+// English or with ?lang=bn in Bangla
+func (h *InvoiceHandler) RenderInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format, language, ok := documentRequest(c)
+		if !ok {
+			return
 		}
 
-		err := h.invoiceRepo.CreateInvoice(c.Request.Context(), &invoice)
+		invoice, err := h.documentService.GetInvoiceDocument(c.Request.Context(), c.Param("id"))
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
 		if err != nil {
-			logger.Error("Failed to create invoice", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invoice"})
+			logger.Error("Failed to get invoice", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invoice"})
 			return
 		}
 
-		c.JSON(http.StatusCreated, invoice)
+		renderDocument(c, h.renderer, documents.Invoice, format, language, invoice.Number, invoice)
+	}
+}
+
+// RenderBuildingInvoices renders the invoices of every customer in ?buildingId= for the billing
+// ?period= (YYYY-MM) as one printable file, one invoice per page. Paid invoices are left out
+// unless ?includePaid=true.
+func (h *InvoiceHandler) RenderBuildingInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format, language, ok := documentRequest(c)
+		if !ok {
+			return
+		}
+
+		buildingID := c.Query("buildingId")
+		if buildingID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "buildingId is required"})
+			return
+		}
+		period := c.Query("period")
+		if _, err := time.Parse("2006-01", period); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "period is required as YYYY-MM"})
+			return
+		}
+		includePaid, _ := strconv.ParseBool(c.DefaultQuery("includePaid", "false"))
+
+		invoices, err := h.documentService.GetBuildingInvoices(c.Request.Context(), buildingID, period, includePaid)
+		if errors.Is(err, services.ErrBuildingNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get building invoices", zap.Error(err), zap.String("buildingID", buildingID))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get building invoices"})
+			return
+		}
+
+		renderDocument(c, h.renderer, documents.Invoices, format, language, "invoices-"+period, invoices)
+	}
+}
+
+func (h *InvoiceHandler) UpdateInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Lines   []models.InvoiceLine `json:"lines,omitempty"`
+			DueDate *time.Time           `json:"dueDate,omitempty"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			logger.Error("Failed to bind JSON", zap.Error(err))
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+
+		invoice, err := h.invoiceService.UpdateInvoice(c.Request.Context(), c.Param("id"), services.InvoiceUpdate{
+			Lines:   input.Lines,
+			DueDate: input.DueDate,
+		})
+		if err != nil {
+			respondInvoiceError(c, "Failed to update invoice", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, "Invoice updated successfully", invoice)
+	}
+}
+
+func (h *InvoiceHandler) CancelInvoice() gin.HandlerFunc {
+	return h.closeInvoice("Invoice cancelled successfully", h.invoiceService.CancelInvoice)
+}
+
+func (h *InvoiceHandler) VoidInvoice() gin.HandlerFunc {
+	return h.closeInvoice("Invoice voided successfully", h.invoiceService.VoidInvoice)
+}
+
+func (h *InvoiceHandler) closeInvoice(message string, closeFn func(ctx context.Context, id, reason string) (*models.Invoice, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+			logger.Error("Failed to bind JSON", zap.Error(err))
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+
+		invoice, err := closeFn(c.Request.Context(), c.Param("id"), input.Reason)
+		if err != nil {
+			respondInvoiceError(c, "Failed to close invoice", err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, message, invoice)
 	}
 }
 
-// Add other invoice handler methods (GetInvoice, UpdateInvoice, GetAllInvoices) here
+// respondInvoiceError maps invoice validation and state errors to 400/404/409 and anything else to 500
+func respondInvoiceError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		response.Error(c, http.StatusNotFound, "Invoice not found", err.Error())
+	case errors.Is(err, services.ErrInvalidInvoice):
+		response.Error(c, http.StatusBadRequest, message, err.Error())
+	case errors.Is(err, services.ErrInvoiceAlreadyPaid),
+		errors.Is(err, services.ErrInvoiceNotOpen),
+		errors.Is(err, services.ErrInvoiceHasPayments):
+		response.Error(c, http.StatusConflict, message, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message, err.Error())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/timam/uttarawave-backend/api/response"
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/money"
 	"net/http"
 	"strconv"
 )
@@ -30,10 +31,17 @@ func (h *PackageHandler) CreatePackage() gin.HandlerFunc {
 		pkg.IsActive = true // Set the package as active by default
 
 		// Validate mandatory fields for all package types
-		if pkg.Type == "" || pkg.Name == "" || pkg.Price == 0 {
+		if pkg.Type == "" || pkg.Name == "" || pkg.Price <= 0 {
 			response.Error(c, http.StatusBadRequest, "Missing required fields", "Type, name, and price are required")
 			return
 		}
+		if pkg.Currency == "" {
+			pkg.Currency = money.DefaultCurrency
+		}
+		if !pkg.Currency.IsValid() {
+			response.Error(c, http.StatusBadRequest, "Invalid currency", "Specified currency is not supported")
+			return
+		}
 
 		// Validate package type and required fields
 		switch pkg.Type {
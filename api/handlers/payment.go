@@ -1,28 +1,30 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/documents"
 	"github.com/timam/uttarawave-backend/internals/models"
-	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
 	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
 	"go.uber.org/zap"
 	"net/http"
-	"strconv"
 	"time"
 )
 
 type PaymentHandler struct {
-	paymentRepo      repositories.PaymentRepository
-	subscriptionRepo repositories.SubscriptionRepository
-	invoiceRepo      repositories.InvoiceRepository
+	paymentService  *services.PaymentService
+	documentService *services.DocumentService
+	renderer        *documents.Renderer
 }
 
-func NewPaymentHandler(pr repositories.PaymentRepository, sr repositories.SubscriptionRepository, ir repositories.InvoiceRepository) *PaymentHandler {
+func NewPaymentHandler(ps *services.PaymentService, ds *services.DocumentService, renderer *documents.Renderer) *PaymentHandler {
 	return &PaymentHandler{
-		paymentRepo:      pr,
-		subscriptionRepo: sr,
-		invoiceRepo:      ir,
+		paymentService:  ps,
+		documentService: ds,
+		renderer:        renderer,
 	}
 }
 
@@ -35,70 +37,114 @@ func (h *PaymentHandler) CreatePayment() gin.HandlerFunc {
 			return
 		}
 
-		payment.ID = uuid.New().String()
-		payment.PaidAt = time.Now()
+		if payment.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Payment amount must be greater than zero"})
+			return
+		}
+		if payment.Currency == "" {
+			payment.Currency = money.DefaultCurrency
+		}
+		if !payment.Currency.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+			return
+		}
 
-		if payment.InvoiceID != nil {
-			invoice, err := h.invoiceRepo.GetInvoiceByID(c.Request.Context(), *payment.InvoiceID)
-			if err != nil {
-				logger.Error("Failed to get invoice", zap.Error(err))
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invoice"})
-				return
-			}
+		if payment.Type == models.PaymentReversal {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Use the reverse endpoint to reverse a payment"})
+			return
+		}
 
-			if invoice.Status == models.InvoicePaid {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invoice is already paid"})
-				return
-			}
+		payment.ID = uuid.New().String()
+		payment.PaidAt = time.Now()
+		if payment.Type == "" {
+			payment.Type = models.PaymentIncoming
+		}
+		payment.ReceiptNumber = nil
+		payment.Gateway, payment.GatewayTransactionID = nil, nil
+		payment.ReversalOf, payment.Reason, payment.ReversedAt = nil, nil, nil
 
-			if payment.Amount >= invoice.Amount {
-				invoice.Status = models.InvoicePaid
-				invoice.PaidDate = &payment.PaidAt
-			} else {
-				invoice.Status = models.InvoicePending
-			}
+		posting, err := h.paymentService.PostPayment(c.Request.Context(), &payment)
+		switch {
+		case errors.Is(err, services.ErrInvoiceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		case errors.Is(err, services.ErrInvoiceAlreadyPaid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invoice is already paid"})
+			return
+		case errors.Is(err, services.ErrInvoiceNotOpen):
+			c.JSON(http.StatusConflict, gin.H{"error": "Invoice has been cancelled or voided"})
+			return
+		case errors.Is(err, services.ErrInvalidPayment):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrCurrencyMismatch):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Payment currency does not match the invoice currency"})
+			return
+		case err != nil:
+			logger.Error("Failed to post payment", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
+			return
+		}
 
-			err = h.invoiceRepo.UpdateInvoice(c.Request.Context(), invoice)
-			if err != nil {
-				logger.Error("Failed to update invoice", zap.Error(err))
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invoice"})
-				return
-			}
+		c.JSON(http.StatusCreated, posting)
+	}
+}
 
-			if invoice.SubscriptionID != nil {
-				subscription, err := h.subscriptionRepo.GetSubscription(c.Request.Context(), *invoice.SubscriptionID)
-				if err != nil {
-					logger.Error("Failed to get subscription", zap.Error(err))
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription"})
-					return
-				}
+func (h *PaymentHandler) ReversePayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Reason string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to reverse a payment"})
+			return
+		}
 
-				dueAmount, _ := strconv.ParseFloat(subscription.DueAmount, 64)
-				dueAmount -= payment.Amount
-				subscription.DueAmount = strconv.FormatFloat(dueAmount, 'f', 2, 64)
+		reversal, err := h.paymentService.ReversePayment(c.Request.Context(), c.Param("id"), input.Reason)
+		switch {
+		case errors.Is(err, services.ErrPaymentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		case errors.Is(err, services.ErrPaymentAlreadyReversed),
+			errors.Is(err, services.ErrCreditAlreadyUsed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrInvalidPayment):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			logger.Error("Failed to reverse payment", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reverse payment"})
+			return
+		}
 
-				if dueAmount <= 0 {
-					subscription.Status = "Active"
-					subscription.PaidUntil = addMonths(subscription.PaidUntil, 1)
-					subscription.RenewalDate = getFirstDayOfNextMonth(subscription.PaidUntil)
-				}
+		c.JSON(http.StatusCreated, reversal)
+	}
+}
 
-				err = h.subscriptionRepo.UpdateSubscription(c.Request.Context(), subscription)
-				if err != nil {
-					logger.Error("Failed to update subscription", zap.Error(err))
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subscription"})
-					return
-				}
-			}
+// GetReceipt renders the printable receipt of a payment, looked up by its ID or receipt number,
+// as HTML or, with ?format=pdf, as a PDF, in English or with ?lang=bn in Bangla
+func (h *PaymentHandler) GetReceipt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format, language, ok := documentRequest(c)
+		if !ok {
+			return
 		}
 
-		err := h.paymentRepo.CreatePayment(c.Request.Context(), &payment)
-		if err != nil {
-			logger.Error("Failed to create payment", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
+		receipt, err := h.documentService.GetReceipt(c.Request.Context(), c.Param("id"))
+		switch {
+		case errors.Is(err, services.ErrPaymentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		case errors.Is(err, services.ErrNoReceipt):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			logger.Error("Failed to get receipt", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get receipt"})
 			return
 		}
 
-		c.JSON(http.StatusCreated, payment)
+		renderDocument(c, h.renderer, documents.Receipt, format, language, receipt.Number, receipt)
 	}
 }
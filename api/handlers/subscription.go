@@ -1,78 +1,80 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
 	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 )
 
 type SubscriptionHandler struct {
-	repo        repositories.SubscriptionRepository
-	packageRepo repositories.PackageRepository
-	deviceRepo  repositories.DeviceRepository
-	invoiceRepo repositories.InvoiceRepository
+	repo                repositories.SubscriptionRepository
+	packageRepo         repositories.PackageRepository
+	deviceRepo          repositories.DeviceRepository
+	invoiceRepo         repositories.InvoiceRepository
+	subscriptionService *services.SubscriptionService
+	packageService      *services.PackageChangeService
+	holdService         *services.HoldService
 }
 
 func NewSubscriptionHandler(
 	repo repositories.SubscriptionRepository,
 	packageRepo repositories.PackageRepository,
 	deviceRepo repositories.DeviceRepository,
-	invoiceRepo repositories.InvoiceRepository) *SubscriptionHandler {
+	invoiceRepo repositories.InvoiceRepository,
+	subscriptionService *services.SubscriptionService,
+	packageService *services.PackageChangeService,
+	holdService *services.HoldService) *SubscriptionHandler {
 	return &SubscriptionHandler{
-		repo:        repo,
-		packageRepo: packageRepo,
-		deviceRepo:  deviceRepo,
-		invoiceRepo: invoiceRepo,
+		repo:                repo,
+		packageRepo:         packageRepo,
+		deviceRepo:          deviceRepo,
+		invoiceRepo:         invoiceRepo,
+		subscriptionService: subscriptionService,
+		packageService:      packageService,
+		holdService:         holdService,
 	}
 }
 
-func getFirstDayOfNextMonth(date time.Time) time.Time {
-	currentYear, currentMonth, _ := date.Date()
-	firstOfNextMonth := time.Date(currentYear, currentMonth+1, 1, 0, 0, 0, 0, date.Location())
-	return firstOfNextMonth
-}
-
 func (h *SubscriptionHandler) CreateSubscription() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var subscription models.Subscription
-		if err := c.ShouldBindJSON(&subscription); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+		var request struct {
+			CustomerID      string                  `json:"customerId"`
+			Type            models.SubscriptionType `json:"type"`
+			PackageID       string                  `json:"packageId"`
+			DeviceID        string                  `json:"deviceId"`
+			MonthlyDiscount money.Amount            `json:"monthlyDiscount"`
 		}
-
-		// Validate package
-		pkg, err := h.packageRepo.GetPackageByID(c.Request.Context(), subscription.PackageID)
-		if err != nil {
-			logger.Error("Failed to get package", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid package ID"})
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Set subscription details
-		subscription.ID = uuid.New().String()
-		subscription.PackagePrice = pkg.Price
-		subscription.Status = "Active"
-		subscription.StartDate = time.Now()
-		subscription.RenewalDate = getFirstDayOfNextMonth(subscription.StartDate)
-		subscription.PaidUntil = subscription.StartDate
-		subscription.DueAmount = strconv.FormatFloat(pkg.Price, 'f', 2, 64)
-
-		err = h.repo.CreateSubscription(c.Request.Context(), &subscription)
+		created, err := h.subscriptionService.CreateSubscription(c.Request.Context(), services.SubscriptionRequest{
+			CustomerID:      request.CustomerID,
+			Type:            request.Type,
+			PackageID:       request.PackageID,
+			DeviceID:        request.DeviceID,
+			MonthlyDiscount: request.MonthlyDiscount,
+		})
 		if err != nil {
-			logger.Error("Failed to create subscription", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subscription"})
+			respondSubscriptionError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusCreated, subscription)
+		c.JSON(http.StatusCreated, created)
 	}
 }
+
 func (h *SubscriptionHandler) GetSubscription() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
@@ -105,7 +107,11 @@ func (h *SubscriptionHandler) UpdateSubscription() gin.HandlerFunc {
 			return
 		}
 
-		var updateData models.Subscription
+		var updateData struct {
+			Status    models.SubscriptionStatus `json:"status"`
+			PackageID string                    `json:"packageId"`
+			DueAmount *money.Amount             `json:"dueAmount"`
+		}
 		if err := c.ShouldBindJSON(&updateData); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -123,20 +129,20 @@ func (h *SubscriptionHandler) UpdateSubscription() gin.HandlerFunc {
 			return
 		}
 
-		// Update fields based on provided data
-		if updateData.Status != "" {
-			existingSubscription.Status = updateData.Status
+		// Status only changes through the suspend, resume, cancel and reactivate endpoints
+		if updateData.Status != "" && updateData.Status != existingSubscription.Status {
+			c.JSON(http.StatusConflict, gin.H{"error": "Status cannot be updated directly, use suspend, resume, cancel or reactivate"})
+			return
 		}
-		if updateData.PackageID != "" {
-			existingSubscription.PackageID = updateData.PackageID
-			// Fetch the new package and update related fields
-			newPackage, err := h.packageRepo.GetPackageByID(c.Request.Context(), updateData.PackageID)
-			if err == nil {
-				existingSubscription.PackagePrice = newPackage.Price
-			}
+		// Package changes are billed, so they go through change-package
+		if updateData.PackageID != "" && updateData.PackageID != existingSubscription.PackageID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Package cannot be updated directly, use change-package"})
+			return
 		}
-		if updateData.DueAmount != "" {
-			existingSubscription.DueAmount = updateData.DueAmount
+
+		// Update fields based on provided data
+		if updateData.DueAmount != nil {
+			existingSubscription.DueAmount = *updateData.DueAmount
 		}
 		// Update other fields as needed
 
@@ -159,10 +165,8 @@ func (h *SubscriptionHandler) DeleteSubscription() gin.HandlerFunc {
 			return
 		}
 
-		err := h.repo.DeleteSubscription(c.Request.Context(), id)
-		if err != nil {
-			logger.Error("Failed to delete subscription", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subscription"})
+		if err := h.subscriptionService.DeleteSubscription(c.Request.Context(), id); err != nil {
+			respondSubscriptionError(c, err)
 			return
 		}
 
@@ -194,25 +198,200 @@ func (h *SubscriptionHandler) GetAllSubscriptions() gin.HandlerFunc {
 	}
 }
 
-//func (h *SubscriptionHandler) ProcessExpiredSubscriptions() {
-//	ctx := context.Background()
-//	expiredSubscriptions, err := h.repo.GetExpiredSubscriptions(ctx)
-//	if err != nil {
-//		logger.Error("Failed to get expired subscriptions", zap.Error(err))
-//		return
-//	}
-//
-//	for _, subscription := range expiredSubscriptions {
-//		if subscription.DeviceID != "" {
-//			err := h.deviceRepo.MarkDeviceForCollection(ctx, subscription.DeviceID)
-//			if err != nil {
-//				logger.Error("Failed to mark device for collection", zap.Error(err), zap.String("deviceID", subscription.DeviceID))
-//			}
-//		}
-//		subscription.Status = "Expired"
-//		err := h.repo.UpdateSubscription(ctx, &subscription)
-//		if err != nil {
-//			logger.Error("Failed to update subscription status", zap.Error(err), zap.String("subscriptionID", subscription.ID))
-//		}
-//	}
-//}
+func (h *SubscriptionHandler) ActivateSubscription() gin.HandlerFunc {
+	return h.changeStatus(h.subscriptionService.Activate)
+}
+
+func (h *SubscriptionHandler) SuspendSubscription() gin.HandlerFunc {
+	return h.changeStatus(h.subscriptionService.Suspend)
+}
+
+func (h *SubscriptionHandler) ResumeSubscription() gin.HandlerFunc {
+	return h.changeStatus(h.subscriptionService.Resume)
+}
+
+func (h *SubscriptionHandler) CancelSubscription() gin.HandlerFunc {
+	return h.changeStatus(h.subscriptionService.Cancel)
+}
+
+func (h *SubscriptionHandler) ReactivateSubscription() gin.HandlerFunc {
+	return h.changeStatus(h.subscriptionService.Reactivate)
+}
+
+// changeStatus runs a lifecycle action with the reason from the request body; transitions the
+// current status does not allow are rejected with 409
+func (h *SubscriptionHandler) changeStatus(changeFn func(ctx context.Context, id, reason string) (*services.SubscriptionTransition, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Reason string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		transition, err := changeFn(c.Request.Context(), c.Param("id"), input.Reason)
+		if err != nil {
+			respondSubscriptionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transition)
+	}
+}
+
+func (h *SubscriptionHandler) GetStatusHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		changes, err := h.subscriptionService.GetStatusHistory(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			respondSubscriptionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"changes": changes})
+	}
+}
+
+// ChangePackage moves the subscription to another package. With "effective": "next_cycle" the
+// change waits for the next renewal; otherwise it applies now with prorated billing.
+func (h *SubscriptionHandler) ChangePackage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			PackageID string `json:"packageId" binding:"required"`
+			Effective string `json:"effective"`
+			Reason    string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		request := services.PackageChangeRequest{PackageID: input.PackageID, Reason: input.Reason}
+		switch input.Effective {
+		case "", "now":
+		case "next_cycle":
+			request.NextCycle = true
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "effective must be now or next_cycle"})
+			return
+		}
+
+		result, err := h.packageService.ChangePackage(c.Request.Context(), c.Param("id"), request)
+		if err != nil {
+			respondSubscriptionError(c, err)
+			return
+		}
+
+		status := http.StatusOK
+		if request.NextCycle {
+			status = http.StatusAccepted
+		}
+		c.JSON(status, result)
+	}
+}
+
+func (h *SubscriptionHandler) GetPackageHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		changes, err := h.packageService.GetPackageHistory(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			respondSubscriptionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"changes": changes})
+	}
+}
+
+// PlaceHold pauses the subscription from startDate until endDate (YYYY-MM-DD), the day service
+// resumes. holdFee overrides the configured hold fee; 0 waives it.
+func (h *SubscriptionHandler) PlaceHold() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			StartDate string        `json:"startDate" binding:"required"`
+			EndDate   string        `json:"endDate" binding:"required"`
+			Reason    string        `json:"reason"`
+			HoldFee   *money.Amount `json:"holdFee"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		request := services.HoldRequest{Reason: input.Reason, Fee: input.HoldFee}
+		var err error
+		if request.StartDate, err = time.ParseInLocation(invoiceDateLayout, input.StartDate, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "startDate must be YYYY-MM-DD"})
+			return
+		}
+		if request.EndDate, err = time.ParseInLocation(invoiceDateLayout, input.EndDate, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "endDate must be YYYY-MM-DD"})
+			return
+		}
+
+		hold, err := h.holdService.PlaceHold(c.Request.Context(), c.Param("id"), request)
+		if err != nil {
+			respondSubscriptionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, hold)
+	}
+}
+
+// EndHold resumes a subscription on hold early, or cancels a hold that has not started
+func (h *SubscriptionHandler) EndHold() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		hold, err := h.holdService.EndHold(c.Request.Context(), c.Param("id"), input.Reason)
+		if err != nil {
+			respondSubscriptionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, hold)
+	}
+}
+
+func (h *SubscriptionHandler) GetHolds() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		holds, err := h.holdService.GetHolds(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			respondSubscriptionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"holds": holds})
+	}
+}
+
+func respondSubscriptionError(c *gin.Context, err error) {
+	var validation *services.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": validation.Errors})
+	case errors.Is(err, services.ErrInvalidHold):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrHoldExists), errors.Is(err, services.ErrNoOpenHold):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPackageChange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPackageChangeNotAllowed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+	case errors.Is(err, services.ErrReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrSubscriptionInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error("Failed to change subscription", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change subscription"})
+	}
+}
@@ -0,0 +1,117 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timam/uttarawave-backend/api/response"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const ClaimsKey = "claims"
+
+// RoutePermissions maps "METHOD /full/path" to the roles allowed to call it
+type RoutePermissions map[string][]models.EmployeeRole
+
+func abortWithError(c *gin.Context, statusCode int, message string, err string) {
+	c.AbortWithStatusJSON(statusCode, response.ErrorResponse{
+		Status:  statusCode,
+		Message: message,
+		Error:   err,
+	})
+}
+
+// AuthMiddleware requires a valid bearer token and attaches its claims to the request
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			abortWithError(c, http.StatusUnauthorized, "Unauthorized", "missing bearer token")
+			return
+		}
+
+		claims, err := auth.ParseToken(tokenString)
+		if err != nil {
+			logger.Warn("Rejected token", zap.Error(err), zap.String("path", c.Request.URL.Path))
+			abortWithError(c, http.StatusUnauthorized, "Unauthorized", "invalid or expired token")
+			return
+		}
+
+		c.Set(ClaimsKey, claims)
+		c.Request = c.Request.WithContext(auth.WithClaims(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+// EmployeeLookup loads the employee a token was issued to
+type EmployeeLookup interface {
+	GetEmployeeByID(ctx context.Context, id string) (*models.Employee, error)
+}
+
+// PermissionMiddleware enforces the permission matrix; routes missing from it are denied. Tokens
+// outlive changes to the account, so once the token's role is allowed the employee is loaded and
+// must still be active and hold that role.
+func PermissionMiddleware(permissions RoutePermissions, employees EmployeeLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := auth.ClaimsFromContext(c.Request.Context())
+		if !ok {
+			abortWithError(c, http.StatusUnauthorized, "Unauthorized", "missing credentials")
+			return
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		roles, ok := permissions[route]
+		if !ok {
+			logger.Error("No permission policy for route", zap.String("route", route))
+			abortWithError(c, http.StatusForbidden, "Forbidden", "you don't have permission to perform this action")
+			return
+		}
+
+		for _, role := range roles {
+			if string(role) == claims.Role {
+				if authorizeEmployee(c, employees, claims) {
+					c.Next()
+				}
+				return
+			}
+		}
+
+		logger.Warn("Permission denied",
+			zap.String("route", route),
+			zap.String("employeeID", claims.EmployeeID),
+			zap.String("role", claims.Role),
+		)
+		abortWithError(c, http.StatusForbidden, "Forbidden", "you don't have permission to perform this action")
+	}
+}
+
+// authorizeEmployee rejects tokens of employees who were deleted, deactivated or given another
+// role since the token was issued; they have to log in again
+func authorizeEmployee(c *gin.Context, employees EmployeeLookup, claims *auth.Claims) bool {
+	employee, err := employees.GetEmployeeByID(c.Request.Context(), claims.EmployeeID)
+	if err != nil {
+		logger.Error("Failed to load employee for token", zap.Error(err), zap.String("employeeID", claims.EmployeeID))
+		abortWithError(c, http.StatusInternalServerError, "Failed to authorize request", err.Error())
+		return false
+	}
+
+	switch {
+	case employee == nil:
+		abortWithError(c, http.StatusUnauthorized, "Unauthorized", "the employee account no longer exists")
+	case !employee.IsActive:
+		abortWithError(c, http.StatusUnauthorized, "Unauthorized", "this employee account has been deactivated")
+	case string(employee.Role) != claims.Role:
+		abortWithError(c, http.StatusUnauthorized, "Unauthorized", "the account's role has changed, log in again")
+	default:
+		return true
+	}
+
+	logger.Warn("Rejected token of changed account", zap.String("employeeID", claims.EmployeeID), zap.String("role", claims.Role))
+	return false
+}
@@ -2,6 +2,7 @@ package response
 
 import (
 	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
 	"time"
 )
 
@@ -79,3 +80,46 @@ func NewDeviceListResponse(devices []models.Device, total int64, page, size int)
 		},
 	}
 }
+
+type DeviceCollectionGroup struct {
+	Key     string                                 `json:"key"`
+	Label   string                                 `json:"label"`
+	Count   int                                    `json:"count"`
+	Devices []repositories.PendingCollectionDevice `json:"devices"`
+}
+
+// NewDeviceCollectionGroups groups devices awaiting collection by building or area, preserving first-seen order
+func NewDeviceCollectionGroups(devices []repositories.PendingCollectionDevice, groupBy string) []DeviceCollectionGroup {
+	groups := []DeviceCollectionGroup{}
+	index := make(map[string]int)
+
+	for _, device := range devices {
+		key, label := "unknown", "Unknown"
+		switch groupBy {
+		case "building":
+			if device.LocationBuilding != nil {
+				key = *device.LocationBuilding
+				label = key
+				if device.BuildingName != nil {
+					label = *device.BuildingName
+				}
+			}
+		default:
+			if device.Area != nil && *device.Area != "" {
+				key = *device.Area
+				label = *device.Area
+			}
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, DeviceCollectionGroup{Key: key, Label: label})
+		}
+		groups[i].Devices = append(groups[i].Devices, device)
+		groups[i].Count++
+	}
+
+	return groups
+}
@@ -2,6 +2,7 @@ package response
 
 import (
 	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
 	"time"
 )
 
@@ -20,7 +21,8 @@ type TVPackageResponse struct {
 	ID           string             `json:"id"`
 	Type         models.PackageType `json:"type"`
 	Name         string             `json:"name"`
-	Price        float64            `json:"price"`
+	Price        money.Amount       `json:"price"`
+	Currency     money.Currency     `json:"currency"`
 	IsActive     bool               `json:"isActive"`
 	ChannelCount int                `json:"channelCount"`
 	TVCount      int                `json:"tvCount"`
@@ -32,7 +34,8 @@ type InternetPackageResponse struct {
 	ID            string             `json:"id"`
 	Type          models.PackageType `json:"type"`
 	Name          string             `json:"name"`
-	Price         float64            `json:"price"`
+	Price         money.Amount       `json:"price"`
+	Currency      money.Currency     `json:"currency"`
 	IsActive      bool               `json:"isActive"`
 	Bandwidth     int                `json:"bandwidth"`
 	BandwidthType string             `json:"bandwidthType"`
@@ -65,6 +68,7 @@ func NewTVPackageResponse(pkg *models.Package) TVPackageResponse {
 		Type:         pkg.Type,
 		Name:         pkg.Name,
 		Price:        pkg.Price,
+		Currency:     pkg.Currency,
 		IsActive:     pkg.IsActive,
 		ChannelCount: derefInt(pkg.ChannelCount),
 		TVCount:      derefInt(pkg.TVCount),
@@ -79,6 +83,7 @@ func NewInternetPackageResponse(pkg *models.Package) InternetPackageResponse {
 		Type:          pkg.Type,
 		Name:          pkg.Name,
 		Price:         pkg.Price,
+		Currency:      pkg.Currency,
 		IsActive:      pkg.IsActive,
 		Bandwidth:     derefInt(pkg.Bandwidth),
 		BandwidthType: derefBandwidthType(pkg.BandwidthType),
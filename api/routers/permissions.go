@@ -0,0 +1,104 @@
+package routers
+
+import (
+	middlewares "github.com/timam/uttarawave-backend/api/middlewares"
+	"github.com/timam/uttarawave-backend/internals/models"
+)
+
+var (
+	adminOnly = []models.EmployeeRole{models.RoleAdmin}
+	allRoles  = []models.EmployeeRole{models.RoleAdmin, models.RoleEmployee}
+)
+
+// routePermissions is the permission matrix for every authenticated /api/v1 route.
+// Routes that are not listed here are denied.
+var routePermissions = middlewares.RoutePermissions{
+	"GET /api/v1/auth/me": allRoles,
+
+	"POST /api/v1/packages":       adminOnly,
+	"GET /api/v1/packages":        allRoles,
+	"GET /api/v1/packages/:id":    allRoles,
+	"DELETE /api/v1/packages/:id": adminOnly,
+
+	"POST /api/v1/buildings":       allRoles,
+	"GET /api/v1/buildings":        allRoles,
+	"GET /api/v1/buildings/:id":    allRoles,
+	"PATCH /api/v1/buildings/:id":  allRoles,
+	"DELETE /api/v1/buildings/:id": adminOnly,
+
+	"POST /api/v1/devices":                             allRoles,
+	"GET /api/v1/devices":                              allRoles,
+	"POST /api/v1/devices/import":                      adminOnly,
+	"GET /api/v1/devices/:id":                          allRoles,
+	"GET /api/v1/devices/:id/history":                  allRoles,
+	"PUT /api/v1/devices/:id":                          allRoles,
+	"POST /api/v1/devices/:id/assign":                  allRoles,
+	"POST /api/v1/devices/:id/unassign":                allRoles,
+	"POST /api/v1/devices/:id/status":                  allRoles,
+	"POST /api/v1/devices/:id/write-off":               adminOnly,
+	"GET /api/v1/devices/:id/repairs":                  allRoles,
+	"POST /api/v1/devices/:id/repairs":                 allRoles,
+	"POST /api/v1/devices/:id/repairs/:ticketId/close": adminOnly,
+	"DELETE /api/v1/devices/:id":                       allRoles,
+	"GET /api/v1/devices/by-assignment":                allRoles,
+	"GET /api/v1/devices/pending-collection":           allRoles,
+	"GET /api/v1/devices/repairs":                      allRoles,
+	"GET /api/v1/devices/reports/inventory":            adminOnly,
+	"GET /api/v1/devices/reports/depreciation":         adminOnly,
+
+	"POST /api/v1/subscriptions":                    allRoles,
+	"GET /api/v1/subscriptions/:id":                 allRoles,
+	"PUT /api/v1/subscriptions/:id":                 allRoles,
+	"DELETE /api/v1/subscriptions/:id":              allRoles,
+	"GET /api/v1/subscriptions":                     allRoles,
+	"POST /api/v1/subscriptions/:id/activate":       allRoles,
+	"POST /api/v1/subscriptions/:id/suspend":        allRoles,
+	"POST /api/v1/subscriptions/:id/resume":         allRoles,
+	"POST /api/v1/subscriptions/:id/cancel":         adminOnly,
+	"POST /api/v1/subscriptions/:id/reactivate":     adminOnly,
+	"GET /api/v1/subscriptions/:id/status-history":  allRoles,
+	"POST /api/v1/subscriptions/:id/change-package": allRoles,
+	"GET /api/v1/subscriptions/:id/package-history": allRoles,
+	"POST /api/v1/subscriptions/:id/hold":           allRoles,
+	"POST /api/v1/subscriptions/:id/hold/end":       allRoles,
+	"GET /api/v1/subscriptions/:id/holds":           allRoles,
+
+	"POST /api/v1/customers":              allRoles,
+	"GET /api/v1/customers":               allRoles,
+	"PUT /api/v1/customers/:id":           allRoles,
+	"DELETE /api/v1/customers":            allRoles,
+	"GET /api/v1/customers/all":           allRoles,
+	"GET /api/v1/customers/:id/statement": allRoles,
+
+	"POST /api/v1/payments":                            allRoles,
+	"POST /api/v1/payments/:id/reverse":                adminOnly,
+	"GET /api/v1/payments/:id/receipt":                 allRoles,
+	"POST /api/v1/payments/checkout":                   allRoles,
+	"GET /api/v1/payments/gateway-transactions/:id":    allRoles,
+	"POST /api/v1/payments/gateways/:gateway/simulate": adminOnly,
+
+	"POST /api/v1/invoices":            allRoles,
+	"GET /api/v1/invoices":             allRoles,
+	"GET /api/v1/invoices/:id":         allRoles,
+	"GET /api/v1/invoices/render":      allRoles,
+	"GET /api/v1/invoices/:id/render":  allRoles,
+	"PUT /api/v1/invoices/:id":         adminOnly,
+	"POST /api/v1/invoices/:id/cancel": adminOnly,
+	"POST /api/v1/invoices/:id/void":   adminOnly,
+
+	"POST /api/v1/expenses": adminOnly,
+
+	"POST /api/v1/employees":                   adminOnly,
+	"GET /api/v1/employees":                    adminOnly,
+	"GET /api/v1/employees/:id":                adminOnly,
+	"PUT /api/v1/employees/:id":                adminOnly,
+	"DELETE /api/v1/employees/:id":             adminOnly,
+	"POST /api/v1/employees/:id/activate":      adminOnly,
+	"POST /api/v1/employees/:id/deactivate":    adminOnly,
+	"PUT /api/v1/employees/:id/role":           adminOnly,
+	"PUT /api/v1/employees/:id/salary":         adminOnly,
+	"GET /api/v1/employees/:id/salary-history": adminOnly,
+	"GET /api/v1/employees/:id/payouts":        adminOnly,
+	"POST /api/v1/employees/:id/payouts":       adminOnly,
+	"POST /api/v1/payroll/run":                 adminOnly,
+}
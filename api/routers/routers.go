@@ -5,9 +5,13 @@ import (
 	"github.com/spf13/viper"
 	handlers2 "github.com/timam/uttarawave-backend/api/handlers"
 	middlewares "github.com/timam/uttarawave-backend/api/middlewares"
+	"github.com/timam/uttarawave-backend/internals/documents"
 	repositories "github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
+	"github.com/timam/uttarawave-backend/pkg/gateway"
 	"github.com/timam/uttarawave-backend/pkg/logger"
 	"github.com/timam/uttarawave-backend/pkg/metrics"
+	"go.uber.org/zap"
 )
 
 func InitRouter() *gin.Engine {
@@ -29,7 +33,37 @@ func InitRouter() *gin.Engine {
 	logger.Info("Initializing router")
 	router.GET("/metrics", metrics.MetricsHandler())
 
-	apiV1 := router.Group("/api/v1")
+	employeeRepo := repositories.NewGormEmployeeRepository()
+	authHandler := handlers2.NewAuthHandler(employeeRepo)
+
+	gateways, err := gateway.LoadGateways()
+	if err != nil {
+		logger.Fatal("Failed to load payment gateways", zap.Error(err))
+	}
+	renderer, err := documents.NewRendererFromConfig()
+	if err != nil {
+		logger.Fatal("Failed to load document templates", zap.Error(err))
+	}
+
+	invoiceRepo := repositories.NewGormInvoiceRepository()
+	subscriptionRepo := repositories.NewGormSubscriptionRepository()
+	customerRepo := repositories.NewGormCustomerRepository()
+	paymentRepo := repositories.NewGormPaymentRepository()
+	creditRepo := repositories.NewGormCustomerCreditRepository()
+	ledgerRepo := repositories.NewGormLedgerRepository()
+	paymentService := services.NewPaymentService(paymentRepo, invoiceRepo, subscriptionRepo, creditRepo, ledgerRepo)
+	gatewayService := services.NewGatewayService(gateways, repositories.NewGormGatewayTransactionRepository(), invoiceRepo, customerRepo, paymentService)
+	gatewayHandler := handlers2.NewGatewayHandler(gatewayService)
+
+	publicV1 := router.Group("/api/v1")
+	{
+		publicV1.POST("/auth/login", authHandler.Login())
+		// Gateway callbacks are authenticated by their signature rather than a token
+		publicV1.POST("/payments/gateways/:gateway/webhook", gatewayHandler.Webhook())
+	}
+
+	apiV1 := router.Group("/api/v1", middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(routePermissions, employeeRepo))
+	apiV1.GET("/auth/me", authHandler.Me())
 
 	packageRepo := repositories.NewGormPackageRepository()
 	packageHandler := handlers2.NewPackageHandler(packageRepo)
@@ -52,24 +86,45 @@ func InitRouter() *gin.Engine {
 		buildingRoutes.DELETE("/:id", buildingHandler.DeleteBuilding())
 	}
 
+	usefulLives, err := services.LoadUsefulLives()
+	if err != nil {
+		logger.Fatal("Invalid depreciation configuration", zap.Error(err))
+	}
+	expenseRepo := repositories.NewGormExpenseRepository()
 	deviceRepo := repositories.NewGormDeviceRepository()
-	deviceHandler := handlers2.NewDeviceHandler(deviceRepo)
+	deviceService := services.NewDeviceService(deviceRepo, subscriptionRepo, buildingRepo, expenseRepo, usefulLives)
+	deviceHandler := handlers2.NewDeviceHandler(deviceRepo, deviceService)
 	deviceRoutes := apiV1.Group("/devices")
 	{
 		deviceRoutes.POST("", deviceHandler.CreateDevice())
 		deviceRoutes.GET("", deviceHandler.GetAllDevices())
+		deviceRoutes.POST("/import", deviceHandler.ImportDevices())
 		deviceRoutes.GET("/:id", deviceHandler.GetDevice())
+		deviceRoutes.GET("/:id/history", deviceHandler.GetDeviceHistory())
 		deviceRoutes.PUT("/:id", deviceHandler.UpdateDevice())
 		deviceRoutes.POST("/:id/assign", deviceHandler.AssignDevice())
 		deviceRoutes.POST("/:id/unassign", deviceHandler.UnassignDevice())
+		deviceRoutes.POST("/:id/status", deviceHandler.MarkDeviceStatus())
+		deviceRoutes.POST("/:id/write-off", deviceHandler.WriteOffDevice())
+		deviceRoutes.GET("/:id/repairs", deviceHandler.GetDeviceRepairs())
+		deviceRoutes.POST("/:id/repairs", deviceHandler.OpenRepair())
+		deviceRoutes.POST("/:id/repairs/:ticketId/close", deviceHandler.CloseRepair())
 		deviceRoutes.DELETE("/:id", deviceHandler.DeleteDevice())
 		deviceRoutes.GET("/by-assignment", deviceHandler.GetDeviceByAssignment())
+		deviceRoutes.GET("/pending-collection", deviceHandler.GetDevicesPendingCollection())
+		deviceRoutes.GET("/repairs", deviceHandler.GetRepairs())
+		deviceRoutes.GET("/reports/inventory", deviceHandler.GetInventoryReport())
+		deviceRoutes.GET("/reports/depreciation", deviceHandler.GetDepreciationReport())
 	}
 
-	invoiceRepo := repositories.NewGormInvoiceRepository()
-
-	subscriptionRepo := repositories.NewGormSubscriptionRepository()
-	subscriptionHandler := handlers2.NewSubscriptionHandler(subscriptionRepo, packageRepo, deviceRepo, invoiceRepo)
+	subscriptionService := services.NewSubscriptionService(subscriptionRepo, packageRepo, customerRepo, deviceRepo, invoiceRepo, creditRepo, ledgerRepo)
+	packageChangeService := services.NewPackageChangeService(subscriptionRepo, packageRepo, invoiceRepo, creditRepo, ledgerRepo)
+	holdFee, err := services.DefaultHoldFee()
+	if err != nil {
+		logger.Fatal("Invalid hold configuration", zap.Error(err))
+	}
+	holdService := services.NewHoldService(subscriptionRepo, invoiceRepo, creditRepo, ledgerRepo, holdFee)
+	subscriptionHandler := handlers2.NewSubscriptionHandler(subscriptionRepo, packageRepo, deviceRepo, invoiceRepo, subscriptionService, packageChangeService, holdService)
 	subscriptionRoutes := apiV1.Group("/subscriptions")
 	{
 		subscriptionRoutes.POST("", subscriptionHandler.CreateSubscription())
@@ -77,10 +132,21 @@ func InitRouter() *gin.Engine {
 		subscriptionRoutes.PUT("/:id", subscriptionHandler.UpdateSubscription())
 		subscriptionRoutes.DELETE("/:id", subscriptionHandler.DeleteSubscription())
 		subscriptionRoutes.GET("", subscriptionHandler.GetAllSubscriptions())
+		subscriptionRoutes.POST("/:id/activate", subscriptionHandler.ActivateSubscription())
+		subscriptionRoutes.POST("/:id/suspend", subscriptionHandler.SuspendSubscription())
+		subscriptionRoutes.POST("/:id/resume", subscriptionHandler.ResumeSubscription())
+		subscriptionRoutes.POST("/:id/cancel", subscriptionHandler.CancelSubscription())
+		subscriptionRoutes.POST("/:id/reactivate", subscriptionHandler.ReactivateSubscription())
+		subscriptionRoutes.GET("/:id/status-history", subscriptionHandler.GetStatusHistory())
+		subscriptionRoutes.POST("/:id/change-package", subscriptionHandler.ChangePackage())
+		subscriptionRoutes.GET("/:id/package-history", subscriptionHandler.GetPackageHistory())
+		subscriptionRoutes.POST("/:id/hold", subscriptionHandler.PlaceHold())
+		subscriptionRoutes.POST("/:id/hold/end", subscriptionHandler.EndHold())
+		subscriptionRoutes.GET("/:id/holds", subscriptionHandler.GetHolds())
 	}
 
-	customerRepo := repositories.NewGormCustomerRepository()
-	customerHandler := handlers2.NewCustomerHandler(customerRepo, buildingRepo)
+	ledgerService := services.NewLedgerService(ledgerRepo)
+	customerHandler := handlers2.NewCustomerHandler(customerRepo, buildingRepo, ledgerService)
 	customerRoutes := apiV1.Group("/customers")
 	{
 		customerRoutes.POST("", customerHandler.CreateCustomer())
@@ -88,27 +154,37 @@ func InitRouter() *gin.Engine {
 		customerRoutes.PUT("/:id", customerHandler.UpdateCustomer())
 		customerRoutes.DELETE("", customerHandler.DeleteCustomer())
 		customerRoutes.GET("/all", customerHandler.GetAllCustomers())
+		customerRoutes.GET("/:id/statement", customerHandler.GetStatement())
 	}
 
-	paymentRepo := repositories.NewGormPaymentRepository()
-	paymentHandler := handlers2.NewPaymentHandler(paymentRepo, subscriptionRepo, invoiceRepo)
-	invoiceHandler := handlers2.NewInvoiceHandler(invoiceRepo, subscriptionRepo)
+	documentService := services.NewDocumentService(paymentRepo, invoiceRepo, subscriptionRepo, packageRepo, customerRepo, creditRepo, buildingRepo)
+	paymentHandler := handlers2.NewPaymentHandler(paymentService, documentService, renderer)
+	invoiceService := services.NewInvoiceService(invoiceRepo, paymentRepo, subscriptionRepo, packageRepo, creditRepo, ledgerRepo)
+	invoiceHandler := handlers2.NewInvoiceHandler(invoiceRepo, invoiceService, documentService, renderer)
 
 	paymentRoutes := apiV1.Group("/payments")
 	{
 		paymentRoutes.POST("", paymentHandler.CreatePayment())
+		paymentRoutes.POST("/:id/reverse", paymentHandler.ReversePayment())
+		paymentRoutes.GET("/:id/receipt", paymentHandler.GetReceipt())
+		paymentRoutes.POST("/checkout", gatewayHandler.CreateCheckout())
+		paymentRoutes.GET("/gateway-transactions/:id", gatewayHandler.GetTransaction())
+		paymentRoutes.POST("/gateways/:gateway/simulate", gatewayHandler.Simulate())
 		// Add other payment routes here
 	}
 
 	invoiceRoutes := apiV1.Group("/invoices")
 	{
 		invoiceRoutes.POST("", invoiceHandler.CreateInvoice())
-		//invoiceRoutes.GET("/:id", invoiceHandler.GetInvoice())
-		//invoiceRoutes.PUT("/:id", invoiceHandler.UpdateInvoice())
-		//invoiceRoutes.GET("", invoiceHandler.GetAllInvoices())
+		invoiceRoutes.GET("/:id", invoiceHandler.GetInvoice())
+		invoiceRoutes.PUT("/:id", invoiceHandler.UpdateInvoice())
+		invoiceRoutes.GET("", invoiceHandler.GetAllInvoices())
+		invoiceRoutes.GET("/render", invoiceHandler.RenderBuildingInvoices())
+		invoiceRoutes.GET("/:id/render", invoiceHandler.RenderInvoice())
+		invoiceRoutes.POST("/:id/cancel", invoiceHandler.CancelInvoice())
+		invoiceRoutes.POST("/:id/void", invoiceHandler.VoidInvoice())
 	}
 
-	expenseRepo := repositories.NewGormExpenseRepository()
 	expenseHandler := handlers2.NewExpenseHandler(expenseRepo)
 
 	expenseRoutes := apiV1.Group("/expenses")
@@ -117,6 +193,29 @@ func InitRouter() *gin.Engine {
 		// Add other expense routes here
 	}
 
+	payrollService := services.NewPayrollService(employeeRepo)
+	employeeHandler := handlers2.NewEmployeeHandler(employeeRepo, payrollService)
+	employeeRoutes := apiV1.Group("/employees")
+	{
+		employeeRoutes.POST("", employeeHandler.CreateEmployee())
+		employeeRoutes.GET("", employeeHandler.GetAllEmployees())
+		employeeRoutes.GET("/:id", employeeHandler.GetEmployee())
+		employeeRoutes.PUT("/:id", employeeHandler.UpdateEmployee())
+		employeeRoutes.DELETE("/:id", employeeHandler.DeleteEmployee())
+		employeeRoutes.POST("/:id/activate", employeeHandler.ActivateEmployee())
+		employeeRoutes.POST("/:id/deactivate", employeeHandler.DeactivateEmployee())
+		employeeRoutes.PUT("/:id/role", employeeHandler.AssignRole())
+		employeeRoutes.PUT("/:id/salary", employeeHandler.UpdateSalary())
+		employeeRoutes.GET("/:id/salary-history", employeeHandler.GetSalaryHistory())
+		employeeRoutes.GET("/:id/payouts", employeeHandler.GetSalaryPayouts())
+		employeeRoutes.POST("/:id/payouts", employeeHandler.PaySalary())
+	}
+
+	payrollRoutes := apiV1.Group("/payroll")
+	{
+		payrollRoutes.POST("/run", employeeHandler.RunPayroll())
+	}
+
 	logger.Info("Router initialized successfully")
 	return router
 }
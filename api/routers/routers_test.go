@@ -1,12 +1,22 @@
 package routers
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	middlewares "github.com/timam/uttarawave-backend/api/middlewares"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/metrics"
+	"go.uber.org/zap"
 )
 
 // setupRouter initializes the Gin router and sets up routes for testing.
@@ -48,3 +58,118 @@ func TestInitRouter(t *testing.T) {
 		})
 	}
 }
+
+var metricsOnce sync.Once
+
+func initTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	logger.SetLogger(zap.NewNop())
+	metricsOnce.Do(func() {
+		assert.NoError(t, metrics.InitializeMetrics())
+	})
+	viper.Set("auth.jwt.secret", "test-secret")
+	t.Cleanup(func() { viper.Set("auth.jwt.secret", "") })
+	return InitRouter()
+}
+
+// TestRoutePermissionsCoverAllRoutes ensures every authenticated route has an entry in the permission matrix.
+func TestRoutePermissionsCoverAllRoutes(t *testing.T) {
+	router := initTestRouter(t)
+
+	public := map[string]bool{
+		"POST /api/v1/auth/login":                         true,
+		"POST /api/v1/payments/gateways/:gateway/webhook": true,
+	}
+
+	for _, route := range router.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/v1") {
+			continue
+		}
+		key := route.Method + " " + route.Path
+		if public[key] {
+			continue
+		}
+		_, ok := routePermissions[key]
+		assert.True(t, ok, "route %s has no permission policy", key)
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	router := initTestRouter(t)
+
+	employeeToken, _, err := auth.GenerateToken("employee-id", "Employee", string(models.RoleEmployee))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		description    string
+		method         string
+		path           string
+		token          string
+		expectedStatus int
+	}{
+		{"MissingToken", "GET", "/api/v1/packages", "", http.StatusUnauthorized},
+		{"InvalidToken", "GET", "/api/v1/packages", "not-a-token", http.StatusUnauthorized},
+		{"EmployeeCreatesPackage", "POST", "/api/v1/packages", employeeToken, http.StatusForbidden},
+		{"EmployeeDeletesBuilding", "DELETE", "/api/v1/buildings/some-id", employeeToken, http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+type employeeLookupFunc func(id string) (*models.Employee, error)
+
+func (f employeeLookupFunc) GetEmployeeByID(_ context.Context, id string) (*models.Employee, error) {
+	return f(id)
+}
+
+// TestPermissionsFollowEmployeeChanges ensures tokens stop working once their employee is deactivated or has another role
+func TestPermissionsFollowEmployeeChanges(t *testing.T) {
+	logger.SetLogger(zap.NewNop())
+	viper.Set("auth.jwt.secret", "test-secret")
+	t.Cleanup(func() { viper.Set("auth.jwt.secret", "") })
+
+	employees := map[string]*models.Employee{
+		"active":   {ID: "active", Role: models.RoleAdmin, IsActive: true},
+		"inactive": {ID: "inactive", Role: models.RoleAdmin, IsActive: false},
+		"demoted":  {ID: "demoted", Role: models.RoleEmployee, IsActive: true},
+	}
+	lookup := employeeLookupFunc(func(id string) (*models.Employee, error) { return employees[id], nil })
+
+	router := gin.New()
+	permissions := middlewares.RoutePermissions{"DELETE /api/v1/packages/:id": adminOnly}
+	router.DELETE("/api/v1/packages/:id", middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permissions, lookup), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	testCases := []struct {
+		employeeID     string
+		expectedStatus int
+	}{
+		{"active", http.StatusNoContent},
+		{"inactive", http.StatusUnauthorized},
+		{"demoted", http.StatusUnauthorized},
+		{"deleted", http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.employeeID, func(t *testing.T) {
+			token, _, err := auth.GenerateToken(tc.employeeID, tc.employeeID, string(models.RoleAdmin))
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", "/api/v1/packages/some-id", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/timam/uttarawave-backend/internals/documents"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var billingPeriod string
+
+var (
+	printBuilding    string
+	printFormat      string
+	printLanguage    string
+	printOutput      string
+	printIncludePaid bool
+)
+
+var billingCmd = &cobra.Command{
+	Use:   "billing",
+	Short: "Billing operations for uttarawave backend server",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var billingRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Generate invoices for subscriptions renewing in a billing period",
+	Long:  `This command creates one consolidated invoice per customer covering their active subscriptions whose renewal date falls in the given period. Running it again for the same period does not create duplicates.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		period := services.NewBillingPeriod(time.Now())
+		if billingPeriod != "" {
+			var err error
+			period, err = services.ParseBillingPeriod(billingPeriod)
+			if err != nil {
+				logger.Fatal("Invalid billing period", zap.Error(err))
+			}
+		}
+
+		billingService := services.NewBillingService(
+			repositories.NewGormSubscriptionRepository(),
+			repositories.NewGormInvoiceRepository(),
+			repositories.NewGormPackageRepository(),
+			repositories.NewGormCustomerCreditRepository(),
+			repositories.NewGormLedgerRepository(),
+		)
+
+		result, err := billingService.RunBilling(context.Background(), period)
+		if result != nil {
+			fmt.Printf("Billing period %s: %d created, %d already invoiced, %d failed\n", result.Period, result.Created, result.Skipped, result.Failed)
+		}
+		if err != nil {
+			logger.Fatal("Billing run failed", zap.Error(err))
+		}
+	},
+}
+
+var billingOverdueCmd = &cobra.Command{
+	Use:   "overdue",
+	Short: "Mark unpaid invoices past their due date as overdue and apply late fees",
+	Long:  `This command marks pending invoices past their due date plus the configured grace period as overdue and adds late fee lines according to billing.late_fee.policies.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		policies, err := services.LoadLateFeePolicies()
+		if err != nil {
+			logger.Fatal("Invalid late fee configuration", zap.Error(err))
+		}
+
+		overdueService := services.NewOverdueService(
+			repositories.NewGormInvoiceRepository(),
+			repositories.NewGormSubscriptionRepository(),
+			repositories.NewGormPackageRepository(),
+			repositories.NewGormLedgerRepository(),
+			policies,
+			services.LateFeeGracePeriod(),
+		)
+
+		result, err := overdueService.ProcessOverdueInvoices(context.Background(), time.Now())
+		if result != nil {
+			fmt.Printf("Marked %d invoice(s) overdue, %d late fee(s) totalling %s applied, %d failed\n", result.Overdue, result.FeesApplied, result.FeesTotal, result.Failed)
+		}
+		if err != nil {
+			logger.Fatal("Overdue run failed", zap.Error(err))
+		}
+	},
+}
+
+var billingPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Render a building's invoices for a billing period as one printable file",
+	Long:  `This command renders the invoices of every customer in a building for a billing period into one HTML or PDF file, one invoice per page in flat order, for door-to-door collection.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		period := services.NewBillingPeriod(time.Now())
+		if billingPeriod != "" {
+			var err error
+			period, err = services.ParseBillingPeriod(billingPeriod)
+			if err != nil {
+				logger.Fatal("Invalid billing period", zap.Error(err))
+			}
+		}
+		format, err := documents.ParseFormat(printFormat)
+		if err != nil {
+			logger.Fatal("Invalid format", zap.Error(err))
+		}
+		language, err := documents.ParseLanguage(printLanguage)
+		if err != nil {
+			logger.Fatal("Invalid language", zap.Error(err))
+		}
+		renderer, err := documents.NewRendererFromConfig()
+		if err != nil {
+			logger.Fatal("Invalid documents configuration", zap.Error(err))
+		}
+
+		documentService := services.NewDocumentService(
+			repositories.NewGormPaymentRepository(),
+			repositories.NewGormInvoiceRepository(),
+			repositories.NewGormSubscriptionRepository(),
+			repositories.NewGormPackageRepository(),
+			repositories.NewGormCustomerRepository(),
+			repositories.NewGormCustomerCreditRepository(),
+			repositories.NewGormBuildingRepository(),
+		)
+
+		ctx := context.Background()
+		invoices, err := documentService.GetBuildingInvoices(ctx, printBuilding, period.String(), printIncludePaid)
+		if err != nil {
+			logger.Fatal("Failed to get building invoices", zap.Error(err))
+		}
+		document, err := renderer.Render(ctx, documents.Invoices, format, language, invoices)
+		if err != nil {
+			logger.Fatal("Failed to render invoices", zap.Error(err))
+		}
+
+		output := printOutput
+		if output == "" {
+			output = fmt.Sprintf("invoices-%s.%s", period, format)
+		}
+		if err := os.WriteFile(output, document, 0o644); err != nil {
+			logger.Fatal("Failed to write invoices", zap.Error(err))
+		}
+		fmt.Printf("Wrote %d invoice(s) for %s, %s to %s\n", len(invoices.Invoices), invoices.Building, period, output)
+	},
+}
+
+func init() {
+	billingRunCmd.Flags().StringVar(&billingPeriod, "period", "", "billing period in YYYY-MM form (defaults to the current month)")
+	billingCmd.AddCommand(billingRunCmd)
+	billingCmd.AddCommand(billingOverdueCmd)
+
+	billingPrintCmd.Flags().StringVar(&billingPeriod, "period", "", "billing period in YYYY-MM form (defaults to the current month)")
+	billingPrintCmd.Flags().StringVar(&printBuilding, "building", "", "ID of the building to print")
+	billingPrintCmd.Flags().StringVar(&printFormat, "format", "html", "output format, html or pdf")
+	billingPrintCmd.Flags().StringVar(&printLanguage, "lang", "", "document language, en or bn (defaults to documents.language)")
+	billingPrintCmd.Flags().StringVar(&printOutput, "output", "", "file to write (defaults to invoices-<period>.<format>)")
+	billingPrintCmd.Flags().BoolVar(&printIncludePaid, "include-paid", false, "also print invoices that are already paid")
+	billingPrintCmd.MarkFlagRequired("building")
+	billingCmd.AddCommand(billingPrintCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var importDryRun bool
+
+var deviceCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "Device inventory operations for uttarawave backend server",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var deviceImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Take a shipment of devices into stock from a CSV or XLSX file",
+	Long:  `This command reads devices from a CSV or XLSX file with type, brand, model and serial columns, and optional purchase price and purchase date columns. Every row is checked before anything is saved, including serial numbers repeated in the file or already in the inventory; the devices are only added if all rows are valid.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			logger.Fatal("Failed to read import file", zap.Error(err))
+		}
+
+		deviceService := services.NewDeviceService(
+			repositories.NewGormDeviceRepository(),
+			repositories.NewGormSubscriptionRepository(),
+			repositories.NewGormBuildingRepository(),
+			repositories.NewGormExpenseRepository(),
+			nil,
+		)
+
+		result, err := deviceService.ImportDevices(context.Background(), args[0], data, importDryRun)
+		if err != nil {
+			logger.Fatal("Device import failed", zap.Error(err))
+		}
+
+		for _, rowError := range result.Errors {
+			for _, fieldError := range rowError.Errors {
+				fmt.Printf("Row %d: %s %s\n", rowError.Row, fieldError.Field, fieldError.Message)
+			}
+		}
+		switch {
+		case !result.Valid():
+			fmt.Printf("%d of %d row(s) are invalid, no devices were imported\n", len(result.Errors), result.Rows)
+			os.Exit(1)
+		case result.DryRun:
+			fmt.Printf("All %d row(s) are valid, nothing was imported (dry run)\n", result.Rows)
+		default:
+			fmt.Printf("Imported %d device(s)\n", result.Imported)
+		}
+	},
+}
+
+func init() {
+	deviceImportCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "only check the file, without importing anything")
+	deviceCmd.AddCommand(deviceImportCmd)
+}
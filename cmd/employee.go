@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	employeeName     string
+	employeeMobile   string
+	employeePassword string
+	employeeRole     string
+)
+
+var employeeCmd = &cobra.Command{
+	Use:   "employees",
+	Short: "Employee account operations for uttarawave backend server",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var employeeCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an employee login, e.g. to bootstrap the first admin",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		role := models.EmployeeRole(employeeRole)
+		if role != models.RoleAdmin && role != models.RoleEmployee {
+			logger.Fatal("Invalid role, must be Admin or Employee", zap.String("role", employeeRole))
+		}
+
+		hash, err := auth.HashPassword(employeePassword)
+		if err != nil {
+			logger.Fatal("Invalid password", zap.Error(err))
+		}
+
+		employee := models.Employee{
+			ID:           uuid.New().String(),
+			Name:         employeeName,
+			Mobile:       employeeMobile,
+			PasswordHash: hash,
+			Role:         role,
+			IsActive:     true,
+			JoinDate:     time.Now(),
+		}
+
+		if err := repositories.NewGormEmployeeRepository().CreateEmployee(context.Background(), &employee); err != nil {
+			logger.Fatal("Failed to create employee", zap.Error(err))
+		}
+
+		fmt.Printf("Created %s %s (%s)\n", employee.Role, employee.Name, employee.ID)
+	},
+}
+
+func init() {
+	employeeCreateCmd.Flags().StringVar(&employeeName, "name", "", "employee name")
+	employeeCreateCmd.Flags().StringVar(&employeeMobile, "mobile", "", "mobile number used to log in")
+	employeeCreateCmd.Flags().StringVar(&employeePassword, "password", "", "initial password (at least 8 characters)")
+	employeeCreateCmd.Flags().StringVar(&employeeRole, "role", string(models.RoleAdmin), "Admin or Employee")
+	employeeCreateCmd.MarkFlagRequired("name")
+	employeeCreateCmd.MarkFlagRequired("mobile")
+	employeeCreateCmd.MarkFlagRequired("password")
+	employeeCmd.AddCommand(employeeCreateCmd)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/timam/uttarawave-backend/internals/migrations"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manage database migrations for uttarawave backend server",
+	Long:  `This command applies, rolls back and inspects the versioned database migrations of uttarawave backend server.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply all pending migrations",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := migrations.Up(context.Background()); err != nil {
+			logger.Fatal("Failed to apply migrations", zap.Error(err))
+		}
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down N",
+	Short: "Roll back the last N applied migrations",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		steps, err := strconv.Atoi(args[0])
+		if err != nil || steps < 1 {
+			logger.Fatal("Number of migrations to roll back must be a positive integer", zap.String("value", args[0]))
+		}
+
+		if err := migrations.Down(context.Background(), steps); err != nil {
+			logger.Fatal("Failed to roll back migrations", zap.Error(err))
+		}
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show applied and pending migrations",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		statuses, err := migrations.GetStatus(context.Background())
+		if err != nil {
+			logger.Fatal("Failed to read migration status", zap.Error(err))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", "-"
+			if status.Applied {
+				state = "applied"
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%06d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		w.Flush()
+	},
+}
+
+var migrateCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new empty up/down migration pair",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		paths, err := migrations.Create(args[0])
+		if err != nil {
+			logger.Fatal("Failed to create migration", zap.Error(err))
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+	},
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+	migrateCmd.AddCommand(migrateCreateCmd)
+}
@@ -8,7 +8,6 @@ import (
 )
 
 var Serve func() error
-var Migrate func() error
 
 var rootCmd = &cobra.Command{
 	Use:   "uttarawave-backend",
@@ -23,6 +22,10 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(billingCmd)
+	rootCmd.AddCommand(subscriptionCmd)
+	rootCmd.AddCommand(deviceCmd)
+	rootCmd.AddCommand(employeeCmd)
 }
 
 func Execute() error {
@@ -41,8 +44,12 @@ func displayBanner() {
 Uttarawave Backend Application
 ======================================================
 Available commands:
-  serve   : Serve the backend server
-  migrate : Run database migrations 
+  serve         : Serve the backend server
+  migrate       : Manage database schema migrations (up, down, status, create)
+  billing       : Run billing operations (run --period YYYY-MM, overdue, print)
+  subscriptions : Subscription maintenance (expire, holds)
+  devices       : Device inventory (import FILE [--dry-run])
+  employees     : Employee accounts (create)
 ======================================================
 `
 	fmt.Println(banner)
@@ -58,14 +65,3 @@ var serveCmd = &cobra.Command{
 		}
 	},
 }
-
-var migrateCmd = &cobra.Command{
-	Use:   "migrate",
-	Short: "Run database migration for uttarawave backend server",
-	Long:  `This command migrates database of uttarawave backend server.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		if err := Migrate(); err != nil {
-			logger.Fatal("Failed to run the application", zap.Error(err))
-		}
-	},
-}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var subscriptionCmd = &cobra.Command{
+	Use:   "subscriptions",
+	Short: "Subscription maintenance operations for uttarawave backend server",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var subscriptionExpireCmd = &cobra.Command{
+	Use:   "expire",
+	Short: "Expire overdue subscriptions and flag their devices for collection",
+	Long:  `This command expires active subscriptions whose renewal date has passed by more than the configured grace period and marks their devices as pending collection.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		expiryService := services.NewExpiryService(
+			repositories.NewGormSubscriptionRepository(),
+			repositories.NewGormDeviceRepository(),
+			services.ExpiryGracePeriod(),
+		)
+
+		result, err := expiryService.ProcessExpiredSubscriptions(context.Background(), time.Now())
+		if result != nil {
+			fmt.Printf("Expired %d subscription(s), %d device(s) marked for collection, %d failed\n", result.Expired, result.DevicesMarked, result.Failed)
+		}
+		if err != nil {
+			logger.Fatal("Expiry run failed", zap.Error(err))
+		}
+	},
+}
+
+var subscriptionHoldsCmd = &cobra.Command{
+	Use:   "holds",
+	Short: "Start scheduled subscription holds and resume subscriptions whose hold has ended",
+	Long:  `This command puts subscriptions on hold when their scheduled hold starts, invoicing the hold fee, and resumes the ones whose hold has reached its end date.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		holdFee, err := services.DefaultHoldFee()
+		if err != nil {
+			logger.Fatal("Invalid hold configuration", zap.Error(err))
+		}
+
+		holdService := services.NewHoldService(
+			repositories.NewGormSubscriptionRepository(),
+			repositories.NewGormInvoiceRepository(),
+			repositories.NewGormCustomerCreditRepository(),
+			repositories.NewGormLedgerRepository(),
+			holdFee,
+		)
+
+		result, err := holdService.ProcessHolds(context.Background(), time.Now())
+		if result != nil {
+			fmt.Printf("Started %d hold(s), ended %d, skipped %d, %d failed\n", result.Started, result.Ended, result.Skipped, result.Failed)
+		}
+		if err != nil {
+			logger.Fatal("Hold run failed", zap.Error(err))
+		}
+	},
+}
+
+func init() {
+	subscriptionCmd.AddCommand(subscriptionExpireCmd)
+	subscriptionCmd.AddCommand(subscriptionHoldsCmd)
+}
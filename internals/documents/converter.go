@@ -0,0 +1,57 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const defaultConvertTimeout = 30 * time.Second
+
+// Converter turns a rendered HTML document into a PDF
+type Converter interface {
+	Convert(ctx context.Context, html []byte) ([]byte, error)
+}
+
+// CommandConverter pipes the HTML through an external program that writes the PDF to its
+// standard output, e.g. wkhtmltopdf --quiet --encoding utf-8 - -
+type CommandConverter struct {
+	Command []string
+	Timeout time.Duration
+}
+
+func (c CommandConverter) Convert(ctx context.Context, html []byte) ([]byte, error) {
+	if len(c.Command) == 0 {
+		return nil, ErrPDFNotConfigured
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultConvertTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(html)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s is not installed", ErrPDFNotConfigured, c.Command[0])
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("pdf conversion timed out after %s", timeout)
+		}
+		return nil, fmt.Errorf("pdf conversion failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, errors.New("pdf conversion produced no output")
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,205 @@
+// Package documents renders printable customer documents such as invoices and receipts from
+// HTML templates, optionally converted to PDF. The built-in templates and labels can be
+// overridden from a directory, so the wording and layout can be edited without a rebuild.
+package documents
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+//go:embed templates/*.html templates/labels.json
+var templateFiles embed.FS
+
+// Names of the templates callers render
+const (
+	Receipt  = "receipt.html"
+	Invoice  = "invoice.html"
+	Invoices = "invoices.html"
+)
+
+type Format string
+
+const (
+	FormatHTML Format = "html"
+	FormatPDF  Format = "pdf"
+)
+
+var (
+	ErrUnknownFormat    = errors.New("unknown document format, expected html or pdf")
+	ErrPDFNotConfigured = errors.New("pdf rendering is unavailable, documents.pdf.command must name an installed converter")
+)
+
+// ParseFormat reads a requested format, defaulting to HTML
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(value)) {
+	case "", FormatHTML:
+		return FormatHTML, nil
+	case FormatPDF:
+		return FormatPDF, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+func (f Format) ContentType() string {
+	if f == FormatPDF {
+		return "application/pdf"
+	}
+	return "text/html; charset=utf-8"
+}
+
+// Company is the letterhead printed on every document. Logo is an image URL or data URI.
+type Company struct {
+	Name    string
+	Address string
+	Phone   string
+	Logo    template.URL
+}
+
+type Renderer struct {
+	templates       *template.Template
+	labels          map[Language]map[string]string
+	company         Company
+	defaultLanguage Language
+	converter       Converter
+}
+
+// Options configure a Renderer. TemplatesDir, when set, holds *.html templates and a
+// labels.json that replace the built-in ones of the same name. A nil Converter leaves PDF
+// output unavailable.
+type Options struct {
+	Company         Company
+	TemplatesDir    string
+	DefaultLanguage Language
+	Converter       Converter
+}
+
+func NewRenderer(options Options) (*Renderer, error) {
+	templates, err := template.New("").Funcs(newLocale(English, nil).funcs(options.Company)).
+		ParseFS(templateFiles, "templates/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse document templates: %w", err)
+	}
+	labels, err := readLabels(templateFiles, "templates/labels.json")
+	if err != nil {
+		return nil, err
+	}
+
+	if options.TemplatesDir != "" {
+		dir := os.DirFS(options.TemplatesDir)
+		if matches, _ := fs.Glob(dir, "*.html"); len(matches) > 0 {
+			if templates, err = templates.ParseFS(dir, "*.html"); err != nil {
+				return nil, fmt.Errorf("failed to parse templates in %s: %w", options.TemplatesDir, err)
+			}
+		}
+		if _, err := fs.Stat(dir, "labels.json"); err == nil {
+			overrides, err := readLabels(dir, "labels.json")
+			if err != nil {
+				return nil, err
+			}
+			mergeLabels(labels, overrides)
+		}
+	}
+
+	language := options.DefaultLanguage
+	if language == "" {
+		language = English
+	}
+	if _, ok := labels[language]; !ok {
+		return nil, fmt.Errorf("%w: no labels for %q", ErrUnknownLanguage, language)
+	}
+
+	return &Renderer{
+		templates:       templates,
+		labels:          labels,
+		company:         options.Company,
+		defaultLanguage: language,
+		converter:       options.Converter,
+	}, nil
+}
+
+// NewRendererFromConfig builds a renderer from the documents section of the configuration
+func NewRendererFromConfig() (*Renderer, error) {
+	options := Options{
+		Company: Company{
+			Name:    viper.GetString("documents.company.name"),
+			Address: viper.GetString("documents.company.address"),
+			Phone:   viper.GetString("documents.company.phone"),
+		},
+		TemplatesDir:    viper.GetString("documents.templates_dir"),
+		DefaultLanguage: Language(viper.GetString("documents.language")),
+	}
+
+	if path := viper.GetString("documents.company.logo"); path != "" {
+		logo, err := readLogo(path)
+		if err != nil {
+			return nil, err
+		}
+		options.Company.Logo = logo
+	}
+	if command := viper.GetStringSlice("documents.pdf.command"); len(command) > 0 {
+		options.Converter = CommandConverter{Command: command, Timeout: viper.GetDuration("documents.pdf.timeout")}
+	}
+	return NewRenderer(options)
+}
+
+// readLogo inlines the logo as a data URI, so it prints the same from a browser and from the PDF converter
+func readLogo(path string) (template.URL, error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return template.URL(path), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read company logo: %w", err)
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	return template.URL("data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(content)), nil
+}
+
+// Render executes the named template with data in the given language, or the default language
+// when it is empty, and returns the document in the requested format
+func (r *Renderer) Render(ctx context.Context, name string, format Format, language Language, data any) ([]byte, error) {
+	if format == FormatPDF && r.converter == nil {
+		return nil, ErrPDFNotConfigured
+	}
+	if language == "" {
+		language = r.defaultLanguage
+	}
+	labels, ok := r.labels[language]
+	if !ok {
+		return nil, ErrUnknownLanguage
+	}
+
+	// The parsed set is never executed itself, so each render can bind its own language
+	templates, err := r.templates.Clone()
+	if err != nil {
+		return nil, err
+	}
+	templates.Funcs(newLocale(language, labels).funcs(r.company))
+
+	var html bytes.Buffer
+	if err := templates.ExecuteTemplate(&html, name, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	if format != FormatPDF {
+		return html.Bytes(), nil
+	}
+	return r.converter.Convert(ctx, html.Bytes())
+}
@@ -0,0 +1,143 @@
+package documents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/services"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, FormatHTML, format)
+
+	format, err = ParseFormat("PDF")
+	require.NoError(t, err)
+	assert.Equal(t, FormatPDF, format)
+
+	_, err = ParseFormat("docx")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestRenderReceipt(t *testing.T) {
+	renderer, err := NewRenderer(Options{Company: Company{Name: "Uttarawave"}})
+	require.NoError(t, err)
+
+	receipt := &services.Receipt{
+		Number:   "RCT-2026-004512",
+		PaidAt:   time.Date(2026, 11, 3, 10, 0, 0, 0, time.UTC),
+		Amount:   money.FromMajor(1000),
+		Currency: money.BDT,
+		Method:   "Manual",
+		Customer: services.DocumentCustomer{
+			Name:    "Rahim <Uddin>",
+			Mobile:  "01700000000",
+			Address: services.FormatAddress(models.Address{House: "12", Road: "5", Area: "Uttara"}),
+		},
+		Invoices: []services.ReceiptInvoice{
+			{Number: "INV-2026-000123", BillingPeriod: "2026-11", Applied: money.FromMajor(800)},
+		},
+		Subscriptions: []services.DocumentSubscription{
+			{Type: models.InternetPackage, PackageName: "Home 20 Mbps"},
+		},
+		Credit: money.FromMajor(200),
+	}
+
+	html, err := renderer.Render(context.Background(), Receipt, FormatHTML, "", receipt)
+	require.NoError(t, err)
+	for _, want := range []string{
+		"RCT-2026-004512", "03 November 2026", "INV-2026-000123", "Home 20 Mbps",
+		"House 12, Road 5, Uttara", "BDT 800.00", "BDT 200.00", "BDT 1000.00",
+		"Rahim &lt;Uddin&gt;",
+	} {
+		assert.Contains(t, string(html), want)
+	}
+
+	_, err = renderer.Render(context.Background(), Receipt, FormatPDF, "", receipt)
+	assert.ErrorIs(t, err, ErrPDFNotConfigured)
+}
+
+func TestRenderInvoice(t *testing.T) {
+	renderer, err := NewRenderer(Options{Company: Company{Name: "Uttarawave", Logo: "https://example.com/logo.png"}})
+	require.NoError(t, err)
+
+	invoice := services.InvoiceDocument{
+		Number:        "INV-2026-000123",
+		Status:        models.InvoiceOverdue,
+		BillingPeriod: "2026-11",
+		IssuedAt:      time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		DueDate:       time.Date(2026, 11, 10, 0, 0, 0, 0, time.UTC),
+		Currency:      money.BDT,
+		Customer:      services.DocumentCustomer{Name: "Rahim", Building: "Lake View"},
+		Lines: []services.InvoiceDocumentLine{
+			{Description: "Home 20 Mbps subscription (2026-11)", Quantity: 1, UnitPrice: money.FromMajor(800), Amount: money.FromMajor(800)},
+		},
+		Total:   money.FromMajor(800),
+		Balance: money.FromMajor(800),
+	}
+
+	html, err := renderer.Render(context.Background(), Invoice, FormatHTML, English, invoice)
+	require.NoError(t, err)
+	for _, want := range []string{
+		"INV-2026-000123", "November 2026", "10 November 2026", "Lake View", "BDT 800.00",
+		"OVERDUE", `src="https://example.com/logo.png"`,
+	} {
+		assert.Contains(t, string(html), want)
+	}
+
+	html, err = renderer.Render(context.Background(), Invoice, FormatHTML, Bangla, invoice)
+	require.NoError(t, err)
+	assert.Contains(t, string(html), `lang="bn"`)
+	assert.Contains(t, string(html), "৳ ৮০০.০০")
+	assert.Contains(t, string(html), "১০")
+
+	bulk := services.BuildingInvoices{Building: "Lake View", Period: "2026-11", Invoices: []services.InvoiceDocument{invoice, invoice}}
+	html, err = renderer.Render(context.Background(), Invoices, FormatHTML, English, bulk)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(html), `<div class="page">`))
+
+	_, err = renderer.Render(context.Background(), Invoice, FormatHTML, "fr", invoice)
+	assert.ErrorIs(t, err, ErrUnknownLanguage)
+}
+
+func TestTemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "labels.json"), []byte(`{"en": {"receipt": "Cash Memo"}}`), 0o644))
+
+	renderer, err := NewRenderer(Options{TemplatesDir: dir})
+	require.NoError(t, err)
+	html, err := renderer.Render(context.Background(), Receipt, FormatHTML, "", &services.Receipt{Number: "RCT-2026-000001"})
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "Cash Memo")
+}
+
+func TestConfiguredPDFConverter(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	receipt := &services.Receipt{Number: "RCT-2026-004512", PaidAt: time.Date(2026, 11, 3, 10, 0, 0, 0, time.UTC)}
+
+	// cat stands in for wkhtmltopdf: it reads the HTML on stdin and writes it back out
+	viper.Set("documents.pdf.command", "cat")
+	renderer, err := NewRendererFromConfig()
+	require.NoError(t, err)
+	html, err := renderer.Render(context.Background(), Receipt, FormatHTML, "", receipt)
+	require.NoError(t, err)
+	pdf, err := renderer.Render(context.Background(), Receipt, FormatPDF, "", receipt)
+	require.NoError(t, err)
+	assert.Equal(t, html, pdf)
+
+	viper.Set("documents.pdf.command", "uttarawave-missing-converter --quiet - -")
+	renderer, err = NewRendererFromConfig()
+	require.NoError(t, err)
+	_, err = renderer.Render(context.Background(), Receipt, FormatPDF, "", receipt)
+	assert.ErrorIs(t, err, ErrPDFNotConfigured, "a converter that is not installed is reported like a missing one")
+}
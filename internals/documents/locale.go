@@ -0,0 +1,128 @@
+package documents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+type Language string
+
+const (
+	English Language = "en"
+	Bangla  Language = "bn"
+)
+
+var ErrUnknownLanguage = errors.New("unknown document language")
+
+// ParseLanguage reads a requested language; empty means the renderer's default
+func ParseLanguage(value string) (Language, error) {
+	switch Language(strings.ToLower(value)) {
+	case "":
+		return "", nil
+	case English:
+		return English, nil
+	case Bangla:
+		return Bangla, nil
+	}
+	return "", ErrUnknownLanguage
+}
+
+var banglaDigits = strings.NewReplacer(
+	"0", "০", "1", "১", "2", "২", "3", "৩", "4", "৪",
+	"5", "৫", "6", "৬", "7", "৭", "8", "৮", "9", "৯",
+)
+
+// locale formats the numbers, dates and labels of one language
+type locale struct {
+	language Language
+	labels   map[string]string
+}
+
+func newLocale(language Language, labels map[string]string) locale {
+	return locale{language: language, labels: labels}
+}
+
+// label looks a key up, falling back to the key itself so an untranslated value still prints
+func (l locale) label(key string) string {
+	if value, ok := l.labels[key]; ok {
+		return value
+	}
+	return key
+}
+
+func (l locale) digits(value string) string {
+	if l.language == Bangla {
+		return banglaDigits.Replace(value)
+	}
+	return value
+}
+
+func (l locale) money(amount money.Amount, currency money.Currency) string {
+	return l.label("currency_"+string(currency)) + " " + l.digits(amount.String())
+}
+
+func (l locale) month(month time.Month) string {
+	return l.label("month_" + strconv.Itoa(int(month)))
+}
+
+func (l locale) date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return l.digits(fmt.Sprintf("%02d", t.Day())) + " " + l.month(t.Month()) + " " + l.digits(strconv.Itoa(t.Year()))
+}
+
+// period prints a YYYY-MM billing period as its month and year
+func (l locale) period(value string) string {
+	t, err := time.Parse("2006-01", value)
+	if err != nil {
+		return value
+	}
+	return l.month(t.Month()) + " " + l.digits(strconv.Itoa(t.Year()))
+}
+
+func (l locale) funcs(company Company) template.FuncMap {
+	return template.FuncMap{
+		"t":      l.label,
+		"money":  l.money,
+		"date":   l.date,
+		"period": l.period,
+		"number": func(n int) string { return l.digits(strconv.Itoa(n)) },
+		"company": func() Company {
+			return company
+		},
+		"lang": func() string { return string(l.language) },
+	}
+}
+
+func readLabels(fsys fs.FS, name string) (map[Language]map[string]string, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read labels: %w", err)
+	}
+	var labels map[Language]map[string]string
+	if err := json.Unmarshal(content, &labels); err != nil {
+		return nil, fmt.Errorf("invalid labels in %s: %w", name, err)
+	}
+	return labels, nil
+}
+
+// mergeLabels lays the overrides over the built-in labels key by key
+func mergeLabels(labels, overrides map[Language]map[string]string) {
+	for language, values := range overrides {
+		if labels[language] == nil {
+			labels[language] = make(map[string]string, len(values))
+		}
+		for key, value := range values {
+			labels[language][key] = value
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/internals/services"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// InitializeJobs builds the scheduler with every background job enabled in the jobs config section
+func InitializeJobs() *Scheduler {
+	scheduler := NewScheduler()
+
+	subscriptionRepo := repositories.NewGormSubscriptionRepository()
+	invoiceRepo := repositories.NewGormInvoiceRepository()
+	deviceRepo := repositories.NewGormDeviceRepository()
+	packageRepo := repositories.NewGormPackageRepository()
+	creditRepo := repositories.NewGormCustomerCreditRepository()
+	ledgerRepo := repositories.NewGormLedgerRepository()
+
+	if viper.GetBool("jobs.billing.enabled") {
+		billingService := services.NewBillingService(subscriptionRepo, invoiceRepo, packageRepo, creditRepo, ledgerRepo)
+		scheduler.Register(Job{
+			Name:     "billing",
+			Interval: jobInterval("jobs.billing.interval"),
+			Run: func(ctx context.Context) error {
+				_, err := billingService.RunBilling(ctx, services.NewBillingPeriod(time.Now()))
+				return err
+			},
+		})
+	}
+
+	if viper.GetBool("jobs.expiry.enabled") {
+		expiryService := services.NewExpiryService(subscriptionRepo, deviceRepo, services.ExpiryGracePeriod())
+		scheduler.Register(Job{
+			Name:     "subscription-expiry",
+			Interval: jobInterval("jobs.expiry.interval"),
+			Run: func(ctx context.Context) error {
+				_, err := expiryService.ProcessExpiredSubscriptions(ctx, time.Now())
+				return err
+			},
+		})
+	}
+
+	if viper.GetBool("jobs.holds.enabled") {
+		holdFee, err := services.DefaultHoldFee()
+		if err != nil {
+			logger.Error("Hold job disabled", zap.Error(err))
+		} else {
+			holdService := services.NewHoldService(subscriptionRepo, invoiceRepo, creditRepo, ledgerRepo, holdFee)
+			scheduler.Register(Job{
+				Name:     "subscription-holds",
+				Interval: jobInterval("jobs.holds.interval"),
+				Run: func(ctx context.Context) error {
+					_, err := holdService.ProcessHolds(ctx, time.Now())
+					return err
+				},
+			})
+		}
+	}
+
+	if viper.GetBool("jobs.overdue.enabled") {
+		policies, err := services.LoadLateFeePolicies()
+		if err != nil {
+			logger.Error("Overdue job disabled", zap.Error(err))
+		} else {
+			overdueService := services.NewOverdueService(invoiceRepo, subscriptionRepo, packageRepo, ledgerRepo, policies, services.LateFeeGracePeriod())
+			scheduler.Register(Job{
+				Name:     "invoice-overdue",
+				Interval: jobInterval("jobs.overdue.interval"),
+				Run: func(ctx context.Context) error {
+					_, err := overdueService.ProcessOverdueInvoices(ctx, time.Now())
+					return err
+				},
+			})
+		}
+	}
+
+	return scheduler
+}
+
+func jobInterval(key string) time.Duration {
+	interval := viper.GetDuration(key)
+	if interval <= 0 {
+		logger.Warn("Invalid or missing job interval, defaulting to 1h", zap.String("key", key))
+		return time.Hour
+	}
+	return interval
+}
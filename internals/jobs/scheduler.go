@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	jobs   []Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every registered job once immediately and then on its interval until Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			logger.Info("Scheduled job started", zap.String("job", job.Name), zap.Duration("interval", job.Interval))
+
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+
+			for {
+				s.runJob(ctx, job)
+
+				select {
+				case <-ctx.Done():
+					logger.Info("Scheduled job stopped", zap.String("job", job.Name))
+					return
+				case <-ticker.C:
+				}
+			}
+		}(job)
+	}
+}
+
+func (s *Scheduler) runJob(ctx context.Context, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Scheduled job panicked", zap.String("job", job.Name), zap.Any("panic", r))
+		}
+	}()
+
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		logger.Error("Scheduled job failed", zap.String("job", job.Name), zap.Error(err))
+		return
+	}
+	logger.Info("Scheduled job finished", zap.String("job", job.Name), zap.Duration("duration", time.Since(start)))
+}
+
+// Stop cancels all running jobs and waits for in-flight runs to return
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
@@ -0,0 +1,361 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var migrationFiles embed.FS
+
+const (
+	migrationDir = "sql"
+	historyTable = "schema_migrations"
+
+	// advisoryLockID guards against two processes migrating the same database at once
+	advisoryLockID = 7391842051
+)
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type AppliedMigration struct {
+	Version   int64     `gorm:"primaryKey"`
+	Name      string    `gorm:"type:varchar(255)"`
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (AppliedMigration) TableName() string {
+	return historyTable
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Load reads all migrations checked into the sql directory, ordered by version
+func Load() ([]Migration, error) {
+	return loadFrom(migrationFiles, migrationDir)
+}
+
+func loadFrom(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func ensureHistoryTable(ctx context.Context) error {
+	return db.DB.WithContext(ctx).Exec(`CREATE TABLE IF NOT EXISTS ` + historyTable + ` (
+		version bigint PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`).Error
+}
+
+// appliedMigrations is read-only so that status checks never alter the schema
+func appliedMigrations(ctx context.Context) (map[int64]AppliedMigration, error) {
+	if !db.DB.WithContext(ctx).Migrator().HasTable(historyTable) {
+		return map[int64]AppliedMigration{}, nil
+	}
+
+	var rows []AppliedMigration
+	if err := db.DB.WithContext(ctx).Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema history: %w", err)
+	}
+
+	applied := make(map[int64]AppliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// withLock runs fn while holding a session level advisory lock on a dedicated connection
+func withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockID); err != nil {
+			logger.Error("Failed to release migration lock", zap.Error(err))
+		}
+	}()
+
+	return fn(db.DB.WithContext(ctx))
+}
+
+// Up applies every pending migration in version order, each in its own transaction
+func Up(ctx context.Context) error {
+	migrations, err := Load()
+	if err != nil {
+		return err
+	}
+
+	return withLock(ctx, func(conn *gorm.DB) error {
+		if err := ensureHistoryTable(ctx); err != nil {
+			return fmt.Errorf("failed to create schema history table: %w", err)
+		}
+
+		applied, err := appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&AppliedMigration{Version: m.Version, Name: m.Name}).Error
+			})
+			if err != nil {
+				logger.Error("Failed to apply migration", zap.Int64("version", m.Version), zap.String("name", m.Name), zap.Error(err))
+				return fmt.Errorf("failed to apply migration %d_%s: %w", m.Version, m.Name, err)
+			}
+
+			logger.Info("Applied migration", zap.Int64("version", m.Version), zap.String("name", m.Name))
+			count++
+		}
+
+		if count == 0 {
+			logger.Info("Database schema is up to date")
+		} else {
+			logger.Info("Migrations applied successfully", zap.Int("count", count))
+		}
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied migrations, newest first
+func Down(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return errors.New("number of migrations to roll back must be at least 1")
+	}
+
+	migrations, err := Load()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	return withLock(ctx, func(conn *gorm.DB) error {
+		if err := ensureHistoryTable(ctx); err != nil {
+			return fmt.Errorf("failed to create schema history table: %w", err)
+		}
+
+		applied, err := appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		if steps > len(versions) {
+			steps = len(versions)
+		}
+
+		for _, version := range versions[:steps] {
+			m, ok := known[version]
+			if !ok {
+				return fmt.Errorf("applied migration %d_%s is not present in this build", version, applied[version].Name)
+			}
+			if strings.TrimSpace(m.Down) == "" {
+				return fmt.Errorf("migration %d_%s has no down script", m.Version, m.Name)
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&AppliedMigration{}, "version = ?", m.Version).Error
+			})
+			if err != nil {
+				logger.Error("Failed to roll back migration", zap.Int64("version", m.Version), zap.String("name", m.Name), zap.Error(err))
+				return fmt.Errorf("failed to roll back migration %d_%s: %w", m.Version, m.Name, err)
+			}
+
+			logger.Info("Rolled back migration", zap.Int64("version", m.Version), zap.String("name", m.Name))
+		}
+
+		return nil
+	})
+}
+
+// GetStatus reports every known migration together with whether it has been applied
+func GetStatus(ctx context.Context) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		status := Status{Migration: m}
+		if row, ok := applied[m.Version]; ok {
+			status.Applied = true
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied to the database yet
+func Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := GetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Create writes an empty up/down pair with the next version number into the source tree
+func Create(name string) ([]string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return nil, errors.New("migration name is required")
+	}
+
+	_, filename, _, ok := runtime.Caller(0)
+	if !ok {
+		return nil, errors.New("unable to get the current filename")
+	}
+	dir := filepath.Join(filepath.Dir(filename), migrationDir)
+
+	migrations, err := loadFrom(os.DirFS(filepath.Dir(filename)), migrationDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var next int64 = 1
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", next, name, direction))
+		content := fmt.Sprintf("-- %06d_%s (%s)\n", next, name, direction)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write migration file: %w", err)
+		}
+		paths = append(paths, path)
+	}
+
+	logger.Info("Created migration", zap.Int64("version", next), zap.String("name", name))
+	return paths, nil
+}
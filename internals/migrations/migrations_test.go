@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFromOrdersAndPairsMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/000002_add_index.up.sql":        {Data: []byte("CREATE INDEX a ON b (c);")},
+		"sql/000002_add_index.down.sql":      {Data: []byte("DROP INDEX a;")},
+		"sql/000001_initial_schema.up.sql":   {Data: []byte("CREATE TABLE b (c text);")},
+		"sql/000001_initial_schema.down.sql": {Data: []byte("DROP TABLE b;")},
+	}
+
+	migrations, err := loadFrom(fsys, "sql")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "initial_schema", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE b;", migrations[0].Down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Equal(t, "CREATE INDEX a ON b (c);", migrations[1].Up)
+}
+
+func TestLoadFromRejectsInvalidMigrations(t *testing.T) {
+	testCases := []struct {
+		description string
+		files       fstest.MapFS
+	}{
+		{"InvalidName", fstest.MapFS{"sql/initial.sql": {Data: []byte("SELECT 1;")}}},
+		{"MissingUp", fstest.MapFS{"sql/000001_initial.down.sql": {Data: []byte("SELECT 1;")}}},
+		{"DuplicateVersion", fstest.MapFS{
+			"sql/000001_first.up.sql":  {Data: []byte("SELECT 1;")},
+			"sql/000001_second.up.sql": {Data: []byte("SELECT 1;")},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			_, err := loadFrom(tc.files, "sql")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := Load()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "migration versions must be sequential")
+		assert.NotEmpty(t, m.Down, "migration %d_%s must have a down script", m.Version, m.Name)
+	}
+}
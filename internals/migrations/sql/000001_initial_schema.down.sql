@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS expenses;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS devices;
+DROP TABLE IF EXISTS packages;
+DROP TABLE IF EXISTS addresses;
+DROP TABLE IF EXISTS customers;
+DROP TABLE IF EXISTS buildings;
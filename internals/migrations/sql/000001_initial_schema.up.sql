@@ -0,0 +1,145 @@
+-- Baseline schema. Mirrors what AutoMigrate produced so existing databases can adopt
+-- versioned migrations without changes; every statement is safe to run against them.
+
+CREATE TABLE IF NOT EXISTS buildings (
+    id text PRIMARY KEY,
+    name varchar(100),
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_buildings_name ON buildings (name);
+
+CREATE TABLE IF NOT EXISTS customers (
+    id text PRIMARY KEY,
+    mobile text,
+    email text NULL,
+    name varchar(100),
+    type varchar(20),
+    identification_number varchar(50) NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_customers_mobile ON customers (mobile);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_customers_email ON customers (email);
+CREATE INDEX IF NOT EXISTS idx_customers_name ON customers (name);
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id text PRIMARY KEY,
+    customer_id text,
+    building_id text,
+    flat varchar(50),
+    house varchar(50),
+    road varchar(100),
+    block varchar(50),
+    area varchar(100),
+    city varchar(100),
+    latitude decimal(10,8),
+    longitude decimal(11,8)
+);
+CREATE INDEX IF NOT EXISTS idx_addresses_customer_id ON addresses (customer_id);
+CREATE INDEX IF NOT EXISTS idx_addresses_building_id ON addresses (building_id);
+
+DO $$
+BEGIN
+    ALTER TABLE addresses ADD CONSTRAINT fk_buildings_address FOREIGN KEY (building_id) REFERENCES buildings (id);
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
+DO $$
+BEGIN
+    ALTER TABLE addresses ADD CONSTRAINT fk_customers_address FOREIGN KEY (customer_id) REFERENCES customers (id);
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
+CREATE TABLE IF NOT EXISTS packages (
+    id text PRIMARY KEY,
+    type text,
+    name varchar(100),
+    price decimal,
+    is_active boolean,
+    bandwidth bigint,
+    bandwidth_type text,
+    has_real_ip boolean,
+    channel_count bigint,
+    tv_count bigint,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS devices (
+    id text PRIMARY KEY,
+    type varchar(20),
+    serial_number varchar(50),
+    brand varchar(50),
+    model varchar(50),
+    usage varchar(20),
+    status varchar(20),
+    purchase_price decimal,
+    purchase_date timestamptz,
+    subscription_id text,
+    building_id text,
+    assigned_date timestamptz,
+    collection_date timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_devices_serial_number ON devices (serial_number);
+CREATE INDEX IF NOT EXISTS idx_devices_subscription_id ON devices (subscription_id);
+CREATE INDEX IF NOT EXISTS idx_devices_building_id ON devices (building_id);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id text PRIMARY KEY,
+    customer_id text,
+    package_id text,
+    package_price decimal,
+    monthly_discount decimal,
+    status text,
+    start_date timestamptz,
+    renewal_date timestamptz,
+    paid_until timestamptz,
+    due_amount text,
+    device_id text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_customer_id ON subscriptions (customer_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_package_id ON subscriptions (package_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_device_id ON subscriptions (device_id);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id text PRIMARY KEY,
+    customer_id text,
+    subscription_id text,
+    amount decimal,
+    status text,
+    due_date timestamptz,
+    paid_date timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_invoices_customer_id ON invoices (customer_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_subscription_id ON invoices (subscription_id);
+
+CREATE TABLE IF NOT EXISTS payments (
+    id text PRIMARY KEY,
+    invoice_id text,
+    customer_id text,
+    amount decimal,
+    type text,
+    description text,
+    paid_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_payments_invoice_id ON payments (invoice_id);
+CREATE INDEX IF NOT EXISTS idx_payments_customer_id ON payments (customer_id);
+
+CREATE TABLE IF NOT EXISTS expenses (
+    id text PRIMARY KEY,
+    amount decimal,
+    type text,
+    description text,
+    paid_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
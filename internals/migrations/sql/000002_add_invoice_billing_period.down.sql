@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_invoices_subscription_period;
+ALTER TABLE invoices DROP COLUMN IF EXISTS billing_period;
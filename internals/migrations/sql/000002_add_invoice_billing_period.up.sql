@@ -0,0 +1,5 @@
+ALTER TABLE invoices ADD COLUMN billing_period varchar(7);
+
+-- One generated invoice per subscription per billing period; manual invoices leave the period empty
+CREATE UNIQUE INDEX idx_invoices_subscription_period ON invoices (subscription_id, billing_period)
+    WHERE billing_period IS NOT NULL;
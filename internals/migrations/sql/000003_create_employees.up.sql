@@ -0,0 +1,16 @@
+CREATE TABLE employees (
+    id text PRIMARY KEY,
+    name varchar(100) NOT NULL,
+    mobile varchar(20) NOT NULL,
+    email text NULL,
+    password_hash varchar(100) NOT NULL,
+    role varchar(20) NOT NULL,
+    is_active boolean NOT NULL DEFAULT true,
+    salary decimal NOT NULL DEFAULT 0,
+    join_date timestamptz,
+    last_login_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX idx_employees_mobile ON employees (mobile);
+CREATE UNIQUE INDEX idx_employees_email ON employees (email);
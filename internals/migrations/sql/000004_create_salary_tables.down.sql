@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS salary_payouts;
+DROP TABLE IF EXISTS salary_histories;
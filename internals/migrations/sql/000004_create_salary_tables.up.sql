@@ -0,0 +1,25 @@
+CREATE TABLE salary_histories (
+    id text PRIMARY KEY,
+    employee_id text NOT NULL REFERENCES employees (id),
+    salary decimal NOT NULL,
+    previous_salary decimal NOT NULL DEFAULT 0,
+    effective_from timestamptz NOT NULL,
+    reason text,
+    changed_by varchar(64),
+    created_at timestamptz
+);
+CREATE INDEX idx_salary_histories_employee_id ON salary_histories (employee_id);
+
+CREATE TABLE salary_payouts (
+    id text PRIMARY KEY,
+    employee_id text NOT NULL REFERENCES employees (id),
+    period varchar(7) NOT NULL,
+    amount decimal NOT NULL,
+    expense_id text NOT NULL REFERENCES expenses (id),
+    paid_at timestamptz NOT NULL,
+    paid_by varchar(64),
+    created_at timestamptz
+);
+CREATE INDEX idx_salary_payouts_employee_id ON salary_payouts (employee_id);
+CREATE INDEX idx_salary_payouts_expense_id ON salary_payouts (expense_id);
+CREATE UNIQUE INDEX idx_salary_payouts_employee_period ON salary_payouts (employee_id, period);
@@ -0,0 +1,52 @@
+ALTER TABLE salary_payouts
+    ALTER COLUMN amount TYPE decimal USING amount / 100.0;
+
+ALTER TABLE salary_histories
+    ALTER COLUMN salary TYPE decimal USING salary / 100.0,
+    ALTER COLUMN previous_salary DROP DEFAULT,
+    ALTER COLUMN previous_salary TYPE decimal USING previous_salary / 100.0,
+    ALTER COLUMN previous_salary SET DEFAULT 0;
+
+ALTER TABLE employees
+    ALTER COLUMN salary DROP DEFAULT,
+    ALTER COLUMN salary TYPE decimal USING salary / 100.0,
+    ALTER COLUMN salary SET DEFAULT 0;
+
+ALTER TABLE devices
+    ALTER COLUMN purchase_price TYPE decimal USING purchase_price / 100.0;
+
+ALTER TABLE expenses
+    DROP COLUMN currency,
+    ALTER COLUMN amount DROP NOT NULL,
+    ALTER COLUMN amount DROP DEFAULT,
+    ALTER COLUMN amount TYPE decimal USING amount / 100.0;
+
+ALTER TABLE payments
+    DROP COLUMN currency,
+    ALTER COLUMN amount DROP NOT NULL,
+    ALTER COLUMN amount DROP DEFAULT,
+    ALTER COLUMN amount TYPE decimal USING amount / 100.0;
+
+ALTER TABLE invoices
+    DROP COLUMN currency,
+    ALTER COLUMN amount DROP NOT NULL,
+    ALTER COLUMN amount DROP DEFAULT,
+    ALTER COLUMN amount TYPE decimal USING amount / 100.0;
+
+ALTER TABLE subscriptions
+    DROP COLUMN currency,
+    ALTER COLUMN due_amount DROP NOT NULL,
+    ALTER COLUMN due_amount DROP DEFAULT,
+    ALTER COLUMN due_amount TYPE text USING to_char(due_amount / 100.0, 'FM999999999999990.00'),
+    ALTER COLUMN monthly_discount DROP NOT NULL,
+    ALTER COLUMN monthly_discount DROP DEFAULT,
+    ALTER COLUMN monthly_discount TYPE decimal USING monthly_discount / 100.0,
+    ALTER COLUMN package_price DROP NOT NULL,
+    ALTER COLUMN package_price DROP DEFAULT,
+    ALTER COLUMN package_price TYPE decimal USING package_price / 100.0;
+
+ALTER TABLE packages
+    DROP COLUMN currency,
+    ALTER COLUMN price DROP NOT NULL,
+    ALTER COLUMN price DROP DEFAULT,
+    ALTER COLUMN price TYPE decimal USING price / 100.0;
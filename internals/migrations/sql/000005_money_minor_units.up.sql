@@ -0,0 +1,55 @@
+-- Money is stored as exact integer minor units (poisha) with an explicit currency.
+-- Existing decimal values are multiplied by 100 and rounded; text due amounts are parsed first.
+
+ALTER TABLE packages
+    ALTER COLUMN price TYPE bigint USING round(COALESCE(price, 0) * 100)::bigint,
+    ALTER COLUMN price SET DEFAULT 0,
+    ALTER COLUMN price SET NOT NULL,
+    ADD COLUMN currency varchar(3) NOT NULL DEFAULT 'BDT';
+
+ALTER TABLE subscriptions
+    ALTER COLUMN package_price TYPE bigint USING round(COALESCE(package_price, 0) * 100)::bigint,
+    ALTER COLUMN package_price SET DEFAULT 0,
+    ALTER COLUMN package_price SET NOT NULL,
+    ALTER COLUMN monthly_discount TYPE bigint USING round(COALESCE(monthly_discount, 0) * 100)::bigint,
+    ALTER COLUMN monthly_discount SET DEFAULT 0,
+    ALTER COLUMN monthly_discount SET NOT NULL,
+    ALTER COLUMN due_amount TYPE bigint USING round(COALESCE(NULLIF(trim(due_amount), ''), '0')::numeric * 100)::bigint,
+    ALTER COLUMN due_amount SET DEFAULT 0,
+    ALTER COLUMN due_amount SET NOT NULL,
+    ADD COLUMN currency varchar(3) NOT NULL DEFAULT 'BDT';
+
+ALTER TABLE invoices
+    ALTER COLUMN amount TYPE bigint USING round(COALESCE(amount, 0) * 100)::bigint,
+    ALTER COLUMN amount SET DEFAULT 0,
+    ALTER COLUMN amount SET NOT NULL,
+    ADD COLUMN currency varchar(3) NOT NULL DEFAULT 'BDT';
+
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE bigint USING round(COALESCE(amount, 0) * 100)::bigint,
+    ALTER COLUMN amount SET DEFAULT 0,
+    ALTER COLUMN amount SET NOT NULL,
+    ADD COLUMN currency varchar(3) NOT NULL DEFAULT 'BDT';
+
+ALTER TABLE expenses
+    ALTER COLUMN amount TYPE bigint USING round(COALESCE(amount, 0) * 100)::bigint,
+    ALTER COLUMN amount SET DEFAULT 0,
+    ALTER COLUMN amount SET NOT NULL,
+    ADD COLUMN currency varchar(3) NOT NULL DEFAULT 'BDT';
+
+ALTER TABLE devices
+    ALTER COLUMN purchase_price TYPE bigint USING round(purchase_price * 100)::bigint;
+
+ALTER TABLE employees
+    ALTER COLUMN salary DROP DEFAULT,
+    ALTER COLUMN salary TYPE bigint USING round(salary * 100)::bigint,
+    ALTER COLUMN salary SET DEFAULT 0;
+
+ALTER TABLE salary_histories
+    ALTER COLUMN salary TYPE bigint USING round(salary * 100)::bigint,
+    ALTER COLUMN previous_salary DROP DEFAULT,
+    ALTER COLUMN previous_salary TYPE bigint USING round(previous_salary * 100)::bigint,
+    ALTER COLUMN previous_salary SET DEFAULT 0;
+
+ALTER TABLE salary_payouts
+    ALTER COLUMN amount TYPE bigint USING round(amount * 100)::bigint;
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_invoices_subscription_period;
+CREATE UNIQUE INDEX idx_invoices_subscription_period ON invoices (subscription_id, billing_period)
+    WHERE billing_period IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_invoices_due_date;
+DROP INDEX IF EXISTS idx_invoices_status;
+
+ALTER TABLE invoices
+    DROP COLUMN close_reason,
+    DROP COLUMN closed_by,
+    DROP COLUMN closed_at;
@@ -0,0 +1,12 @@
+ALTER TABLE invoices
+    ADD COLUMN closed_at timestamptz,
+    ADD COLUMN closed_by varchar(64),
+    ADD COLUMN close_reason text;
+
+CREATE INDEX IF NOT EXISTS idx_invoices_status ON invoices (status);
+CREATE INDEX IF NOT EXISTS idx_invoices_due_date ON invoices (due_date);
+
+-- A voided invoice was issued in error, so it no longer blocks the billing run from reissuing the period
+DROP INDEX IF EXISTS idx_invoices_subscription_period;
+CREATE UNIQUE INDEX idx_invoices_subscription_period ON invoices (subscription_id, billing_period)
+    WHERE billing_period IS NOT NULL AND status <> 'VOID';
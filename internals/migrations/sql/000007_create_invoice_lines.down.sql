@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_invoices_customer_period;
+DROP TABLE IF EXISTS invoice_lines;
@@ -0,0 +1,32 @@
+CREATE TABLE invoice_lines (
+    id text PRIMARY KEY,
+    invoice_id text NOT NULL REFERENCES invoices (id) ON DELETE CASCADE,
+    position integer NOT NULL DEFAULT 0,
+    type varchar(20) NOT NULL,
+    description text NOT NULL DEFAULT '',
+    subscription_id text,
+    quantity integer NOT NULL DEFAULT 1,
+    unit_price bigint NOT NULL DEFAULT 0,
+    amount bigint NOT NULL DEFAULT 0,
+    created_at timestamptz
+);
+CREATE INDEX idx_invoice_lines_invoice_id ON invoice_lines (invoice_id);
+CREATE INDEX idx_invoice_lines_subscription_id ON invoice_lines (subscription_id);
+
+-- Existing invoices get a single line carrying their whole amount, so totals stay derivable from lines
+INSERT INTO invoice_lines (id, invoice_id, position, type, description, subscription_id, quantity, unit_price, amount, created_at)
+SELECT gen_random_uuid()::text,
+       id,
+       0,
+       CASE WHEN subscription_id IS NULL THEN 'OTHER' ELSE 'SUBSCRIPTION' END,
+       CASE WHEN billing_period IS NULL THEN 'Invoice charge' ELSE 'Subscription charge (' || billing_period || ')' END,
+       subscription_id,
+       1,
+       amount,
+       amount,
+       COALESCE(created_at, now())
+FROM invoices;
+
+-- Consolidated invoices bill several subscriptions at once and carry no subscription of their own
+CREATE UNIQUE INDEX idx_invoices_customer_period ON invoices (customer_id, billing_period)
+    WHERE billing_period IS NOT NULL AND subscription_id IS NULL AND status <> 'VOID';
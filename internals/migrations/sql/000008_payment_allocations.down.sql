@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS customer_credits;
+DROP TABLE IF EXISTS payment_allocations;
+ALTER TABLE invoices DROP COLUMN paid_amount;
@@ -0,0 +1,42 @@
+ALTER TABLE invoices ADD COLUMN paid_amount bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE payment_allocations (
+    id text PRIMARY KEY,
+    payment_id text NOT NULL REFERENCES payments (id),
+    invoice_id text NOT NULL REFERENCES invoices (id),
+    amount bigint NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX idx_payment_allocations_payment_id ON payment_allocations (payment_id);
+CREATE INDEX idx_payment_allocations_invoice_id ON payment_allocations (invoice_id);
+
+CREATE TABLE customer_credits (
+    id text PRIMARY KEY,
+    customer_id text NOT NULL,
+    amount bigint NOT NULL,
+    currency varchar(3) NOT NULL DEFAULT 'BDT',
+    payment_id text REFERENCES payments (id),
+    invoice_id text REFERENCES invoices (id),
+    description text NOT NULL DEFAULT '',
+    created_at timestamptz
+);
+CREATE INDEX idx_customer_credits_customer_id ON customer_credits (customer_id);
+CREATE INDEX idx_customer_credits_payment_id ON customer_credits (payment_id);
+CREATE INDEX idx_customer_credits_invoice_id ON customer_credits (invoice_id);
+
+-- Payments posted against a single invoice become allocations of their full amount
+INSERT INTO payment_allocations (id, payment_id, invoice_id, amount, created_at)
+SELECT gen_random_uuid()::text, p.id, p.invoice_id, p.amount, COALESCE(p.paid_at, now())
+FROM payments p
+JOIN invoices i ON i.id = p.invoice_id
+WHERE p.amount > 0;
+
+UPDATE invoices i
+SET paid_amount = CASE
+        WHEN i.status = 'PAID' THEN i.amount
+        ELSE LEAST(i.amount, a.total)
+    END
+FROM (SELECT invoice_id, SUM(amount) AS total FROM payment_allocations GROUP BY invoice_id) a
+WHERE a.invoice_id = i.id;
+
+UPDATE invoices SET paid_amount = amount WHERE status = 'PAID' AND paid_amount = 0;
@@ -0,0 +1,42 @@
+CREATE TABLE customer_ledger_entries (
+    id text PRIMARY KEY,
+    customer_id text NOT NULL,
+    type varchar(20) NOT NULL,
+    debit bigint NOT NULL DEFAULT 0,
+    credit bigint NOT NULL DEFAULT 0,
+    currency varchar(3) NOT NULL DEFAULT 'BDT',
+    invoice_id text REFERENCES invoices (id),
+    payment_id text REFERENCES payments (id),
+    description text NOT NULL DEFAULT '',
+    posted_at timestamptz NOT NULL,
+    posted_by varchar(64) NOT NULL DEFAULT 'system',
+    created_at timestamptz,
+    CONSTRAINT chk_customer_ledger_entries_one_side CHECK (debit >= 0 AND credit >= 0 AND (debit = 0 OR credit = 0))
+);
+CREATE INDEX idx_customer_ledger_entries_customer_posted ON customer_ledger_entries (customer_id, posted_at);
+CREATE INDEX idx_customer_ledger_entries_invoice_id ON customer_ledger_entries (invoice_id);
+CREATE INDEX idx_customer_ledger_entries_payment_id ON customer_ledger_entries (payment_id);
+
+-- Rebuild the ledger from history: every invoice is a debit when issued, cancelled and void
+-- invoices are reversed when closed, and every incoming payment is a credit
+INSERT INTO customer_ledger_entries (id, customer_id, type, debit, currency, invoice_id, description, posted_at, created_at)
+SELECT gen_random_uuid()::text, customer_id, 'INVOICE', amount, currency, id,
+       CASE WHEN billing_period IS NULL THEN 'Invoice' ELSE 'Invoice for ' || billing_period END,
+       COALESCE(created_at, due_date, now()), now()
+FROM invoices
+WHERE customer_id IS NOT NULL AND amount > 0;
+
+INSERT INTO customer_ledger_entries (id, customer_id, type, credit, currency, invoice_id, description, posted_at, created_at)
+SELECT gen_random_uuid()::text, customer_id, 'ADJUSTMENT', amount, currency, id,
+       CASE WHEN status = 'VOID' THEN 'Invoice voided' ELSE 'Invoice cancelled' END,
+       COALESCE(closed_at, updated_at, now()), now()
+FROM invoices
+WHERE customer_id IS NOT NULL AND amount > 0 AND status IN ('CANCELLED', 'VOID');
+
+INSERT INTO customer_ledger_entries (id, customer_id, type, credit, currency, payment_id, description, posted_at, created_at)
+SELECT gen_random_uuid()::text, COALESCE(p.customer_id, i.customer_id), 'PAYMENT', p.amount, p.currency, p.id,
+       'Payment received', COALESCE(p.paid_at, p.created_at, now()), now()
+FROM payments p
+LEFT JOIN invoices i ON i.id = p.invoice_id
+WHERE COALESCE(p.customer_id, i.customer_id) IS NOT NULL AND p.amount > 0
+  AND (p.type IS NULL OR p.type <> 'OUTGOING');
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_payments_gateway_txn;
+ALTER TABLE payments DROP COLUMN gateway_transaction_id;
+ALTER TABLE payments DROP COLUMN gateway;
+DROP TABLE IF EXISTS gateway_transactions;
@@ -0,0 +1,24 @@
+CREATE TABLE gateway_transactions (
+    id text PRIMARY KEY,
+    gateway varchar(32) NOT NULL,
+    transaction_id text NOT NULL,
+    invoice_id text REFERENCES invoices (id),
+    customer_id text NOT NULL,
+    amount bigint NOT NULL,
+    currency varchar(3) NOT NULL DEFAULT 'BDT',
+    status varchar(20) NOT NULL DEFAULT 'PENDING',
+    redirect_url text NOT NULL DEFAULT '',
+    payment_id text REFERENCES payments (id),
+    completed_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX idx_gateway_transactions_gateway_txn ON gateway_transactions (gateway, transaction_id);
+CREATE INDEX idx_gateway_transactions_invoice_id ON gateway_transactions (invoice_id);
+CREATE INDEX idx_gateway_transactions_customer_id ON gateway_transactions (customer_id);
+
+ALTER TABLE payments ADD COLUMN gateway varchar(32);
+ALTER TABLE payments ADD COLUMN gateway_transaction_id text;
+-- A gateway transaction can only ever be posted as one payment
+CREATE UNIQUE INDEX idx_payments_gateway_txn ON payments (gateway, gateway_transaction_id)
+    WHERE gateway_transaction_id IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS subscription_renewals;
+DROP INDEX IF EXISTS idx_payments_reversal_of;
+ALTER TABLE payments DROP COLUMN reversed_at;
+ALTER TABLE payments DROP COLUMN reason;
+ALTER TABLE payments DROP COLUMN reversal_of;
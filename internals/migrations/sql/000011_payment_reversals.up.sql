@@ -0,0 +1,18 @@
+ALTER TABLE payments ADD COLUMN reversal_of text REFERENCES payments (id);
+ALTER TABLE payments ADD COLUMN reason text;
+ALTER TABLE payments ADD COLUMN reversed_at timestamptz;
+-- A payment can be reversed once
+CREATE UNIQUE INDEX idx_payments_reversal_of ON payments (reversal_of) WHERE reversal_of IS NOT NULL;
+
+CREATE TABLE subscription_renewals (
+    id text PRIMARY KEY,
+    subscription_id text NOT NULL REFERENCES subscriptions (id),
+    invoice_id text NOT NULL REFERENCES invoices (id),
+    previous_paid_until timestamptz NOT NULL,
+    previous_renewal_date timestamptz NOT NULL,
+    previous_due_amount bigint NOT NULL DEFAULT 0,
+    paid_until timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_subscription_renewals_invoice_subscription ON subscription_renewals (invoice_id, subscription_id);
+CREATE INDEX idx_subscription_renewals_subscription_id ON subscription_renewals (subscription_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_payments_receipt_number;
+DROP INDEX IF EXISTS idx_invoices_number;
+ALTER TABLE payments DROP COLUMN receipt_number;
+ALTER TABLE invoices DROP COLUMN number;
+DROP TABLE IF EXISTS document_sequences;
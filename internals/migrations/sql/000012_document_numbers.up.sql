@@ -0,0 +1,54 @@
+CREATE TABLE document_sequences (
+    prefix varchar(8) NOT NULL,
+    year integer NOT NULL,
+    last_value bigint NOT NULL,
+    PRIMARY KEY (prefix, year)
+);
+
+ALTER TABLE invoices ADD COLUMN number varchar(32);
+ALTER TABLE payments ADD COLUMN receipt_number varchar(32);
+
+-- Number existing invoices and incoming payments in the order they were created
+WITH numbered AS (
+    SELECT id,
+           EXTRACT(YEAR FROM COALESCE(created_at, due_date, now()))::int AS year,
+           ROW_NUMBER() OVER (
+               PARTITION BY EXTRACT(YEAR FROM COALESCE(created_at, due_date, now()))
+               ORDER BY created_at, id
+           ) AS n
+    FROM invoices
+)
+UPDATE invoices i
+SET number = 'INV-' || numbered.year || '-' || LPAD(numbered.n::text, GREATEST(6, LENGTH(numbered.n::text)), '0')
+FROM numbered
+WHERE numbered.id = i.id;
+
+WITH numbered AS (
+    SELECT id,
+           EXTRACT(YEAR FROM COALESCE(paid_at, created_at, now()))::int AS year,
+           ROW_NUMBER() OVER (
+               PARTITION BY EXTRACT(YEAR FROM COALESCE(paid_at, created_at, now()))
+               ORDER BY paid_at, created_at, id
+           ) AS n
+    FROM payments
+    WHERE type IS NULL OR type = 'INCOMING'
+)
+UPDATE payments p
+SET receipt_number = 'RCT-' || numbered.year || '-' || LPAD(numbered.n::text, GREATEST(6, LENGTH(numbered.n::text)), '0')
+FROM numbered
+WHERE numbered.id = p.id;
+
+INSERT INTO document_sequences (prefix, year, last_value)
+SELECT 'INV', split_part(number, '-', 2)::int, MAX(split_part(number, '-', 3)::bigint)
+FROM invoices
+WHERE number IS NOT NULL
+GROUP BY split_part(number, '-', 2);
+
+INSERT INTO document_sequences (prefix, year, last_value)
+SELECT 'RCT', split_part(receipt_number, '-', 2)::int, MAX(split_part(receipt_number, '-', 3)::bigint)
+FROM payments
+WHERE receipt_number IS NOT NULL
+GROUP BY split_part(receipt_number, '-', 2);
+
+CREATE UNIQUE INDEX idx_invoices_number ON invoices (number);
+CREATE UNIQUE INDEX idx_payments_receipt_number ON payments (receipt_number);
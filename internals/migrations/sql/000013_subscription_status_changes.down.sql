@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS subscription_status_changes;
+DROP INDEX IF EXISTS idx_subscriptions_status;
@@ -0,0 +1,18 @@
+-- Statuses written before the lifecycle rules were free-form; fold them onto the known values
+UPDATE subscriptions SET status = 'Active' WHERE status IS NULL OR status = '' OR lower(status) = 'active';
+UPDATE subscriptions SET status = 'Expired' WHERE lower(status) = 'expired';
+UPDATE subscriptions SET status = 'Suspended' WHERE lower(status) = 'suspended';
+UPDATE subscriptions SET status = 'Cancelled' WHERE lower(status) IN ('cancelled', 'canceled');
+UPDATE subscriptions SET status = 'PendingActivation' WHERE lower(status) IN ('pendingactivation', 'pending');
+CREATE INDEX IF NOT EXISTS idx_subscriptions_status ON subscriptions (status);
+
+CREATE TABLE subscription_status_changes (
+    id text PRIMARY KEY,
+    subscription_id text NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    from_status varchar(20) NOT NULL,
+    to_status varchar(20) NOT NULL,
+    reason text,
+    changed_by varchar(64),
+    changed_at timestamptz NOT NULL
+);
+CREATE INDEX idx_subscription_status_changes_subscription_id ON subscription_status_changes (subscription_id, changed_at);
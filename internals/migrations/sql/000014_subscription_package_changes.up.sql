@@ -0,0 +1,27 @@
+CREATE TABLE subscription_package_changes (
+    id text PRIMARY KEY,
+    subscription_id text NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    from_package_id text NOT NULL,
+    to_package_id text NOT NULL,
+    from_price bigint NOT NULL DEFAULT 0,
+    to_price bigint NOT NULL DEFAULT 0,
+    kind varchar(20) NOT NULL,
+    status varchar(20) NOT NULL,
+    effective_at timestamptz NOT NULL,
+    prorated_days integer NOT NULL DEFAULT 0,
+    cycle_days integer NOT NULL DEFAULT 0,
+    credit bigint NOT NULL DEFAULT 0,
+    charge bigint NOT NULL DEFAULT 0,
+    currency varchar(3) NOT NULL DEFAULT 'BDT',
+    invoice_id text REFERENCES invoices (id),
+    reason text,
+    changed_by varchar(64),
+    applied_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX idx_subscription_package_changes_subscription_id ON subscription_package_changes (subscription_id);
+-- At most one change can be waiting for the next cycle
+CREATE UNIQUE INDEX idx_subscription_package_changes_scheduled ON subscription_package_changes (subscription_id)
+    WHERE status = 'SCHEDULED';
+CREATE INDEX idx_subscription_package_changes_due ON subscription_package_changes (effective_at)
+    WHERE status = 'SCHEDULED';
@@ -0,0 +1,23 @@
+CREATE TABLE subscription_holds (
+    id text PRIMARY KEY,
+    subscription_id text NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    start_date timestamptz NOT NULL,
+    end_date timestamptz NOT NULL,
+    status varchar(20) NOT NULL,
+    fee bigint NOT NULL DEFAULT 0,
+    currency varchar(3) NOT NULL DEFAULT 'BDT',
+    invoice_id text REFERENCES invoices (id),
+    days_held integer NOT NULL DEFAULT 0,
+    reason text,
+    created_by varchar(64),
+    started_at timestamptz,
+    ended_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CHECK (end_date > start_date)
+);
+CREATE INDEX idx_subscription_holds_subscription_id ON subscription_holds (subscription_id);
+-- A subscription has at most one hold waiting or running
+CREATE UNIQUE INDEX idx_subscription_holds_open ON subscription_holds (subscription_id)
+    WHERE status IN ('SCHEDULED', 'ACTIVE');
+CREATE INDEX idx_subscription_holds_status ON subscription_holds (status);
@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS type varchar(20);
+-- Existing subscriptions take the type of the package they are on
+UPDATE subscriptions s SET type = p.type FROM packages p WHERE p.id = s.package_id AND s.type IS NULL;
@@ -0,0 +1,16 @@
+-- No foreign key on device_id: the history outlives a deleted device
+CREATE TABLE device_events (
+    id text PRIMARY KEY,
+    device_id text NOT NULL,
+    type varchar(20) NOT NULL,
+    from_status varchar(20),
+    to_status varchar(20),
+    subscription_id text,
+    building_id text,
+    note text,
+    actor varchar(64),
+    occurred_at timestamptz NOT NULL
+);
+CREATE INDEX idx_device_events_device_id ON device_events (device_id, occurred_at);
+CREATE INDEX idx_device_events_subscription_id ON device_events (subscription_id);
+CREATE INDEX idx_device_events_building_id ON device_events (building_id);
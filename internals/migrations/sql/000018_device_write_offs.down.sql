@@ -0,0 +1 @@
+ALTER TABLE devices DROP COLUMN loss_expense_id;
@@ -0,0 +1 @@
+ALTER TABLE devices ADD COLUMN loss_expense_id text REFERENCES expenses (id);
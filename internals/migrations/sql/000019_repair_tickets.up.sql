@@ -0,0 +1,27 @@
+-- No foreign key on device_id: like the device history, tickets outlive a deleted device
+CREATE TABLE repair_tickets (
+    id text PRIMARY KEY,
+    device_id text NOT NULL,
+    status varchar(20) NOT NULL,
+    fault text NOT NULL,
+    vendor varchar(100) NOT NULL,
+    sent_date timestamptz NOT NULL,
+    returned_date timestamptz,
+    cost bigint NOT NULL DEFAULT 0,
+    currency varchar(3) NOT NULL DEFAULT 'BDT',
+    expense_id text REFERENCES expenses (id),
+    outcome varchar(20),
+    serial_number varchar(50),
+    replacement_serial_number varchar(50),
+    note text,
+    opened_by varchar(64),
+    closed_by varchar(64),
+    closed_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CHECK (returned_date IS NULL OR returned_date >= sent_date)
+);
+CREATE INDEX idx_repair_tickets_device_id ON repair_tickets (device_id);
+CREATE INDEX idx_repair_tickets_status ON repair_tickets (status);
+-- A device is with at most one vendor at a time
+CREATE UNIQUE INDEX idx_repair_tickets_open ON repair_tickets (device_id) WHERE status = 'OPEN';
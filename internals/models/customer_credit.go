@@ -0,0 +1,19 @@
+package models
+
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+// CustomerCredit is one movement on a customer's credit balance: a positive amount is credit
+// kept from an overpayment, a negative amount is credit used to settle an invoice
+type CustomerCredit struct {
+	ID          string         `gorm:"primaryKey" json:"id"`
+	CustomerID  string         `gorm:"index" json:"customerId"`
+	Amount      money.Amount   `json:"amount"`
+	Currency    money.Currency `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	PaymentID   *string        `gorm:"index" json:"paymentId,omitempty"`
+	InvoiceID   *string        `gorm:"index" json:"invoiceId,omitempty"`
+	Description string         `json:"description"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
 
 type DeviceType string
 
@@ -13,6 +16,14 @@ const (
 	Camera DeviceType = "CAMERA"
 )
 
+func (t DeviceType) IsValid() bool {
+	switch t {
+	case ONU, Switch, OLT, Router, Server, Camera:
+		return true
+	}
+	return false
+}
+
 type DeviceUsage string
 
 const (
@@ -29,8 +40,41 @@ const (
 	PendingCollection DeviceStatus = "PendingCollection"
 	Damaged           DeviceStatus = "Damaged"
 	UnderRepair       DeviceStatus = "UnderRepair"
+	WrittenOff        DeviceStatus = "WrittenOff"
 )
 
+// deviceTransitions lists the statuses each status may move to. A device leaves a customer or
+// building through collection, unless staff bring it straight back to stock. Written-off devices
+// stay written off.
+var deviceTransitions = map[DeviceStatus][]DeviceStatus{
+	InStock:           {Assigned, Damaged},
+	Assigned:          {PendingCollection, InStock},
+	PendingCollection: {InStock, Damaged},
+	Damaged:           {UnderRepair, WrittenOff},
+	UnderRepair:       {InStock, Damaged},
+	WrittenOff:        nil,
+}
+
+func (s DeviceStatus) IsValid() bool {
+	_, ok := deviceTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether the transition table allows moving from s to next
+func (s DeviceStatus) CanTransitionTo(next DeviceStatus) bool {
+	for _, allowed := range deviceTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAssigned reports whether the device is with a subscription or building, including while it awaits collection
+func (s DeviceStatus) IsAssigned() bool {
+	return s == Assigned || s == PendingCollection
+}
+
 type Device struct {
 	ID           string     `gorm:"primaryKey" json:"id"`
 	Type         DeviceType `gorm:"type:varchar(20)" json:"type"`
@@ -41,8 +85,10 @@ type Device struct {
 	Usage  DeviceUsage  `gorm:"type:varchar(20)" json:"usage"`
 	Status DeviceStatus `gorm:"type:varchar(20)" json:"status"`
 
-	PurchasePrice *float64   `json:"purchasePrice,omitempty"`
-	PurchaseDate  *time.Time `json:"purchaseDate,omitempty"`
+	PurchasePrice *money.Amount `json:"purchasePrice,omitempty"`
+	PurchaseDate  *time.Time    `json:"purchaseDate,omitempty"`
+	// LossExpenseID is the capital expense posted for the device's book value when it was written off
+	LossExpenseID *string `json:"lossExpenseId,omitempty"`
 
 	SubscriptionID *string    `gorm:"index" json:"subscriptionId,omitempty"`
 	BuildingID     *string    `gorm:"index" json:"buildingId,omitempty"`
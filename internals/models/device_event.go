@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+type DeviceEventType string
+
+const (
+	DeviceEventAssigned      DeviceEventType = "ASSIGNED"
+	DeviceEventUnassigned    DeviceEventType = "UNASSIGNED"
+	DeviceEventStatusChanged DeviceEventType = "STATUS_CHANGED"
+	DeviceEventRepair        DeviceEventType = "REPAIR"
+	DeviceEventCollection    DeviceEventType = "COLLECTION"
+	DeviceEventWrittenOff    DeviceEventType = "WRITTEN_OFF"
+)
+
+// DeviceEvent records one movement of a device: where it was assigned or taken from, how its
+// status changed, who did it and when. Events are kept after the device itself is deleted.
+type DeviceEvent struct {
+	ID             string          `gorm:"primaryKey" json:"id"`
+	DeviceID       string          `gorm:"index" json:"deviceId"`
+	Type           DeviceEventType `gorm:"type:varchar(20)" json:"type"`
+	FromStatus     DeviceStatus    `gorm:"type:varchar(20)" json:"fromStatus"`
+	ToStatus       DeviceStatus    `gorm:"type:varchar(20)" json:"toStatus"`
+	SubscriptionID *string         `gorm:"index" json:"subscriptionId,omitempty"`
+	BuildingID     *string         `gorm:"index" json:"buildingId,omitempty"`
+	Note           string          `json:"note,omitempty"`
+	Actor          string          `gorm:"type:varchar(64)" json:"actor"`
+	OccurredAt     time.Time       `json:"occurredAt"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceStatusTransitions(t *testing.T) {
+	testCases := []struct {
+		from, to DeviceStatus
+		allowed  bool
+	}{
+		{InStock, Assigned, true},
+		{Assigned, PendingCollection, true},
+		{Assigned, InStock, true},
+		{PendingCollection, InStock, true},
+		{PendingCollection, Damaged, true},
+		{Damaged, UnderRepair, true},
+		{UnderRepair, InStock, true},
+		{Damaged, WrittenOff, true},
+		{UnderRepair, WrittenOff, false},
+		{WrittenOff, InStock, false},
+		{Assigned, Assigned, false},
+		{Damaged, Assigned, false},
+		{Damaged, InStock, false},
+		{UnderRepair, Assigned, false},
+		{PendingCollection, Assigned, false},
+		{"instock", Assigned, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.allowed, tc.from.CanTransitionTo(tc.to), "%s -> %s", tc.from, tc.to)
+	}
+
+	assert.True(t, PendingCollection.IsAssigned())
+	assert.False(t, UnderRepair.IsAssigned())
+	assert.True(t, WrittenOff.IsValid())
+	assert.False(t, DeviceStatus("Lost").IsValid())
+}
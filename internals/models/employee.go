@@ -1,12 +1,55 @@
 package models
 
-//type Employee struct {
-//	ID       string `json:"id"`
-//	Name     string `json:"name"`
-//	Role     string `json:"role"`
-//	Salary   int    `json:"salary"`
-//	JoinDate string `json:"joinDate"`
-//
-//	CreatedAt string `json:"createdAt"`
-//	UpdatedAt string `json:"updatedAt"`
-//}
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+type EmployeeRole string
+
+const (
+	RoleAdmin    EmployeeRole = "Admin"
+	RoleEmployee EmployeeRole = "Employee"
+)
+
+func (r EmployeeRole) IsValid() bool {
+	return r == RoleAdmin || r == RoleEmployee
+}
+
+type Employee struct {
+	ID           string       `gorm:"primaryKey" json:"id"`
+	Name         string       `gorm:"type:varchar(100)" json:"name"`
+	Mobile       string       `gorm:"uniqueIndex;type:varchar(20)" json:"mobile"`
+	Email        *string      `gorm:"uniqueIndex;null" json:"email,omitempty"`
+	PasswordHash string       `gorm:"type:varchar(100)" json:"-"`
+	Role         EmployeeRole `gorm:"type:varchar(20)" json:"role"`
+	IsActive     bool         `json:"isActive"`
+	Salary       money.Amount `json:"salary"`
+	JoinDate     time.Time    `json:"joinDate"`
+	LastLoginAt  *time.Time   `json:"lastLoginAt,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+type SalaryHistory struct {
+	ID             string       `gorm:"primaryKey" json:"id"`
+	EmployeeID     string       `gorm:"index" json:"employeeId"`
+	Salary         money.Amount `json:"salary"`
+	PreviousSalary money.Amount `json:"previousSalary"`
+	EffectiveFrom  time.Time    `json:"effectiveFrom"`
+	Reason         string       `json:"reason,omitempty"`
+	ChangedBy      string       `gorm:"type:varchar(64)" json:"changedBy"`
+	CreatedAt      time.Time    `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+type SalaryPayout struct {
+	ID         string       `gorm:"primaryKey" json:"id"`
+	EmployeeID string       `gorm:"index" json:"employeeId"`
+	Period     string       `gorm:"type:varchar(7)" json:"period"`
+	Amount     money.Amount `json:"amount"`
+	ExpenseID  string       `gorm:"index" json:"expenseId"`
+	PaidAt     time.Time    `json:"paidAt"`
+	PaidBy     string       `gorm:"type:varchar(64)" json:"paidBy"`
+	CreatedAt  time.Time    `gorm:"autoCreateTime" json:"createdAt"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
 
 type ExpenseType string
 
@@ -10,11 +13,12 @@ const (
 )
 
 type Expense struct {
-	ID          string      `gorm:"primaryKey" json:"id"`
-	Amount      float64     `json:"amount"`
-	Type        ExpenseType `json:"type"`
-	Description string      `json:"description"`
-	PaidAt      time.Time   `json:"paidAt"`
-	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt   time.Time   `gorm:"autoUpdateTime" json:"updatedAt"`
+	ID          string         `gorm:"primaryKey" json:"id"`
+	Amount      money.Amount   `json:"amount"`
+	Currency    money.Currency `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	Type        ExpenseType    `json:"type"`
+	Description string         `json:"description"`
+	PaidAt      time.Time      `json:"paidAt"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updatedAt"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+type GatewayTransactionStatus string
+
+const (
+	GatewayPending   GatewayTransactionStatus = "PENDING"
+	GatewayCompleted GatewayTransactionStatus = "COMPLETED"
+	GatewayFailed    GatewayTransactionStatus = "FAILED"
+	GatewayCancelled GatewayTransactionStatus = "CANCELLED"
+)
+
+// GatewayTransaction is a checkout opened at a payment gateway and what came of it.
+// PaymentID is set once the gateway confirms the money and the payment is posted.
+type GatewayTransaction struct {
+	ID            string                   `gorm:"primaryKey" json:"id"`
+	Gateway       string                   `gorm:"type:varchar(32)" json:"gateway"`
+	TransactionID string                   `json:"transactionId"`
+	InvoiceID     *string                  `gorm:"index" json:"invoiceId,omitempty"`
+	CustomerID    string                   `gorm:"index" json:"customerId"`
+	Amount        money.Amount             `json:"amount"`
+	Currency      money.Currency           `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	Status        GatewayTransactionStatus `gorm:"type:varchar(20)" json:"status"`
+	RedirectURL   string                   `json:"redirectUrl"`
+	PaymentID     *string                  `json:"paymentId,omitempty"`
+	CompletedAt   *time.Time               `json:"completedAt,omitempty"`
+	CreatedAt     time.Time                `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt     time.Time                `gorm:"autoUpdateTime" json:"updatedAt"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
 
 type InvoiceStatus string
 
@@ -9,16 +12,43 @@ const (
 	InvoicePaid      InvoiceStatus = "PAID"
 	InvoiceOverdue   InvoiceStatus = "OVERDUE"
 	InvoiceCancelled InvoiceStatus = "CANCELLED"
+	InvoiceVoid      InvoiceStatus = "VOID"
 )
 
+func (s InvoiceStatus) IsValid() bool {
+	switch s {
+	case InvoicePending, InvoicePaid, InvoiceOverdue, InvoiceCancelled, InvoiceVoid:
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether the invoice still awaits payment and may be edited, cancelled or voided
+func (s InvoiceStatus) IsOpen() bool {
+	return s == InvoicePending || s == InvoiceOverdue
+}
+
 type Invoice struct {
-	ID             string        `gorm:"primaryKey" json:"id"`
-	CustomerID     string        `gorm:"index" json:"customerId"`
-	SubscriptionID *string       `gorm:"index" json:"subscriptionId,omitempty"`
-	Amount         float64       `json:"amount"`
-	Status         InvoiceStatus `json:"status"`
-	DueDate        time.Time     `json:"dueDate"`
-	PaidDate       *time.Time    `json:"paidDate,omitempty"`
-	CreatedAt      time.Time     `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt      time.Time     `gorm:"autoUpdateTime" json:"updatedAt"`
+	ID             string         `gorm:"primaryKey" json:"id"`
+	Number         *string        `gorm:"type:varchar(32);uniqueIndex" json:"number,omitempty"`
+	CustomerID     string         `gorm:"index" json:"customerId"`
+	SubscriptionID *string        `gorm:"index" json:"subscriptionId,omitempty"`
+	Amount         money.Amount   `json:"amount"`
+	PaidAmount     money.Amount   `json:"paidAmount"`
+	Currency       money.Currency `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	Status         InvoiceStatus  `gorm:"index" json:"status"`
+	DueDate        time.Time      `gorm:"index" json:"dueDate"`
+	PaidDate       *time.Time     `json:"paidDate,omitempty"`
+	BillingPeriod  *string        `gorm:"type:varchar(7)" json:"billingPeriod,omitempty"`
+	ClosedAt       *time.Time     `json:"closedAt,omitempty"`
+	ClosedBy       *string        `gorm:"type:varchar(64)" json:"closedBy,omitempty"`
+	CloseReason    *string        `json:"closeReason,omitempty"`
+	Lines          []InvoiceLine  `gorm:"foreignKey:InvoiceID" json:"lines,omitempty"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// Balance is what is still owed on the invoice
+func (i *Invoice) Balance() money.Amount {
+	return money.Max(i.Amount-i.PaidAmount, 0)
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+type InvoiceLineType string
+
+const (
+	LineSubscription InvoiceLineType = "SUBSCRIPTION"
+	LineDeviceFee    InvoiceLineType = "DEVICE_FEE"
+	LineInstallation InvoiceLineType = "INSTALLATION"
+	LineDiscount     InvoiceLineType = "DISCOUNT"
+	LineArrears      InvoiceLineType = "ARREARS"
+	LineLateFee      InvoiceLineType = "LATE_FEE"
+	LineOther        InvoiceLineType = "OTHER"
+	// LineProration is a part-month charge or, when negative, credit from a package change
+	LineProration InvoiceLineType = "PRORATION"
+)
+
+func (t InvoiceLineType) IsValid() bool {
+	switch t {
+	case LineSubscription, LineDeviceFee, LineInstallation, LineDiscount, LineArrears, LineLateFee, LineOther, LineProration:
+		return true
+	}
+	return false
+}
+
+// IsServiceCharge reports whether lines of this type pay for, or discount, a subscription's service
+func (t InvoiceLineType) IsServiceCharge() bool {
+	return t == LineSubscription || t == LineProration || t == LineDiscount
+}
+
+// InvoiceLine is one charge or credit on an invoice. Amount is Quantity x UnitPrice and is
+// negative for discounts and prorated credits.
+type InvoiceLine struct {
+	ID             string          `gorm:"primaryKey" json:"id"`
+	InvoiceID      string          `gorm:"index" json:"invoiceId"`
+	Position       int             `json:"position"`
+	Type           InvoiceLineType `gorm:"type:varchar(20)" json:"type"`
+	Description    string          `json:"description"`
+	SubscriptionID *string         `gorm:"index" json:"subscriptionId,omitempty"`
+	Quantity       int             `json:"quantity"`
+	UnitPrice      money.Amount    `json:"unitPrice"`
+	Amount         money.Amount    `json:"amount"`
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"createdAt"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+type LedgerEntryType string
+
+const (
+	LedgerInvoice    LedgerEntryType = "INVOICE"
+	LedgerPayment    LedgerEntryType = "PAYMENT"
+	LedgerAdjustment LedgerEntryType = "ADJUSTMENT"
+)
+
+// LedgerEntry is one posting on a customer's account. Invoices post debits (the customer owes
+// more), payments and adjustments in the customer's favour post credits. The balance of the
+// account is the sum of debits minus credits; a negative balance is money held for the customer.
+type LedgerEntry struct {
+	ID          string          `gorm:"primaryKey" json:"id"`
+	CustomerID  string          `gorm:"index" json:"customerId"`
+	Type        LedgerEntryType `gorm:"type:varchar(20)" json:"type"`
+	Debit       money.Amount    `json:"debit"`
+	Credit      money.Amount    `json:"credit"`
+	Currency    money.Currency  `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	InvoiceID   *string         `gorm:"index" json:"invoiceId,omitempty"`
+	PaymentID   *string         `gorm:"index" json:"paymentId,omitempty"`
+	Description string          `json:"description"`
+	PostedAt    time.Time       `gorm:"index" json:"postedAt"`
+	PostedBy    string          `gorm:"type:varchar(64)" json:"postedBy"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+func (LedgerEntry) TableName() string {
+	return "customer_ledger_entries"
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
 
 type PackageType string
 
@@ -17,11 +20,12 @@ const (
 )
 
 type Package struct {
-	ID       string      `gorm:"primaryKey" json:"id"`
-	Type     PackageType `json:"type"`
-	Name     string      `gorm:"type:varchar(100)" json:"name"`
-	Price    float64     `json:"price"`
-	IsActive bool        `json:"isActive"`
+	ID       string         `gorm:"primaryKey" json:"id"`
+	Type     PackageType    `json:"type"`
+	Name     string         `gorm:"type:varchar(100)" json:"name"`
+	Price    money.Amount   `json:"price"`
+	Currency money.Currency `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	IsActive bool           `json:"isActive"`
 
 	Bandwidth     *int           `json:"bandwidth,omitempty"`
 	BandwidthType *BandwidthType `json:"bandwidthType,omitempty"`
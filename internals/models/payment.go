@@ -1,22 +1,45 @@
 package models
 
-import "time"
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
 
 type PaymentType string
 
 const (
 	PaymentIncoming PaymentType = "INCOMING"
 	PaymentOutgoing PaymentType = "OUTGOING"
+	// PaymentReversal is the negative payment that undoes an incoming payment
+	PaymentReversal PaymentType = "REVERSAL"
 )
 
 type Payment struct {
-	ID          string      `gorm:"primaryKey" json:"id"`
-	InvoiceID   *string     `gorm:"index" json:"invoiceId,omitempty"`
-	CustomerID  *string     `gorm:"index" json:"customerId,omitempty"`
-	Amount      float64     `json:"amount"`
-	Type        PaymentType `json:"type"`
-	Description string      `json:"description"`
-	PaidAt      time.Time   `json:"paidAt"`
-	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt   time.Time   `gorm:"autoUpdateTime" json:"updatedAt"`
+	ID            string         `gorm:"primaryKey" json:"id"`
+	ReceiptNumber *string        `gorm:"type:varchar(32);uniqueIndex" json:"receiptNumber,omitempty"`
+	InvoiceID     *string        `gorm:"index" json:"invoiceId,omitempty"`
+	CustomerID    *string        `gorm:"index" json:"customerId,omitempty"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	Type          PaymentType    `json:"type"`
+	Description   string         `json:"description"`
+	// Gateway and GatewayTransactionID identify payments collected through a payment gateway
+	Gateway              *string `gorm:"type:varchar(32)" json:"gateway,omitempty"`
+	GatewayTransactionID *string `json:"gatewayTransactionId,omitempty"`
+	// ReversalOf links a reversal to the payment it undoes; ReversedAt is set on that payment
+	ReversalOf *string    `gorm:"index" json:"reversalOf,omitempty"`
+	Reason     *string    `json:"reason,omitempty"`
+	ReversedAt *time.Time `json:"reversedAt,omitempty"`
+	PaidAt     time.Time  `json:"paidAt"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// PaymentAllocation records how much of a payment settled one invoice
+type PaymentAllocation struct {
+	ID        string       `gorm:"primaryKey" json:"id"`
+	PaymentID string       `gorm:"index" json:"paymentId"`
+	InvoiceID string       `gorm:"index" json:"invoiceId"`
+	Amount    money.Amount `json:"amount"`
+	CreatedAt time.Time    `gorm:"autoCreateTime" json:"createdAt"`
 }
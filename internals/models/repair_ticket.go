@@ -0,0 +1,58 @@
+package models
+
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+type RepairTicketStatus string
+
+const (
+	RepairOpen   RepairTicketStatus = "OPEN"
+	RepairClosed RepairTicketStatus = "CLOSED"
+)
+
+type RepairOutcome string
+
+const (
+	// RepairRepaired: the device came back working and returns to stock
+	RepairRepaired RepairOutcome = "REPAIRED"
+	// RepairReplaced: the vendor swapped the unit; the device record carries on under the
+	// replacement's serial number and returns to stock
+	RepairReplaced RepairOutcome = "REPLACED"
+	// RepairScrapped: the device cannot be fixed and is written off
+	RepairScrapped RepairOutcome = "SCRAPPED"
+)
+
+func (o RepairOutcome) IsValid() bool {
+	switch o {
+	case RepairRepaired, RepairReplaced, RepairScrapped:
+		return true
+	}
+	return false
+}
+
+// RepairTicket follows a damaged device sent to a vendor or technician, RMA included, from the day
+// it is sent until it comes back. The device is under repair while the ticket is open. Closing the
+// ticket records the outcome, posts the repair cost as ExpenseID and moves the device on.
+type RepairTicket struct {
+	ID                      string             `gorm:"primaryKey" json:"id"`
+	DeviceID                string             `gorm:"index" json:"deviceId"`
+	Status                  RepairTicketStatus `gorm:"type:varchar(20);index" json:"status"`
+	Fault                   string             `json:"fault"`
+	Vendor                  string             `gorm:"type:varchar(100)" json:"vendor"`
+	SentDate                time.Time          `json:"sentDate"`
+	ReturnedDate            *time.Time         `json:"returnedDate,omitempty"`
+	Cost                    money.Amount       `json:"cost"`
+	Currency                money.Currency     `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	ExpenseID               *string            `json:"expenseId,omitempty"`
+	Outcome                 RepairOutcome      `gorm:"type:varchar(20)" json:"outcome,omitempty"`
+	SerialNumber            string             `gorm:"type:varchar(50)" json:"serialNumber"`
+	ReplacementSerialNumber *string            `gorm:"type:varchar(50)" json:"replacementSerialNumber,omitempty"`
+	Note                    string             `json:"note,omitempty"`
+	OpenedBy                string             `gorm:"type:varchar(64)" json:"openedBy"`
+	ClosedBy                string             `gorm:"type:varchar(64)" json:"closedBy,omitempty"`
+	ClosedAt                *time.Time         `json:"closedAt,omitempty"`
+	CreatedAt               time.Time          `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt               time.Time          `gorm:"autoUpdateTime" json:"updatedAt"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
 
 type SubscriptionType string
 
@@ -9,18 +12,106 @@ const (
 	CableTV  SubscriptionType = "CableTV"
 )
 
+func (t SubscriptionType) IsValid() bool {
+	return t == Internet || t == CableTV
+}
+
+type SubscriptionStatus string
+
+const (
+	// SubscriptionPendingActivation is a signed-up subscription that is not connected yet
+	SubscriptionPendingActivation SubscriptionStatus = "PendingActivation"
+	SubscriptionActive            SubscriptionStatus = "Active"
+	// SubscriptionSuspended is switched off by staff, e.g. on the customer's request, until it is resumed
+	SubscriptionSuspended SubscriptionStatus = "Suspended"
+	// SubscriptionExpired was left unpaid past its renewal date; paying or reactivating brings it back
+	SubscriptionExpired   SubscriptionStatus = "Expired"
+	SubscriptionCancelled SubscriptionStatus = "Cancelled"
+	// SubscriptionOnHold is paused at the customer's request for a set period and is not billed meanwhile
+	SubscriptionOnHold SubscriptionStatus = "OnHold"
+)
+
+// subscriptionTransitions lists the statuses each status may move to
+var subscriptionTransitions = map[SubscriptionStatus][]SubscriptionStatus{
+	SubscriptionPendingActivation: {SubscriptionActive, SubscriptionCancelled},
+	SubscriptionActive:            {SubscriptionSuspended, SubscriptionExpired, SubscriptionCancelled, SubscriptionOnHold},
+	SubscriptionSuspended:         {SubscriptionActive, SubscriptionCancelled},
+	SubscriptionExpired:           {SubscriptionActive, SubscriptionCancelled},
+	SubscriptionCancelled:         {SubscriptionActive},
+	SubscriptionOnHold:            {SubscriptionActive, SubscriptionCancelled},
+}
+
+func (s SubscriptionStatus) IsValid() bool {
+	_, ok := subscriptionTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether the transition table allows moving from s to next
+func (s SubscriptionStatus) CanTransitionTo(next SubscriptionStatus) bool {
+	for _, allowed := range subscriptionTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// CanActivate, CanSuspend, CanResume, CanCancel, CanReactivate and CanHold guard the staff actions on a subscription
+
+func (s SubscriptionStatus) CanActivate() bool {
+	return s == SubscriptionPendingActivation
+}
+
+func (s SubscriptionStatus) CanSuspend() bool {
+	return s == SubscriptionActive
+}
+
+func (s SubscriptionStatus) CanResume() bool {
+	return s == SubscriptionSuspended
+}
+
+func (s SubscriptionStatus) CanCancel() bool {
+	return s.CanTransitionTo(SubscriptionCancelled)
+}
+
+func (s SubscriptionStatus) CanReactivate() bool {
+	return s == SubscriptionExpired || s == SubscriptionCancelled
+}
+
+func (s SubscriptionStatus) CanHold() bool {
+	return s == SubscriptionActive
+}
+
+// IsBillable reports whether the monthly billing run invoices subscriptions in this status
+func (s SubscriptionStatus) IsBillable() bool {
+	return s == SubscriptionActive
+}
+
 type Subscription struct {
-	ID              string    `gorm:"primaryKey" json:"id"`
-	CustomerID      string    `gorm:"index" json:"customerId"`
-	PackageID       string    `gorm:"index" json:"packageId"`
-	PackagePrice    float64   `json:"packagePrice"`
-	MonthlyDiscount float64   `json:"monthlyDiscount"`
-	Status          string    `json:"status"`
-	StartDate       time.Time `json:"startDate"`
-	RenewalDate     time.Time `json:"renewalDate"`
-	PaidUntil       time.Time `json:"paidUntil"`
-	DueAmount       string    `json:"dueAmount"`
-	DeviceID        string    `gorm:"index" json:"deviceId,omitempty"`
-	CreatedAt       time.Time `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+	ID              string             `gorm:"primaryKey" json:"id"`
+	CustomerID      string             `gorm:"index" json:"customerId"`
+	Type            SubscriptionType   `gorm:"type:varchar(20)" json:"type"`
+	PackageID       string             `gorm:"index" json:"packageId"`
+	PackagePrice    money.Amount       `json:"packagePrice"`
+	MonthlyDiscount money.Amount       `json:"monthlyDiscount"`
+	Currency        money.Currency     `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	Status          SubscriptionStatus `gorm:"index" json:"status"`
+	StartDate       time.Time          `json:"startDate"`
+	RenewalDate     time.Time          `json:"renewalDate"`
+	PaidUntil       time.Time          `json:"paidUntil"`
+	DueAmount       money.Amount       `json:"dueAmount"`
+	DeviceID        string             `gorm:"index" json:"deviceId,omitempty"`
+	CreatedAt       time.Time          `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt       time.Time          `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// SubscriptionStatusChange records who moved a subscription between statuses, when and why
+type SubscriptionStatusChange struct {
+	ID             string             `gorm:"primaryKey" json:"id"`
+	SubscriptionID string             `gorm:"index" json:"subscriptionId"`
+	FromStatus     SubscriptionStatus `gorm:"type:varchar(20)" json:"fromStatus"`
+	ToStatus       SubscriptionStatus `gorm:"type:varchar(20)" json:"toStatus"`
+	Reason         string             `json:"reason,omitempty"`
+	ChangedBy      string             `gorm:"type:varchar(64)" json:"changedBy"`
+	ChangedAt      time.Time          `json:"changedAt"`
 }
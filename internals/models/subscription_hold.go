@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+type HoldStatus string
+
+const (
+	HoldScheduled HoldStatus = "SCHEDULED"
+	HoldActive    HoldStatus = "ACTIVE"
+	HoldCompleted HoldStatus = "COMPLETED"
+	HoldCancelled HoldStatus = "CANCELLED"
+)
+
+// IsOpen reports whether the hold has yet to start or is still running
+func (s HoldStatus) IsOpen() bool {
+	return s == HoldScheduled || s == HoldActive
+}
+
+// SubscriptionHold pauses a subscription from StartDate until EndDate, the day service resumes.
+// While it runs the subscription is not billed, and its paid period is pushed forward by the
+// days held. Fee is the hold fee invoiced when the hold starts, if any.
+type SubscriptionHold struct {
+	ID             string         `gorm:"primaryKey" json:"id"`
+	SubscriptionID string         `gorm:"index" json:"subscriptionId"`
+	StartDate      time.Time      `json:"startDate"`
+	EndDate        time.Time      `json:"endDate"`
+	Status         HoldStatus     `gorm:"type:varchar(20);index" json:"status"`
+	Fee            money.Amount   `json:"fee"`
+	Currency       money.Currency `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	InvoiceID      *string        `json:"invoiceId,omitempty"`
+	DaysHeld       int            `json:"daysHeld"`
+	Reason         string         `json:"reason,omitempty"`
+	CreatedBy      string         `gorm:"type:varchar(64)" json:"createdBy"`
+	StartedAt      *time.Time     `json:"startedAt,omitempty"`
+	EndedAt        *time.Time     `json:"endedAt,omitempty"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updatedAt"`
+}
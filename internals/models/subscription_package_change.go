@@ -0,0 +1,48 @@
+package models
+
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+type PackageChangeKind string
+
+const (
+	PackageUpgrade   PackageChangeKind = "UPGRADE"
+	PackageDowngrade PackageChangeKind = "DOWNGRADE"
+)
+
+type PackageChangeStatus string
+
+const (
+	// PackageChangeScheduled waits for the billing run of the cycle it takes effect in
+	PackageChangeScheduled PackageChangeStatus = "SCHEDULED"
+	PackageChangeApplied   PackageChangeStatus = "APPLIED"
+	// PackageChangeSuperseded was scheduled but replaced by a later change before taking effect
+	PackageChangeSuperseded PackageChangeStatus = "SUPERSEDED"
+)
+
+// SubscriptionPackageChange is one entry in a subscription's package history. Changes applied
+// mid-cycle carry the prorated credit for the old package and charge for the new one, and the
+// adjustment invoice or customer credit that settled the difference.
+type SubscriptionPackageChange struct {
+	ID             string              `gorm:"primaryKey" json:"id"`
+	SubscriptionID string              `gorm:"index" json:"subscriptionId"`
+	FromPackageID  string              `json:"fromPackageId"`
+	ToPackageID    string              `json:"toPackageId"`
+	FromPrice      money.Amount        `json:"fromPrice"`
+	ToPrice        money.Amount        `json:"toPrice"`
+	Kind           PackageChangeKind   `gorm:"type:varchar(20)" json:"kind"`
+	Status         PackageChangeStatus `gorm:"type:varchar(20);index" json:"status"`
+	EffectiveAt    time.Time           `json:"effectiveAt"`
+	ProratedDays   int                 `json:"proratedDays"`
+	CycleDays      int                 `json:"cycleDays"`
+	Credit         money.Amount        `json:"credit"`
+	Charge         money.Amount        `json:"charge"`
+	Currency       money.Currency      `gorm:"type:varchar(3);default:BDT" json:"currency"`
+	InvoiceID      *string             `json:"invoiceId,omitempty"`
+	Reason         string              `json:"reason,omitempty"`
+	ChangedBy      string              `gorm:"type:varchar(64)" json:"changedBy"`
+	AppliedAt      *time.Time          `json:"appliedAt,omitempty"`
+	CreatedAt      time.Time           `gorm:"autoCreateTime" json:"createdAt"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+// SubscriptionRenewal remembers what a paid invoice changed on a subscription,
+// so that reversing the payment can put it back exactly
+type SubscriptionRenewal struct {
+	ID                  string       `gorm:"primaryKey" json:"id"`
+	SubscriptionID      string       `gorm:"index" json:"subscriptionId"`
+	InvoiceID           string       `gorm:"index" json:"invoiceId"`
+	PreviousPaidUntil   time.Time    `json:"previousPaidUntil"`
+	PreviousRenewalDate time.Time    `json:"previousRenewalDate"`
+	PreviousDueAmount   money.Amount `json:"previousDueAmount"`
+	PaidUntil           time.Time    `json:"paidUntil"`
+	CreatedAt           time.Time    `gorm:"autoCreateTime" json:"createdAt"`
+}
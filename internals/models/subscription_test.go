@@ -0,0 +1,42 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionStatusTransitions(t *testing.T) {
+	testCases := []struct {
+		from, to SubscriptionStatus
+		allowed  bool
+	}{
+		{SubscriptionPendingActivation, SubscriptionActive, true},
+		{SubscriptionPendingActivation, SubscriptionSuspended, false},
+		{SubscriptionActive, SubscriptionSuspended, true},
+		{SubscriptionActive, SubscriptionExpired, true},
+		{SubscriptionSuspended, SubscriptionActive, true},
+		{SubscriptionSuspended, SubscriptionExpired, false},
+		{SubscriptionExpired, SubscriptionActive, true},
+		{SubscriptionExpired, SubscriptionSuspended, false},
+		{SubscriptionCancelled, SubscriptionActive, true},
+		{SubscriptionCancelled, SubscriptionExpired, false},
+		{SubscriptionActive, SubscriptionOnHold, true},
+		{SubscriptionOnHold, SubscriptionActive, true},
+		{SubscriptionOnHold, SubscriptionExpired, false},
+		{SubscriptionSuspended, SubscriptionOnHold, false},
+		{"active", SubscriptionActive, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.allowed, tc.from.CanTransitionTo(tc.to), "%s -> %s", tc.from, tc.to)
+	}
+
+	assert.True(t, SubscriptionPendingActivation.CanActivate())
+	assert.False(t, SubscriptionSuspended.CanActivate(), "activation only connects a pending subscription")
+	assert.True(t, SubscriptionActive.CanSuspend())
+	assert.False(t, SubscriptionExpired.CanResume(), "resume only undoes a suspension")
+	assert.True(t, SubscriptionExpired.CanReactivate())
+	assert.False(t, SubscriptionSuspended.CanReactivate())
+	assert.False(t, SubscriptionCancelled.CanCancel())
+	assert.False(t, SubscriptionStatus("Paused").IsValid())
+}
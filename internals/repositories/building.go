@@ -21,7 +21,7 @@ func NewGormBuildingRepository() *GormBuildingRepository {
 }
 
 func (r *GormBuildingRepository) CreateBuilding(ctx context.Context, building *models.Building) error {
-	return db.DB.WithContext(ctx).Create(building).Error
+	return db.Conn(ctx).Create(building).Error
 }
 
 func (r *GormBuildingRepository) DeleteBuilding(id string) error {
@@ -29,12 +29,12 @@ func (r *GormBuildingRepository) DeleteBuilding(id string) error {
 }
 
 func (r *GormBuildingRepository) UpdateBuilding(ctx context.Context, id string, updates map[string]interface{}) error {
-	return db.DB.WithContext(ctx).Model(&models.Building{}).Where("id = ?", id).Updates(updates).Error
+	return db.Conn(ctx).Model(&models.Building{}).Where("id = ?", id).Updates(updates).Error
 }
 
 func (r *GormBuildingRepository) GetBuildingByID(ctx context.Context, id string) (*models.Building, error) {
 	var building models.Building
-	if err := db.DB.WithContext(ctx).Preload("Address").First(&building, "id = ?", id).Error; err != nil {
+	if err := db.Conn(ctx).Preload("Address").First(&building, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &building, nil
@@ -42,7 +42,7 @@ func (r *GormBuildingRepository) GetBuildingByID(ctx context.Context, id string)
 
 func (r *GormBuildingRepository) GetAllBuildings(ctx context.Context) ([]models.Building, error) {
 	var buildings []models.Building
-	if err := db.DB.WithContext(ctx).Preload("Address").Find(&buildings).Error; err != nil {
+	if err := db.Conn(ctx).Preload("Address").Find(&buildings).Error; err != nil {
 		return nil, err
 	}
 	return buildings, nil
@@ -26,7 +26,7 @@ func NewGormCustomerRepository() *GormCustomerRepository {
 }
 
 func (r *GormCustomerRepository) CreateCustomer(ctx context.Context, customer *models.Customer) error {
-	return db.DB.WithContext(ctx).Create(customer).Error
+	return db.Conn(ctx).Create(customer).Error
 }
 
 func (r *GormCustomerRepository) GetCustomer(id string) (*models.Customer, error) {
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+type CustomerCreditRepository interface {
+	LockCustomerCredit(ctx context.Context, customerID string) error
+	GetCreditBalance(ctx context.Context, customerID string, currency money.Currency) (money.Amount, error)
+	GetCreditFromPayment(ctx context.Context, paymentID string) (money.Amount, error)
+	CreateCreditEntry(ctx context.Context, entry *models.CustomerCredit) error
+	GetCreditEntries(ctx context.Context, customerID string) ([]models.CustomerCredit, error)
+}
+
+type GormCustomerCreditRepository struct{}
+
+func NewGormCustomerCreditRepository() *GormCustomerCreditRepository {
+	return &GormCustomerCreditRepository{}
+}
+
+// LockCustomerCredit serializes credit changes for one customer until the surrounding transaction ends
+func (r *GormCustomerCreditRepository) LockCustomerCredit(ctx context.Context, customerID string) error {
+	return db.Conn(ctx).Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "customer_credit:"+customerID).Error
+}
+
+func (r *GormCustomerCreditRepository) GetCreditBalance(ctx context.Context, customerID string, currency money.Currency) (money.Amount, error) {
+	var balance money.Amount
+	err := db.Conn(ctx).Model(&models.CustomerCredit{}).
+		Where("customer_id = ? AND currency = ?", customerID, currency).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// GetCreditFromPayment is the credit kept from a payment's overpayment
+func (r *GormCustomerCreditRepository) GetCreditFromPayment(ctx context.Context, paymentID string) (money.Amount, error) {
+	var amount money.Amount
+	err := db.Conn(ctx).Model(&models.CustomerCredit{}).
+		Where("payment_id = ?", paymentID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&amount).Error
+	return amount, err
+}
+
+func (r *GormCustomerCreditRepository) CreateCreditEntry(ctx context.Context, entry *models.CustomerCredit) error {
+	return db.Conn(ctx).Create(entry).Error
+}
+
+func (r *GormCustomerCreditRepository) GetCreditEntries(ctx context.Context, customerID string) ([]models.CustomerCredit, error) {
+	var entries []models.CustomerCredit
+	err := db.Conn(ctx).Where("customer_id = ?", customerID).Order("created_at").Find(&entries).Error
+	return entries, err
+}
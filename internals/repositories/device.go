@@ -6,19 +6,46 @@ import (
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/pkg/db"
 	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
 type DeviceRepository interface {
 	CreateDevice(ctx context.Context, device *models.Device) error
+	CreateDevices(ctx context.Context, devices []models.Device) error
+	GetExistingSerialNumbers(ctx context.Context, serialNumbers []string) ([]string, error)
 	GetDeviceByID(ctx context.Context, id string) (*models.Device, error)
+	GetDeviceForUpdate(ctx context.Context, id string) (*models.Device, error)
 	GetAllDevices(ctx context.Context, page, pageSize int) ([]models.Device, int64, error)
+	GetInventory(ctx context.Context) ([]models.Device, error)
 	UpdateDevice(ctx context.Context, device *models.Device) error
 	DeleteDevice(ctx context.Context, id string) error
 	AssignDevice(ctx context.Context, deviceID string, assignmentType string, assignmentID string) error
 	UnassignDevice(ctx context.Context, deviceID string) error
 	GetDeviceByAssignment(ctx context.Context, assignmentType string, assignmentID string) (*models.Device, error)
 	MarkDeviceStatus(ctx context.Context, deviceID string, status models.DeviceStatus) error
+	MarkDeviceForCollection(ctx context.Context, deviceID string) error
+	WriteOffDevice(ctx context.Context, deviceID string, lossExpenseID *string) error
+	GetDevicesPendingCollection(ctx context.Context) ([]PendingCollectionDevice, error)
+	CreateDeviceEvent(ctx context.Context, event *models.DeviceEvent) error
+	GetDeviceEvents(ctx context.Context, deviceID string) ([]models.DeviceEvent, error)
+	CreateRepairTicket(ctx context.Context, ticket *models.RepairTicket) error
+	UpdateRepairTicket(ctx context.Context, ticket *models.RepairTicket) error
+	GetRepairTicketForUpdate(ctx context.Context, id string) (*models.RepairTicket, error)
+	GetOpenRepairTicket(ctx context.Context, deviceID string) (*models.RepairTicket, error)
+	GetDeviceRepairTickets(ctx context.Context, deviceID string) ([]models.RepairTicket, error)
+	GetRepairTickets(ctx context.Context, status models.RepairTicketStatus) ([]models.RepairTicket, error)
+}
+
+// PendingCollectionDevice is a device awaiting collection along with where the field team can find it
+type PendingCollectionDevice struct {
+	models.Device
+	CustomerID       *string `json:"customerId,omitempty"`
+	CustomerName     *string `json:"customerName,omitempty"`
+	CustomerMobile   *string `json:"customerMobile,omitempty"`
+	Area             *string `json:"area,omitempty"`
+	LocationBuilding *string `json:"locationBuildingId,omitempty"`
+	BuildingName     *string `json:"buildingName,omitempty"`
 }
 
 type GormDeviceRepository struct{}
@@ -28,41 +55,71 @@ func NewGormDeviceRepository() *GormDeviceRepository {
 }
 
 func (r *GormDeviceRepository) CreateDevice(ctx context.Context, device *models.Device) error {
-	return db.DB.WithContext(ctx).Create(device).Error
+	return db.Conn(ctx).Create(device).Error
+}
+
+func (r *GormDeviceRepository) CreateDevices(ctx context.Context, devices []models.Device) error {
+	return db.Conn(ctx).CreateInBatches(devices, 100).Error
+}
+
+// GetExistingSerialNumbers returns which of the serial numbers are already taken, ignoring case
+func (r *GormDeviceRepository) GetExistingSerialNumbers(ctx context.Context, serialNumbers []string) ([]string, error) {
+	upper := make([]string, len(serialNumbers))
+	for i, serialNumber := range serialNumbers {
+		upper[i] = strings.ToUpper(serialNumber)
+	}
+
+	var existing []string
+	err := db.Conn(ctx).Model(&models.Device{}).Where("UPPER(serial_number) IN ?", upper).Pluck("serial_number", &existing).Error
+	return existing, err
 }
 
 func (r *GormDeviceRepository) GetDeviceByID(ctx context.Context, id string) (*models.Device, error) {
 	var device models.Device
-	err := db.DB.WithContext(ctx).First(&device, "id = ?", id).Error
+	err := db.Conn(ctx).First(&device, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &device, nil
 }
 
+// GetDeviceForUpdate loads the device and locks its row until the surrounding transaction ends
+func (r *GormDeviceRepository) GetDeviceForUpdate(ctx context.Context, id string) (*models.Device, error) {
+	var device models.Device
+	err := db.ForUpdate(ctx).First(&device, "id = ?", id).Error
+	return &device, err
+}
+
 func (r *GormDeviceRepository) GetAllDevices(ctx context.Context, page, pageSize int) ([]models.Device, int64, error) {
 	var devices []models.Device
 	var totalCount int64
 
 	offset := (page - 1) * pageSize
 
-	if err := db.DB.WithContext(ctx).Model(&models.Device{}).Count(&totalCount).Error; err != nil {
+	if err := db.Conn(ctx).Model(&models.Device{}).Count(&totalCount).Error; err != nil {
 		return nil, 0, err
 	}
 
-	if err := db.DB.WithContext(ctx).Offset(offset).Limit(pageSize).Find(&devices).Error; err != nil {
+	if err := db.Conn(ctx).Offset(offset).Limit(pageSize).Find(&devices).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return devices, totalCount, nil
 }
 
+// GetInventory returns every device for the inventory reports, ordered by type, brand and model
+func (r *GormDeviceRepository) GetInventory(ctx context.Context) ([]models.Device, error) {
+	var devices []models.Device
+	err := db.Conn(ctx).Order("type, brand, model, serial_number").Find(&devices).Error
+	return devices, err
+}
+
 func (r *GormDeviceRepository) UpdateDevice(ctx context.Context, device *models.Device) error {
-	return db.DB.WithContext(ctx).Save(device).Error
+	return db.Conn(ctx).Save(device).Error
 }
 
 func (r *GormDeviceRepository) DeleteDevice(ctx context.Context, id string) error {
-	return db.DB.WithContext(ctx).Delete(&models.Device{}, "id = ?", id).Error
+	return db.Conn(ctx).Delete(&models.Device{}, "id = ?", id).Error
 }
 
 func (r *GormDeviceRepository) AssignDevice(ctx context.Context, deviceID string, assignmentType string, assignmentID string) error {
@@ -84,11 +141,11 @@ func (r *GormDeviceRepository) AssignDevice(ctx context.Context, deviceID string
 		return errors.New("invalid assignment type")
 	}
 
-	return db.DB.WithContext(ctx).Model(&models.Device{}).Where("id = ?", deviceID).Updates(updates).Error
+	return db.Conn(ctx).Model(&models.Device{}).Where("id = ?", deviceID).Updates(updates).Error
 }
 
 func (r *GormDeviceRepository) UnassignDevice(ctx context.Context, deviceID string) error {
-	return db.DB.WithContext(ctx).Model(&models.Device{}).Where("id = ?", deviceID).Updates(map[string]interface{}{
+	return db.Conn(ctx).Model(&models.Device{}).Where("id = ?", deviceID).Updates(map[string]interface{}{
 		"subscription_id": nil,
 		"building_id":     nil,
 		"status":          models.InStock,
@@ -103,9 +160,9 @@ func (r *GormDeviceRepository) GetDeviceByAssignment(ctx context.Context, assign
 
 	switch assignmentType {
 	case "Subscription":
-		err = db.DB.WithContext(ctx).Where("subscription_id = ?", assignmentID).First(&device).Error
+		err = db.Conn(ctx).Where("subscription_id = ?", assignmentID).First(&device).Error
 	case "Building":
-		err = db.DB.WithContext(ctx).Where("building_id = ?", assignmentID).First(&device).Error
+		err = db.Conn(ctx).Where("building_id = ?", assignmentID).First(&device).Error
 	default:
 		return nil, errors.New("invalid assignment type")
 	}
@@ -120,5 +177,99 @@ func (r *GormDeviceRepository) GetDeviceByAssignment(ctx context.Context, assign
 }
 
 func (r *GormDeviceRepository) MarkDeviceStatus(ctx context.Context, deviceID string, status models.DeviceStatus) error {
-	return db.DB.WithContext(ctx).Model(&models.Device{}).Where("id = ?", deviceID).Update("status", status).Error
+	return db.Conn(ctx).Model(&models.Device{}).Where("id = ?", deviceID).Update("status", status).Error
+}
+
+func (r *GormDeviceRepository) MarkDeviceForCollection(ctx context.Context, deviceID string) error {
+	return db.Conn(ctx).Model(&models.Device{}).Where("id = ?", deviceID).Updates(map[string]interface{}{
+		"status":          models.PendingCollection,
+		"collection_date": time.Now(),
+	}).Error
+}
+
+func (r *GormDeviceRepository) WriteOffDevice(ctx context.Context, deviceID string, lossExpenseID *string) error {
+	return db.Conn(ctx).Model(&models.Device{}).Where("id = ?", deviceID).Updates(map[string]interface{}{
+		"status":          models.WrittenOff,
+		"loss_expense_id": lossExpenseID,
+	}).Error
+}
+
+func (r *GormDeviceRepository) GetDevicesPendingCollection(ctx context.Context) ([]PendingCollectionDevice, error) {
+	var devices []PendingCollectionDevice
+	err := db.Conn(ctx).
+		Table("devices AS d").
+		Select(`DISTINCT ON (d.id) d.*,
+			s.customer_id,
+			c.name AS customer_name,
+			c.mobile AS customer_mobile,
+			COALESCE(a.area, ba.area) AS area,
+			COALESCE(d.building_id, a.building_id) AS location_building,
+			b.name AS building_name`).
+		Joins("LEFT JOIN subscriptions s ON s.id = d.subscription_id").
+		Joins("LEFT JOIN customers c ON c.id = s.customer_id").
+		Joins("LEFT JOIN addresses a ON a.customer_id = c.id").
+		Joins("LEFT JOIN buildings b ON b.id = COALESCE(d.building_id, a.building_id)").
+		Joins("LEFT JOIN addresses ba ON ba.building_id = b.id AND ba.customer_id IS NULL").
+		Where("d.status = ?", models.PendingCollection).
+		Order("d.id").
+		Scan(&devices).Error
+	return devices, err
+}
+
+func (r *GormDeviceRepository) CreateDeviceEvent(ctx context.Context, event *models.DeviceEvent) error {
+	return db.Conn(ctx).Create(event).Error
+}
+
+// GetDeviceEvents returns the device's history, oldest first
+func (r *GormDeviceRepository) GetDeviceEvents(ctx context.Context, deviceID string) ([]models.DeviceEvent, error) {
+	var events []models.DeviceEvent
+	err := db.Conn(ctx).Where("device_id = ?", deviceID).Order("occurred_at, id").Find(&events).Error
+	return events, err
+}
+
+func (r *GormDeviceRepository) CreateRepairTicket(ctx context.Context, ticket *models.RepairTicket) error {
+	return db.Conn(ctx).Create(ticket).Error
+}
+
+func (r *GormDeviceRepository) UpdateRepairTicket(ctx context.Context, ticket *models.RepairTicket) error {
+	return db.Conn(ctx).Save(ticket).Error
+}
+
+// GetRepairTicketForUpdate loads the ticket and locks its row until the surrounding transaction ends
+func (r *GormDeviceRepository) GetRepairTicketForUpdate(ctx context.Context, id string) (*models.RepairTicket, error) {
+	var ticket models.RepairTicket
+	err := db.ForUpdate(ctx).First(&ticket, "id = ?", id).Error
+	return &ticket, err
+}
+
+// GetOpenRepairTicket returns the device's open repair ticket, or nil without error
+func (r *GormDeviceRepository) GetOpenRepairTicket(ctx context.Context, deviceID string) (*models.RepairTicket, error) {
+	var ticket models.RepairTicket
+	err := db.Conn(ctx).First(&ticket, "device_id = ? AND status = ?", deviceID, models.RepairOpen).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// GetDeviceRepairTickets returns the device's repair tickets, latest sent first
+func (r *GormDeviceRepository) GetDeviceRepairTickets(ctx context.Context, deviceID string) ([]models.RepairTicket, error) {
+	var tickets []models.RepairTicket
+	err := db.Conn(ctx).Where("device_id = ?", deviceID).Order("sent_date DESC, created_at DESC").Find(&tickets).Error
+	return tickets, err
+}
+
+// GetRepairTickets returns the tickets with the given status, or all of them when it is empty,
+// longest with the vendor first
+func (r *GormDeviceRepository) GetRepairTickets(ctx context.Context, status models.RepairTicketStatus) ([]models.RepairTicket, error) {
+	var tickets []models.RepairTicket
+	query := db.Conn(ctx).Order("sent_date, created_at")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&tickets).Error
+	return tickets, err
 }
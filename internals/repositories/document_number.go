@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"time"
+)
+
+const (
+	invoiceNumberPrefix = "INV"
+	receiptNumberPrefix = "RCT"
+)
+
+// nextDocumentNumber hands out the next number of the prefix's sequence for the year, e.g.
+// INV-2026-000123. The sequence row stays locked until the surrounding transaction ends, so
+// concurrent callers queue up behind it and a rolled back transaction gives its number back:
+// the numbers have no gaps. Callers take a number only once the document itself is written.
+func nextDocumentNumber(ctx context.Context, prefix string, date time.Time) (string, error) {
+	year := date.Year()
+
+	var next int64
+	err := db.Conn(ctx).Raw(`INSERT INTO document_sequences (prefix, year, last_value) VALUES (?, ?, 1)
+		ON CONFLICT (prefix, year) DO UPDATE SET last_value = document_sequences.last_value + 1
+		RETURNING last_value`, prefix, year).Scan(&next).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate %s number: %w", prefix, err)
+	}
+	return formatDocumentNumber(prefix, year, next), nil
+}
+
+func formatDocumentNumber(prefix string, year int, n int64) string {
+	return fmt.Sprintf("%s-%d-%06d", prefix, year, n)
+}
@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type EmployeeRepository interface {
+	CreateEmployee(ctx context.Context, employee *models.Employee) error
+	GetEmployeeByID(ctx context.Context, id string) (*models.Employee, error)
+	GetEmployeeByMobile(ctx context.Context, mobile string) (*models.Employee, error)
+	UpdateLastLogin(ctx context.Context, id string, at time.Time) error
+	GetEmployeesPaginated(ctx context.Context, page, pageSize int) ([]models.Employee, int64, error)
+	GetActiveEmployees(ctx context.Context) ([]models.Employee, error)
+	UpdateEmployee(ctx context.Context, employee *models.Employee) error
+	DeleteEmployee(ctx context.Context, id string) error
+	UpdateSalary(ctx context.Context, employee *models.Employee, history *models.SalaryHistory) error
+	GetSalaryHistory(ctx context.Context, employeeID string) ([]models.SalaryHistory, error)
+	CreateSalaryPayout(ctx context.Context, payout *models.SalaryPayout, expense *models.Expense) (bool, error)
+	GetSalaryPayouts(ctx context.Context, employeeID string) ([]models.SalaryPayout, error)
+	CountSalaryPayouts(ctx context.Context, employeeID string) (int64, error)
+}
+
+type GormEmployeeRepository struct{}
+
+func NewGormEmployeeRepository() *GormEmployeeRepository {
+	return &GormEmployeeRepository{}
+}
+
+func (r *GormEmployeeRepository) CreateEmployee(ctx context.Context, employee *models.Employee) error {
+	return db.Conn(ctx).Create(employee).Error
+}
+
+func (r *GormEmployeeRepository) GetEmployeeByID(ctx context.Context, id string) (*models.Employee, error) {
+	var employee models.Employee
+	err := db.Conn(ctx).First(&employee, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &employee, nil
+}
+
+func (r *GormEmployeeRepository) GetEmployeeByMobile(ctx context.Context, mobile string) (*models.Employee, error) {
+	var employee models.Employee
+	err := db.Conn(ctx).Where("mobile = ?", mobile).First(&employee).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &employee, nil
+}
+
+func (r *GormEmployeeRepository) UpdateLastLogin(ctx context.Context, id string, at time.Time) error {
+	return db.Conn(ctx).Model(&models.Employee{}).Where("id = ?", id).Update("last_login_at", at).Error
+}
+
+func (r *GormEmployeeRepository) GetEmployeesPaginated(ctx context.Context, page, pageSize int) ([]models.Employee, int64, error) {
+	var employees []models.Employee
+	var totalCount int64
+
+	offset := (page - 1) * pageSize
+
+	if err := db.Conn(ctx).Model(&models.Employee{}).Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := db.Conn(ctx).Order("name").Offset(offset).Limit(pageSize).Find(&employees).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return employees, totalCount, nil
+}
+
+func (r *GormEmployeeRepository) GetActiveEmployees(ctx context.Context) ([]models.Employee, error) {
+	var employees []models.Employee
+	err := db.Conn(ctx).Where("is_active = ?", true).Order("name").Find(&employees).Error
+	return employees, err
+}
+
+func (r *GormEmployeeRepository) UpdateEmployee(ctx context.Context, employee *models.Employee) error {
+	return db.Conn(ctx).Save(employee).Error
+}
+
+func (r *GormEmployeeRepository) DeleteEmployee(ctx context.Context, id string) error {
+	return db.Conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.SalaryHistory{}, "employee_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Employee{}, "id = ?", id).Error
+	})
+}
+
+// UpdateSalary saves the employee's new salary together with its history record
+func (r *GormEmployeeRepository) UpdateSalary(ctx context.Context, employee *models.Employee, history *models.SalaryHistory) error {
+	return db.Conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Employee{}).Where("id = ?", employee.ID).Update("salary", employee.Salary).Error; err != nil {
+			return err
+		}
+		return tx.Create(history).Error
+	})
+}
+
+func (r *GormEmployeeRepository) GetSalaryHistory(ctx context.Context, employeeID string) ([]models.SalaryHistory, error) {
+	var history []models.SalaryHistory
+	err := db.Conn(ctx).Where("employee_id = ?", employeeID).Order("effective_from DESC, created_at DESC").Find(&history).Error
+	return history, err
+}
+
+// CreateSalaryPayout records the payout and its operational expense atomically. It reports false
+// without writing anything when the employee has already been paid for the period.
+func (r *GormEmployeeRepository) CreateSalaryPayout(ctx context.Context, payout *models.SalaryPayout, expense *models.Expense) (bool, error) {
+	created := false
+	err := db.Conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(expense).Error; err != nil {
+			return err
+		}
+
+		payout.ExpenseID = expense.ID
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(payout)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPayoutExists
+		}
+
+		created = true
+		return nil
+	})
+	if errors.Is(err, errPayoutExists) {
+		return false, nil
+	}
+	return created, err
+}
+
+var errPayoutExists = errors.New("salary payout already exists for period")
+
+func (r *GormEmployeeRepository) GetSalaryPayouts(ctx context.Context, employeeID string) ([]models.SalaryPayout, error) {
+	var payouts []models.SalaryPayout
+	err := db.Conn(ctx).Where("employee_id = ?", employeeID).Order("period DESC").Find(&payouts).Error
+	return payouts, err
+}
+
+func (r *GormEmployeeRepository) CountSalaryPayouts(ctx context.Context, employeeID string) (int64, error) {
+	var count int64
+	err := db.Conn(ctx).Model(&models.SalaryPayout{}).Where("employee_id = ?", employeeID).Count(&count).Error
+	return count, err
+}
@@ -21,25 +21,25 @@ func NewGormExpenseRepository() *GormExpenseRepository {
 }
 
 func (r *GormExpenseRepository) CreateExpense(ctx context.Context, expense *models.Expense) error {
-	return db.DB.WithContext(ctx).Create(expense).Error
+	return db.Conn(ctx).Create(expense).Error
 }
 
 func (r *GormExpenseRepository) GetExpenseByID(ctx context.Context, id string) (*models.Expense, error) {
 	var expense models.Expense
-	err := db.DB.WithContext(ctx).First(&expense, "id = ?", id).Error
+	err := db.Conn(ctx).First(&expense, "id = ?", id).Error
 	return &expense, err
 }
 
 func (r *GormExpenseRepository) GetAllExpenses(ctx context.Context) ([]models.Expense, error) {
 	var expenses []models.Expense
-	err := db.DB.WithContext(ctx).Find(&expenses).Error
+	err := db.Conn(ctx).Find(&expenses).Error
 	return expenses, err
 }
 
 func (r *GormExpenseRepository) UpdateExpense(ctx context.Context, expense *models.Expense) error {
-	return db.DB.WithContext(ctx).Save(expense).Error
+	return db.Conn(ctx).Save(expense).Error
 }
 
 func (r *GormExpenseRepository) DeleteExpense(ctx context.Context, id string) error {
-	return db.DB.WithContext(ctx).Delete(&models.Expense{}, "id = ?", id).Error
+	return db.Conn(ctx).Delete(&models.Expense{}, "id = ?", id).Error
 }
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"gorm.io/gorm"
+)
+
+type GatewayTransactionRepository interface {
+	CreateGatewayTransaction(ctx context.Context, transaction *models.GatewayTransaction) error
+	UpdateGatewayTransaction(ctx context.Context, transaction *models.GatewayTransaction) error
+	GetGatewayTransaction(ctx context.Context, id string) (*models.GatewayTransaction, error)
+	GetGatewayTransactionForUpdate(ctx context.Context, gateway, transactionID string) (*models.GatewayTransaction, error)
+}
+
+type GormGatewayTransactionRepository struct{}
+
+func NewGormGatewayTransactionRepository() *GormGatewayTransactionRepository {
+	return &GormGatewayTransactionRepository{}
+}
+
+func (r *GormGatewayTransactionRepository) CreateGatewayTransaction(ctx context.Context, transaction *models.GatewayTransaction) error {
+	return db.Conn(ctx).Create(transaction).Error
+}
+
+func (r *GormGatewayTransactionRepository) UpdateGatewayTransaction(ctx context.Context, transaction *models.GatewayTransaction) error {
+	return db.Conn(ctx).Save(transaction).Error
+}
+
+// GetGatewayTransaction returns nil without error when the transaction does not exist
+func (r *GormGatewayTransactionRepository) GetGatewayTransaction(ctx context.Context, id string) (*models.GatewayTransaction, error) {
+	var transaction models.GatewayTransaction
+	err := db.Conn(ctx).First(&transaction, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// GetGatewayTransactionForUpdate looks the transaction up by the gateway's own ID and locks its row
+func (r *GormGatewayTransactionRepository) GetGatewayTransactionForUpdate(ctx context.Context, gateway, transactionID string) (*models.GatewayTransaction, error) {
+	var transaction models.GatewayTransaction
+	err := db.ForUpdate(ctx).First(&transaction, "gateway = ? AND transaction_id = ?", gateway, transactionID).Error
+	return &transaction, err
+}
@@ -2,58 +2,275 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/pkg/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
+// InvoiceFilter narrows an invoice listing; zero-valued fields are ignored
+type InvoiceFilter struct {
+	Status         models.InvoiceStatus
+	Number         string
+	CustomerID     string
+	SubscriptionID string
+	DueFrom        *time.Time
+	DueTo          *time.Time
+}
+
 type InvoiceRepository interface {
 	CreateInvoice(ctx context.Context, invoice *models.Invoice) error
+	CreateInvoiceIfNotExists(ctx context.Context, invoice *models.Invoice) (bool, error)
 	GetInvoiceByID(ctx context.Context, id string) (*models.Invoice, error)
+	GetInvoiceForUpdate(ctx context.Context, id string) (*models.Invoice, error)
+	GetOpenInvoicesForUpdate(ctx context.Context, customerID string) ([]models.Invoice, error)
 	GetAllInvoices(ctx context.Context) ([]models.Invoice, error)
+	GetInvoicesPaginated(ctx context.Context, filter InvoiceFilter, page, pageSize int) ([]models.Invoice, int64, error)
 	UpdateInvoice(ctx context.Context, invoice *models.Invoice) error
+	GetInvoiceLines(ctx context.Context, invoiceID string) ([]models.InvoiceLine, error)
+	ReplaceInvoiceLines(ctx context.Context, invoice *models.Invoice) error
+	AddInvoiceLines(ctx context.Context, invoice *models.Invoice, lines []models.InvoiceLine) error
+	GetPendingInvoicesDueBefore(ctx context.Context, cutoff time.Time) ([]models.Invoice, error)
+	GetInvoicedSubscriptionIDs(ctx context.Context, billingPeriod string) (map[string]bool, error)
+	GetBuildingInvoices(ctx context.Context, buildingID, billingPeriod string, includePaid bool) ([]models.Invoice, error)
 	DeleteInvoice(ctx context.Context, id string) error
 	GetInvoicesByCustomerID(ctx context.Context, customerID string) ([]models.Invoice, error)
 	GetInvoicesBySubscriptionID(ctx context.Context, subscriptionID string) ([]models.Invoice, error)
 }
 
+// subscriptionInvoiceCondition matches the invoices of a subscription: its own, and consolidated
+// invoices, which carry no subscription, with a line billing it
+const subscriptionInvoiceCondition = `(invoices.subscription_id = ? OR EXISTS (
+	SELECT 1 FROM invoice_lines WHERE invoice_lines.invoice_id = invoices.id AND invoice_lines.subscription_id = ?))`
+
 type GormInvoiceRepository struct{}
 
 func NewGormInvoiceRepository() *GormInvoiceRepository {
 	return &GormInvoiceRepository{}
 }
 
+// CreateInvoice inserts the invoice together with its lines and gives it the next invoice number
 func (r *GormInvoiceRepository) CreateInvoice(ctx context.Context, invoice *models.Invoice) error {
-	return db.DB.WithContext(ctx).Create(invoice).Error
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		if err := db.Conn(ctx).Omit(clause.Associations).Create(invoice).Error; err != nil {
+			return err
+		}
+		if err := numberInvoice(ctx, invoice); err != nil {
+			return err
+		}
+		return createInvoiceLines(ctx, invoice)
+	})
+}
+
+// CreateInvoiceIfNotExists inserts the invoice and its lines unless the invoice collides with a
+// unique index (e.g. an invoice for the same customer and billing period) and reports whether it was created.
+func (r *GormInvoiceRepository) CreateInvoiceIfNotExists(ctx context.Context, invoice *models.Invoice) (bool, error) {
+	created := false
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		result := db.Conn(ctx).Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(invoice)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+		if err := numberInvoice(ctx, invoice); err != nil {
+			return err
+		}
+		return createInvoiceLines(ctx, invoice)
+	})
+	return created, err
+}
+
+func numberInvoice(ctx context.Context, invoice *models.Invoice) error {
+	number, err := nextDocumentNumber(ctx, invoiceNumberPrefix, invoice.CreatedAt)
+	if err != nil {
+		return err
+	}
+	invoice.Number = &number
+	return db.Conn(ctx).Model(invoice).UpdateColumn("number", number).Error
+}
+
+func createInvoiceLines(ctx context.Context, invoice *models.Invoice) error {
+	if len(invoice.Lines) == 0 {
+		return nil
+	}
+	for i := range invoice.Lines {
+		invoice.Lines[i].InvoiceID = invoice.ID
+	}
+	return db.Conn(ctx).Create(&invoice.Lines).Error
 }
 
 func (r *GormInvoiceRepository) GetInvoiceByID(ctx context.Context, id string) (*models.Invoice, error) {
 	var invoice models.Invoice
-	err := db.DB.WithContext(ctx).First(&invoice, "id = ?", id).Error
+	err := db.Conn(ctx).Preload("Lines", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("position")
+	}).First(&invoice, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// GetInvoiceForUpdate loads the invoice and locks its row until the surrounding transaction ends
+func (r *GormInvoiceRepository) GetInvoiceForUpdate(ctx context.Context, id string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := db.ForUpdate(ctx).First(&invoice, "id = ?", id).Error
 	return &invoice, err
 }
 
+// GetOpenInvoicesForUpdate locks the customer's unpaid invoices, oldest due first
+func (r *GormInvoiceRepository) GetOpenInvoicesForUpdate(ctx context.Context, customerID string) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	err := db.ForUpdate(ctx).
+		Where("customer_id = ? AND status IN ?", customerID, []models.InvoiceStatus{models.InvoicePending, models.InvoiceOverdue}).
+		Order("due_date, created_at").
+		Find(&invoices).Error
+	return invoices, err
+}
+
 func (r *GormInvoiceRepository) GetAllInvoices(ctx context.Context) ([]models.Invoice, error) {
 	var invoices []models.Invoice
-	err := db.DB.WithContext(ctx).Find(&invoices).Error
+	err := db.Conn(ctx).Find(&invoices).Error
 	return invoices, err
 }
 
+// GetInvoicesPaginated lists invoices matching the filter, most recently due first
+func (r *GormInvoiceRepository) GetInvoicesPaginated(ctx context.Context, filter InvoiceFilter, page, pageSize int) ([]models.Invoice, int64, error) {
+	var invoices []models.Invoice
+	var totalCount int64
+
+	offset := (page - 1) * pageSize
+
+	query := db.Conn(ctx).Model(&models.Invoice{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Number != "" {
+		query = query.Where("number = ?", filter.Number)
+	}
+	if filter.CustomerID != "" {
+		query = query.Where("customer_id = ?", filter.CustomerID)
+	}
+	if filter.SubscriptionID != "" {
+		query = query.Where(subscriptionInvoiceCondition, filter.SubscriptionID, filter.SubscriptionID)
+	}
+	if filter.DueFrom != nil {
+		query = query.Where("due_date >= ?", *filter.DueFrom)
+	}
+	if filter.DueTo != nil {
+		query = query.Where("due_date < ?", *filter.DueTo)
+	}
+
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("due_date DESC, created_at DESC").Offset(offset).Limit(pageSize).Find(&invoices).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return invoices, totalCount, nil
+}
+
+// UpdateInvoice saves the invoice row only; use ReplaceInvoiceLines to change its lines
 func (r *GormInvoiceRepository) UpdateInvoice(ctx context.Context, invoice *models.Invoice) error {
-	return db.DB.WithContext(ctx).Save(invoice).Error
+	return db.Conn(ctx).Omit(clause.Associations).Save(invoice).Error
+}
+
+func (r *GormInvoiceRepository) GetInvoiceLines(ctx context.Context, invoiceID string) ([]models.InvoiceLine, error) {
+	var lines []models.InvoiceLine
+	err := db.Conn(ctx).Where("invoice_id = ?", invoiceID).Order("position").Find(&lines).Error
+	return lines, err
+}
+
+// ReplaceInvoiceLines swaps the stored lines for invoice.Lines and saves the invoice total
+func (r *GormInvoiceRepository) ReplaceInvoiceLines(ctx context.Context, invoice *models.Invoice) error {
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		if err := db.Conn(ctx).Where("invoice_id = ?", invoice.ID).Delete(&models.InvoiceLine{}).Error; err != nil {
+			return err
+		}
+		if err := createInvoiceLines(ctx, invoice); err != nil {
+			return err
+		}
+		return r.UpdateInvoice(ctx, invoice)
+	})
+}
+
+// AddInvoiceLines inserts lines that were appended to the invoice and saves the invoice row
+func (r *GormInvoiceRepository) AddInvoiceLines(ctx context.Context, invoice *models.Invoice, lines []models.InvoiceLine) error {
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		if len(lines) > 0 {
+			if err := db.Conn(ctx).Create(&lines).Error; err != nil {
+				return err
+			}
+		}
+		return r.UpdateInvoice(ctx, invoice)
+	})
+}
+
+func (r *GormInvoiceRepository) GetPendingInvoicesDueBefore(ctx context.Context, cutoff time.Time) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	err := db.Conn(ctx).Where("status = ? AND due_date < ?", models.InvoicePending, cutoff).Order("due_date").Find(&invoices).Error
+	return invoices, err
+}
+
+// GetInvoicedSubscriptionIDs returns the subscriptions already billed for the period on any invoice that is not void
+func (r *GormInvoiceRepository) GetInvoicedSubscriptionIDs(ctx context.Context, billingPeriod string) (map[string]bool, error) {
+	var ids []string
+	err := db.Conn(ctx).Model(&models.InvoiceLine{}).
+		Distinct().
+		Joins("JOIN invoices ON invoices.id = invoice_lines.invoice_id").
+		Where("invoices.billing_period = ? AND invoices.status <> ? AND invoice_lines.subscription_id IS NOT NULL", billingPeriod, models.InvoiceVoid).
+		Pluck("invoice_lines.subscription_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	invoiced := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		invoiced[id] = true
+	}
+	return invoiced, nil
+}
+
+// GetBuildingInvoices returns the invoices for the period of customers living in the building, in
+// flat order so a collector can walk the building floor by floor. Only open invoices are returned
+// unless includePaid is set; cancelled and void invoices never are.
+func (r *GormInvoiceRepository) GetBuildingInvoices(ctx context.Context, buildingID, billingPeriod string, includePaid bool) ([]models.Invoice, error) {
+	statuses := []models.InvoiceStatus{models.InvoicePending, models.InvoiceOverdue}
+	if includePaid {
+		statuses = append(statuses, models.InvoicePaid)
+	}
+
+	var invoices []models.Invoice
+	err := db.Conn(ctx).
+		Joins("JOIN addresses ON addresses.customer_id = invoices.customer_id").
+		Where("addresses.building_id = ? AND invoices.billing_period = ? AND invoices.status IN ?", buildingID, billingPeriod, statuses).
+		Preload("Lines", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("position")
+		}).
+		Order("addresses.flat, invoices.number").
+		Find(&invoices).Error
+	return invoices, err
 }
 
 func (r *GormInvoiceRepository) DeleteInvoice(ctx context.Context, id string) error {
-	return db.DB.WithContext(ctx).Delete(&models.Invoice{}, "id = ?", id).Error
+	return db.Conn(ctx).Delete(&models.Invoice{}, "id = ?", id).Error
 }
 
 func (r *GormInvoiceRepository) GetInvoicesByCustomerID(ctx context.Context, customerID string) ([]models.Invoice, error) {
 	var invoices []models.Invoice
-	err := db.DB.WithContext(ctx).Where("customer_id = ?", customerID).Find(&invoices).Error
+	err := db.Conn(ctx).Where("customer_id = ?", customerID).Find(&invoices).Error
 	return invoices, err
 }
 
+// GetInvoicesBySubscriptionID returns the invoices of the subscription, consolidated invoices billing it included
 func (r *GormInvoiceRepository) GetInvoicesBySubscriptionID(ctx context.Context, subscriptionID string) ([]models.Invoice, error) {
 	var invoices []models.Invoice
-	err := db.DB.WithContext(ctx).Where("subscription_id = ?", subscriptionID).Find(&invoices).Error
+	err := db.Conn(ctx).Where(subscriptionInvoiceCondition, subscriptionID, subscriptionID).Find(&invoices).Error
 	return invoices, err
 }
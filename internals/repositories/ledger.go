@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"time"
+)
+
+type LedgerRepository interface {
+	CreateLedgerEntry(ctx context.Context, entry *models.LedgerEntry) error
+	GetBalanceBefore(ctx context.Context, customerID string, currency money.Currency, before time.Time) (money.Amount, error)
+	GetLedgerEntries(ctx context.Context, customerID string, currency money.Currency, from, to time.Time) ([]models.LedgerEntry, error)
+}
+
+type GormLedgerRepository struct{}
+
+func NewGormLedgerRepository() *GormLedgerRepository {
+	return &GormLedgerRepository{}
+}
+
+func (r *GormLedgerRepository) CreateLedgerEntry(ctx context.Context, entry *models.LedgerEntry) error {
+	return db.Conn(ctx).Create(entry).Error
+}
+
+// GetBalanceBefore sums debits minus credits posted before the given time
+func (r *GormLedgerRepository) GetBalanceBefore(ctx context.Context, customerID string, currency money.Currency, before time.Time) (money.Amount, error) {
+	var balance money.Amount
+	err := db.Conn(ctx).Model(&models.LedgerEntry{}).
+		Where("customer_id = ? AND currency = ? AND posted_at < ?", customerID, currency, before).
+		Select("COALESCE(SUM(debit - credit), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// GetLedgerEntries lists the entries posted in [from, to) in posting order
+func (r *GormLedgerRepository) GetLedgerEntries(ctx context.Context, customerID string, currency money.Currency, from, to time.Time) ([]models.LedgerEntry, error) {
+	var entries []models.LedgerEntry
+	err := db.Conn(ctx).
+		Where("customer_id = ? AND currency = ? AND posted_at >= ? AND posted_at < ?", customerID, currency, from, to).
+		Order("posted_at, created_at").
+		Find(&entries).Error
+	return entries, err
+}
@@ -20,12 +20,12 @@ func NewGormPackageRepository() *GormPackageRepository {
 }
 
 func (r *GormPackageRepository) CreatePackage(ctx context.Context, pkg *models.Package) error {
-	return db.DB.WithContext(ctx).Create(pkg).Error
+	return db.Conn(ctx).Create(pkg).Error
 }
 
 func (r *GormPackageRepository) GetPackageByID(ctx context.Context, id string) (*models.Package, error) {
 	var pkg models.Package
-	err := db.DB.WithContext(ctx).First(&pkg, "id = ?", id).Error
+	err := db.Conn(ctx).First(&pkg, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -35,7 +35,7 @@ func (r *GormPackageRepository) GetPackageByID(ctx context.Context, id string) (
 func (r *GormPackageRepository) GetAllPackages(ctx context.Context, packageType string, page, pageSize int) ([]models.Package, int64, error) {
 	var packages []models.Package
 	var total int64
-	query := db.DB.WithContext(ctx)
+	query := db.Conn(ctx)
 
 	if packageType != "" {
 		query = query.Where("type = ?", packageType)
@@ -56,5 +56,5 @@ func (r *GormPackageRepository) GetAllPackages(ctx context.Context, packageType
 }
 
 func (r *GormPackageRepository) DeletePackage(ctx context.Context, id string) error {
-	return db.DB.WithContext(ctx).Delete(&models.Package{}, "id = ?", id).Error
+	return db.Conn(ctx).Delete(&models.Package{}, "id = ?", id).Error
 }
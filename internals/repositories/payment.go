@@ -2,16 +2,22 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/pkg/db"
+	"gorm.io/gorm"
 )
 
 type PaymentRepository interface {
 	CreatePayment(ctx context.Context, payment *models.Payment) error
 	GetPaymentByID(ctx context.Context, id string) (*models.Payment, error)
+	GetPaymentForUpdate(ctx context.Context, id string) (*models.Payment, error)
+	GetPaymentByReceiptNumber(ctx context.Context, number string) (*models.Payment, error)
 	GetAllPayments(ctx context.Context) ([]models.Payment, error)
+	CreatePaymentAllocations(ctx context.Context, allocations []models.PaymentAllocation) error
+	GetAllocationsByPaymentID(ctx context.Context, paymentID string) ([]models.PaymentAllocation, error)
+	GetAllocationsByInvoiceID(ctx context.Context, invoiceID string) ([]models.PaymentAllocation, error)
 	UpdatePayment(ctx context.Context, payment *models.Payment) error
-	DeletePayment(ctx context.Context, id string) error
 }
 
 type GormPaymentRepository struct{}
@@ -20,26 +26,80 @@ func NewGormPaymentRepository() *GormPaymentRepository {
 	return &GormPaymentRepository{}
 }
 
+// CreatePayment inserts the payment, giving incoming payments the next receipt number
 func (r *GormPaymentRepository) CreatePayment(ctx context.Context, payment *models.Payment) error {
-	return db.DB.WithContext(ctx).Create(payment).Error
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		if err := db.Conn(ctx).Create(payment).Error; err != nil {
+			return err
+		}
+		if payment.Type != models.PaymentIncoming {
+			return nil
+		}
+
+		date := payment.PaidAt
+		if date.IsZero() {
+			date = payment.CreatedAt
+		}
+		number, err := nextDocumentNumber(ctx, receiptNumberPrefix, date)
+		if err != nil {
+			return err
+		}
+		payment.ReceiptNumber = &number
+		return db.Conn(ctx).Model(payment).UpdateColumn("receipt_number", number).Error
+	})
+}
+
+// GetPaymentByReceiptNumber returns nil without error when no payment has the number
+func (r *GormPaymentRepository) GetPaymentByReceiptNumber(ctx context.Context, number string) (*models.Payment, error) {
+	var payment models.Payment
+	err := db.Conn(ctx).First(&payment, "receipt_number = ?", number).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
 }
 
 func (r *GormPaymentRepository) GetPaymentByID(ctx context.Context, id string) (*models.Payment, error) {
 	var payment models.Payment
-	err := db.DB.WithContext(ctx).First(&payment, "id = ?", id).Error
+	err := db.Conn(ctx).First(&payment, "id = ?", id).Error
+	return &payment, err
+}
+
+// GetPaymentForUpdate loads the payment and locks its row until the surrounding transaction ends
+func (r *GormPaymentRepository) GetPaymentForUpdate(ctx context.Context, id string) (*models.Payment, error) {
+	var payment models.Payment
+	err := db.ForUpdate(ctx).First(&payment, "id = ?", id).Error
 	return &payment, err
 }
 
 func (r *GormPaymentRepository) GetAllPayments(ctx context.Context) ([]models.Payment, error) {
 	var payments []models.Payment
-	err := db.DB.WithContext(ctx).Find(&payments).Error
+	err := db.Conn(ctx).Find(&payments).Error
 	return payments, err
 }
 
-func (r *GormPaymentRepository) UpdatePayment(ctx context.Context, payment *models.Payment) error {
-	return db.DB.WithContext(ctx).Save(payment).Error
+func (r *GormPaymentRepository) CreatePaymentAllocations(ctx context.Context, allocations []models.PaymentAllocation) error {
+	if len(allocations) == 0 {
+		return nil
+	}
+	return db.Conn(ctx).Create(&allocations).Error
+}
+
+func (r *GormPaymentRepository) GetAllocationsByPaymentID(ctx context.Context, paymentID string) ([]models.PaymentAllocation, error) {
+	var allocations []models.PaymentAllocation
+	err := db.Conn(ctx).Where("payment_id = ?", paymentID).Order("created_at").Find(&allocations).Error
+	return allocations, err
 }
 
-func (r *GormPaymentRepository) DeletePayment(ctx context.Context, id string) error {
-	return db.DB.WithContext(ctx).Delete(&models.Payment{}, "id = ?", id).Error
+func (r *GormPaymentRepository) GetAllocationsByInvoiceID(ctx context.Context, invoiceID string) ([]models.PaymentAllocation, error) {
+	var allocations []models.PaymentAllocation
+	err := db.Conn(ctx).Where("invoice_id = ?", invoiceID).Order("created_at").Find(&allocations).Error
+	return allocations, err
+}
+
+func (r *GormPaymentRepository) UpdatePayment(ctx context.Context, payment *models.Payment) error {
+	return db.Conn(ctx).Save(payment).Error
 }
@@ -2,19 +2,39 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/pkg/db"
+	"gorm.io/gorm"
 	"time"
 )
 
 type SubscriptionRepository interface {
 	CreateSubscription(ctx context.Context, subscription *models.Subscription) error
 	GetSubscription(ctx context.Context, id string) (*models.Subscription, error)
+	GetSubscriptionForUpdate(ctx context.Context, id string) (*models.Subscription, error)
 	GetSubscriptionsByCustomerID(ctx context.Context, customerID string) ([]models.Subscription, error)
 	UpdateSubscription(ctx context.Context, subscription *models.Subscription) error
 	DeleteSubscription(ctx context.Context, id string) error
 	GetSubscriptionsPaginated(ctx context.Context, page, pageSize int) ([]models.Subscription, int64, error)
-	GetExpiredSubscriptions(ctx context.Context) ([]models.Subscription, error)
+	GetExpiredSubscriptions(ctx context.Context, cutoff time.Time) ([]models.Subscription, error)
+	GetActiveSubscriptionsRenewingBetween(ctx context.Context, from, to time.Time) ([]models.Subscription, error)
+	CreateSubscriptionRenewal(ctx context.Context, renewal *models.SubscriptionRenewal) error
+	GetSubscriptionRenewal(ctx context.Context, invoiceID, subscriptionID string) (*models.SubscriptionRenewal, error)
+	DeleteSubscriptionRenewal(ctx context.Context, id string) error
+	CreateSubscriptionStatusChange(ctx context.Context, change *models.SubscriptionStatusChange) error
+	GetSubscriptionStatusChanges(ctx context.Context, subscriptionID string) ([]models.SubscriptionStatusChange, error)
+	CreatePackageChange(ctx context.Context, change *models.SubscriptionPackageChange) error
+	UpdatePackageChange(ctx context.Context, change *models.SubscriptionPackageChange) error
+	GetPackageChanges(ctx context.Context, subscriptionID string) ([]models.SubscriptionPackageChange, error)
+	GetScheduledPackageChange(ctx context.Context, subscriptionID string) (*models.SubscriptionPackageChange, error)
+	GetDuePackageChanges(ctx context.Context, before time.Time) ([]models.SubscriptionPackageChange, error)
+	CreateSubscriptionHold(ctx context.Context, hold *models.SubscriptionHold) error
+	UpdateSubscriptionHold(ctx context.Context, hold *models.SubscriptionHold) error
+	GetSubscriptionHolds(ctx context.Context, subscriptionID string) ([]models.SubscriptionHold, error)
+	GetOpenSubscriptionHold(ctx context.Context, subscriptionID string) (*models.SubscriptionHold, error)
+	GetHoldsToStart(ctx context.Context, now time.Time) ([]models.SubscriptionHold, error)
+	GetHoldsToEnd(ctx context.Context, now time.Time) ([]models.SubscriptionHold, error)
 }
 
 type GormSubscriptionRepository struct{}
@@ -24,27 +44,34 @@ func NewGormSubscriptionRepository() *GormSubscriptionRepository {
 }
 
 func (r *GormSubscriptionRepository) CreateSubscription(ctx context.Context, subscription *models.Subscription) error {
-	return db.DB.WithContext(ctx).Create(subscription).Error
+	return db.Conn(ctx).Create(subscription).Error
 }
 
 func (r *GormSubscriptionRepository) GetSubscription(ctx context.Context, id string) (*models.Subscription, error) {
 	var subscription models.Subscription
-	err := db.DB.WithContext(ctx).First(&subscription, "id = ?", id).Error
+	err := db.Conn(ctx).First(&subscription, "id = ?", id).Error
+	return &subscription, err
+}
+
+// GetSubscriptionForUpdate loads the subscription and locks its row until the surrounding transaction ends
+func (r *GormSubscriptionRepository) GetSubscriptionForUpdate(ctx context.Context, id string) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := db.ForUpdate(ctx).First(&subscription, "id = ?", id).Error
 	return &subscription, err
 }
 
 func (r *GormSubscriptionRepository) GetSubscriptionsByCustomerID(ctx context.Context, customerID string) ([]models.Subscription, error) {
 	var subscriptions []models.Subscription
-	err := db.DB.WithContext(ctx).Where("customer_id = ?", customerID).Find(&subscriptions).Error
+	err := db.Conn(ctx).Where("customer_id = ?", customerID).Find(&subscriptions).Error
 	return subscriptions, err
 }
 
 func (r *GormSubscriptionRepository) UpdateSubscription(ctx context.Context, subscription *models.Subscription) error {
-	return db.DB.WithContext(ctx).Save(subscription).Error
+	return db.Conn(ctx).Save(subscription).Error
 }
 
 func (r *GormSubscriptionRepository) DeleteSubscription(ctx context.Context, id string) error {
-	return db.DB.WithContext(ctx).Delete(&models.Subscription{}, "id = ?", id).Error
+	return db.Conn(ctx).Delete(&models.Subscription{}, "id = ?", id).Error
 }
 
 func (r *GormSubscriptionRepository) GetSubscriptionsPaginated(ctx context.Context, page, pageSize int) ([]models.Subscription, int64, error) {
@@ -53,18 +80,142 @@ func (r *GormSubscriptionRepository) GetSubscriptionsPaginated(ctx context.Conte
 
 	offset := (page - 1) * pageSize
 
-	if err := db.DB.WithContext(ctx).Model(&models.Subscription{}).Count(&totalCount).Error; err != nil {
+	if err := db.Conn(ctx).Model(&models.Subscription{}).Count(&totalCount).Error; err != nil {
 		return nil, 0, err
 	}
 
-	if err := db.DB.WithContext(ctx).Offset(offset).Limit(pageSize).Find(&subscriptions).Error; err != nil {
+	if err := db.Conn(ctx).Offset(offset).Limit(pageSize).Find(&subscriptions).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return subscriptions, totalCount, nil
 }
-func (r *GormSubscriptionRepository) GetExpiredSubscriptions(ctx context.Context) ([]models.Subscription, error) {
+
+// GetExpiredSubscriptions returns active subscriptions whose renewal date passed on or before the cutoff
+func (r *GormSubscriptionRepository) GetExpiredSubscriptions(ctx context.Context, cutoff time.Time) ([]models.Subscription, error) {
 	var expiredSubscriptions []models.Subscription
-	err := db.DB.WithContext(ctx).Where("renewal_date <= ? AND status != ?", time.Now(), "Expired").Find(&expiredSubscriptions).Error
+	err := db.Conn(ctx).Where("renewal_date <= ? AND status = ?", cutoff, models.SubscriptionActive).Find(&expiredSubscriptions).Error
 	return expiredSubscriptions, err
 }
+
+func (r *GormSubscriptionRepository) GetActiveSubscriptionsRenewingBetween(ctx context.Context, from, to time.Time) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	err := db.Conn(ctx).
+		Where("status = ? AND renewal_date >= ? AND renewal_date < ?", models.SubscriptionActive, from, to).
+		Order("renewal_date").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *GormSubscriptionRepository) CreateSubscriptionRenewal(ctx context.Context, renewal *models.SubscriptionRenewal) error {
+	return db.Conn(ctx).Create(renewal).Error
+}
+
+// GetSubscriptionRenewal returns nil without error when the invoice did not renew the subscription
+func (r *GormSubscriptionRepository) GetSubscriptionRenewal(ctx context.Context, invoiceID, subscriptionID string) (*models.SubscriptionRenewal, error) {
+	var renewal models.SubscriptionRenewal
+	err := db.Conn(ctx).First(&renewal, "invoice_id = ? AND subscription_id = ?", invoiceID, subscriptionID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &renewal, nil
+}
+
+func (r *GormSubscriptionRepository) DeleteSubscriptionRenewal(ctx context.Context, id string) error {
+	return db.Conn(ctx).Delete(&models.SubscriptionRenewal{}, "id = ?", id).Error
+}
+
+func (r *GormSubscriptionRepository) CreateSubscriptionStatusChange(ctx context.Context, change *models.SubscriptionStatusChange) error {
+	return db.Conn(ctx).Create(change).Error
+}
+
+// GetSubscriptionStatusChanges returns the status history of the subscription, oldest first
+func (r *GormSubscriptionRepository) GetSubscriptionStatusChanges(ctx context.Context, subscriptionID string) ([]models.SubscriptionStatusChange, error) {
+	var changes []models.SubscriptionStatusChange
+	err := db.Conn(ctx).Where("subscription_id = ?", subscriptionID).Order("changed_at").Find(&changes).Error
+	return changes, err
+}
+
+func (r *GormSubscriptionRepository) CreatePackageChange(ctx context.Context, change *models.SubscriptionPackageChange) error {
+	return db.Conn(ctx).Create(change).Error
+}
+
+func (r *GormSubscriptionRepository) UpdatePackageChange(ctx context.Context, change *models.SubscriptionPackageChange) error {
+	return db.Conn(ctx).Save(change).Error
+}
+
+// GetPackageChanges returns the package history of the subscription, newest first
+func (r *GormSubscriptionRepository) GetPackageChanges(ctx context.Context, subscriptionID string) ([]models.SubscriptionPackageChange, error) {
+	var changes []models.SubscriptionPackageChange
+	err := db.Conn(ctx).Where("subscription_id = ?", subscriptionID).Order("created_at DESC").Find(&changes).Error
+	return changes, err
+}
+
+// GetScheduledPackageChange returns nil without error when no change is waiting for the next cycle
+func (r *GormSubscriptionRepository) GetScheduledPackageChange(ctx context.Context, subscriptionID string) (*models.SubscriptionPackageChange, error) {
+	var change models.SubscriptionPackageChange
+	err := db.Conn(ctx).First(&change, "subscription_id = ? AND status = ?", subscriptionID, models.PackageChangeScheduled).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// GetDuePackageChanges returns the scheduled changes taking effect before the given time
+func (r *GormSubscriptionRepository) GetDuePackageChanges(ctx context.Context, before time.Time) ([]models.SubscriptionPackageChange, error) {
+	var changes []models.SubscriptionPackageChange
+	err := db.Conn(ctx).
+		Where("status = ? AND effective_at < ?", models.PackageChangeScheduled, before).
+		Order("effective_at").
+		Find(&changes).Error
+	return changes, err
+}
+
+func (r *GormSubscriptionRepository) CreateSubscriptionHold(ctx context.Context, hold *models.SubscriptionHold) error {
+	return db.Conn(ctx).Create(hold).Error
+}
+
+func (r *GormSubscriptionRepository) UpdateSubscriptionHold(ctx context.Context, hold *models.SubscriptionHold) error {
+	return db.Conn(ctx).Save(hold).Error
+}
+
+// GetSubscriptionHolds returns the holds of the subscription, latest start first
+func (r *GormSubscriptionRepository) GetSubscriptionHolds(ctx context.Context, subscriptionID string) ([]models.SubscriptionHold, error) {
+	var holds []models.SubscriptionHold
+	err := db.Conn(ctx).Where("subscription_id = ?", subscriptionID).Order("start_date DESC").Find(&holds).Error
+	return holds, err
+}
+
+// GetOpenSubscriptionHold returns the scheduled or running hold of the subscription, or nil without error
+func (r *GormSubscriptionRepository) GetOpenSubscriptionHold(ctx context.Context, subscriptionID string) (*models.SubscriptionHold, error) {
+	var hold models.SubscriptionHold
+	err := db.Conn(ctx).
+		First(&hold, "subscription_id = ? AND status IN ?", subscriptionID, []models.HoldStatus{models.HoldScheduled, models.HoldActive}).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// GetHoldsToStart returns scheduled holds whose start date has come
+func (r *GormSubscriptionRepository) GetHoldsToStart(ctx context.Context, now time.Time) ([]models.SubscriptionHold, error) {
+	var holds []models.SubscriptionHold
+	err := db.Conn(ctx).Where("status = ? AND start_date <= ?", models.HoldScheduled, now).Order("start_date").Find(&holds).Error
+	return holds, err
+}
+
+// GetHoldsToEnd returns running holds whose end date has come
+func (r *GormSubscriptionRepository) GetHoldsToEnd(ctx context.Context, now time.Time) ([]models.SubscriptionHold, error) {
+	var holds []models.SubscriptionHold
+	err := db.Conn(ctx).Where("status = ? AND end_date <= ?", models.HoldActive, now).Order("end_date").Find(&holds).Error
+	return holds, err
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+const billingPeriodLayout = "2006-01"
+
+// BillingPeriod is a calendar month, covering [Start, End)
+type BillingPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (p BillingPeriod) String() string {
+	return p.Start.Format(billingPeriodLayout)
+}
+
+func NewBillingPeriod(date time.Time) BillingPeriod {
+	year, month, _ := date.Date()
+	start := time.Date(year, month, 1, 0, 0, 0, 0, date.Location())
+	return BillingPeriod{Start: start, End: start.AddDate(0, 1, 0)}
+}
+
+// FirstDayOfNextMonth is the first instant of the month after date, when a subscription renews
+func FirstDayOfNextMonth(date time.Time) time.Time {
+	year, month, _ := date.Date()
+	return time.Date(year, month+1, 1, 0, 0, 0, 0, date.Location())
+}
+
+func startOfDay(date time.Time) time.Time {
+	year, month, day := date.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+}
+
+func addMonths(date time.Time, months int) time.Time {
+	return date.AddDate(0, months, 0)
+}
+
+// ParseBillingPeriod parses a period in YYYY-MM form, e.g. 2026-11
+func ParseBillingPeriod(value string) (BillingPeriod, error) {
+	start, err := time.ParseInLocation(billingPeriodLayout, value, time.Local)
+	if err != nil {
+		return BillingPeriod{}, fmt.Errorf("invalid billing period %q, expected YYYY-MM", value)
+	}
+	return NewBillingPeriod(start), nil
+}
+
+type BillingRunResult struct {
+	Period  string `json:"period"`
+	Created int    `json:"created"`
+	Skipped int    `json:"skipped"`
+	Failed  int    `json:"failed"`
+}
+
+type BillingService struct {
+	subscriptionRepo repositories.SubscriptionRepository
+	invoiceRepo      repositories.InvoiceRepository
+	packageRepo      repositories.PackageRepository
+	settlement       settlement
+	ledger           ledger
+}
+
+func NewBillingService(
+	sr repositories.SubscriptionRepository,
+	ir repositories.InvoiceRepository,
+	pr repositories.PackageRepository,
+	cr repositories.CustomerCreditRepository,
+	lr repositories.LedgerRepository) *BillingService {
+	return &BillingService{
+		subscriptionRepo: sr,
+		invoiceRepo:      ir,
+		packageRepo:      pr,
+		settlement:       settlement{invoiceRepo: ir, subscriptionRepo: sr, creditRepo: cr},
+		ledger:           ledger{repo: lr},
+	}
+}
+
+func getMonthlyPrice(subscription *models.Subscription) money.Amount {
+	return subscription.PackagePrice
+}
+
+// MonthlyCharge is the amount billed for one month of a subscription after its discount
+func MonthlyCharge(subscription *models.Subscription) money.Amount {
+	return money.Max(getMonthlyPrice(subscription)-subscription.MonthlyDiscount, 0)
+}
+
+// RunBilling creates one consolidated invoice per customer covering all of their active
+// subscriptions renewing within the period, settling it from customer credit where available.
+// Package changes scheduled for cycles in the period take effect first.
+// Subscriptions that become billable after their customer's invoice for the period was issued,
+// e.g. when a hold ends mid-month or a suspended subscription resumes, are billed on invoices of
+// their own. It is safe to run repeatedly: subscriptions already billed for the period are skipped.
+func (s *BillingService) RunBilling(ctx context.Context, period BillingPeriod) (*BillingRunResult, error) {
+	result := &BillingRunResult{Period: period.String()}
+
+	if err := applyScheduledPackageChanges(ctx, s.subscriptionRepo, period.End); err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := s.subscriptionRepo.GetActiveSubscriptionsRenewingBetween(ctx, period.Start, period.End)
+	if err != nil {
+		logger.Error("Failed to get subscriptions due for billing", zap.Error(err), zap.String("period", result.Period))
+		return nil, err
+	}
+
+	invoiced, err := s.invoiceRepo.GetInvoicedSubscriptionIDs(ctx, result.Period)
+	if err != nil {
+		logger.Error("Failed to get invoiced subscriptions", zap.Error(err), zap.String("period", result.Period))
+		return nil, err
+	}
+
+	var customerIDs []string
+	byCustomer := make(map[string][]*models.Subscription)
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if invoiced[subscription.ID] {
+			continue
+		}
+		if _, ok := byCustomer[subscription.CustomerID]; !ok {
+			customerIDs = append(customerIDs, subscription.CustomerID)
+		}
+		byCustomer[subscription.CustomerID] = append(byCustomer[subscription.CustomerID], subscription)
+	}
+
+	packageNames := make(map[string]string)
+	for _, customerID := range customerIDs {
+		invoice, err := s.buildInvoice(ctx, customerID, byCustomer[customerID], period, packageNames)
+		if err != nil {
+			logger.Error("Failed to build invoice", zap.Error(err), zap.String("customerID", customerID), zap.String("period", result.Period))
+			result.Failed++
+			continue
+		}
+
+		created, err := s.createInvoice(ctx, invoice)
+		if err != nil {
+			logger.Error("Failed to create invoice", zap.Error(err), zap.String("customerID", customerID), zap.String("period", result.Period))
+			result.Failed++
+			continue
+		}
+		if created {
+			result.Created++
+			continue
+		}
+
+		s.billSeparately(ctx, customerID, byCustomer[customerID], period, packageNames, result)
+	}
+
+	logger.Info("Billing run completed",
+		zap.String("period", result.Period),
+		zap.Int("created", result.Created),
+		zap.Int("skipped", result.Skipped),
+		zap.Int("failed", result.Failed),
+	)
+
+	if result.Failed > 0 {
+		return result, fmt.Errorf("failed to create %d invoice(s) for period %s", result.Failed, result.Period)
+	}
+	return result, nil
+}
+
+// billSeparately bills each subscription on a supplementary invoice of its own, for customers whose
+// consolidated invoice for the period was issued before these subscriptions became billable.
+// The invoice carries the subscription, so it is still created at most once per period.
+func (s *BillingService) billSeparately(ctx context.Context, customerID string, subscriptions []*models.Subscription, period BillingPeriod, packageNames map[string]string, result *BillingRunResult) {
+	for _, subscription := range subscriptions {
+		invoice, err := s.buildInvoice(ctx, customerID, []*models.Subscription{subscription}, period, packageNames)
+		created := false
+		if err == nil {
+			subscriptionID := subscription.ID
+			invoice.SubscriptionID = &subscriptionID
+			created, err = s.createInvoice(ctx, invoice)
+		}
+
+		switch {
+		case err != nil:
+			logger.Error("Failed to create supplementary invoice", zap.Error(err), zap.String("subscriptionID", subscription.ID), zap.String("period", result.Period))
+			result.Failed++
+		case created:
+			logger.Info("Supplementary invoice created", zap.String("subscriptionID", subscription.ID), zap.String("invoiceID", invoice.ID), zap.String("period", result.Period))
+			result.Created++
+		default:
+			result.Skipped++
+		}
+	}
+}
+
+// createInvoice inserts the invoice unless the customer, or for a supplementary invoice the
+// subscription, is already invoiced for the period and settles it from any credit the customer has
+func (s *BillingService) createInvoice(ctx context.Context, invoice *models.Invoice) (bool, error) {
+	created := false
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = s.invoiceRepo.CreateInvoiceIfNotExists(ctx, invoice)
+		if err != nil || !created {
+			return err
+		}
+		if err := s.ledger.postInvoice(ctx, invoice); err != nil {
+			return err
+		}
+		_, err = s.settlement.applyCredit(ctx, invoice)
+		return err
+	})
+	return created, err
+}
+
+// buildInvoice combines the customer's subscriptions into one invoice due on the earliest renewal date
+func (s *BillingService) buildInvoice(ctx context.Context, customerID string, subscriptions []*models.Subscription, period BillingPeriod, packageNames map[string]string) (*models.Invoice, error) {
+	billingPeriod := period.String()
+	invoice := &models.Invoice{
+		ID:            uuid.New().String(),
+		CustomerID:    customerID,
+		Currency:      subscriptions[0].Currency,
+		Status:        models.InvoicePending,
+		DueDate:       subscriptions[0].RenewalDate,
+		BillingPeriod: &billingPeriod,
+	}
+
+	var lines []models.InvoiceLine
+	for _, subscription := range subscriptions {
+		if subscription.Currency != invoice.Currency {
+			return nil, fmt.Errorf("subscriptions are billed in different currencies (%s, %s)", invoice.Currency, subscription.Currency)
+		}
+		if subscription.RenewalDate.Before(invoice.DueDate) {
+			invoice.DueDate = subscription.RenewalDate
+		}
+
+		name, ok := packageNames[subscription.PackageID]
+		if !ok {
+			pkg, err := s.packageRepo.GetPackageByID(ctx, subscription.PackageID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get package %s: %w", subscription.PackageID, err)
+			}
+			name = pkg.Name
+			packageNames[subscription.PackageID] = name
+		}
+
+		lines = append(lines, SubscriptionLines(subscription, name, billingPeriod)...)
+	}
+
+	if err := SetInvoiceLines(invoice, lines); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+// defaultUsefulLifeKey is the useful life used for device types that have none of their own
+const defaultUsefulLifeKey = "default"
+
+// UsefulLives holds the straight-line useful life in months of each device type, keyed by
+// lowercased type. Types without a life of their own or a default are not depreciated.
+type UsefulLives map[string]int
+
+// LoadUsefulLives reads inventory.depreciation.useful_life_months, keyed by device type
+// (case-insensitive) with an optional "default" entry
+func LoadUsefulLives() (UsefulLives, error) {
+	var configs map[string]int
+	if err := viper.UnmarshalKey("inventory.depreciation.useful_life_months", &configs); err != nil {
+		return nil, fmt.Errorf("invalid useful lives: %w", err)
+	}
+
+	lives := make(UsefulLives, len(configs))
+	for key, months := range configs {
+		if months <= 0 {
+			return nil, fmt.Errorf("invalid useful life %q: must be a positive number of months", key)
+		}
+		lives[strings.ToLower(key)] = months
+	}
+	return lives, nil
+}
+
+// For is the useful life of the device type in months, zero when it is not depreciated
+func (l UsefulLives) For(deviceType models.DeviceType) int {
+	if months, ok := l[strings.ToLower(string(deviceType))]; ok {
+		return months
+	}
+	return l[defaultUsefulLifeKey]
+}
+
+// Depreciation is where a device's cost stands on a given date
+type Depreciation struct {
+	UsefulLifeMonths int          `json:"usefulLifeMonths"`
+	MonthsElapsed    int          `json:"monthsElapsed"`
+	MonthlyCharge    money.Amount `json:"monthlyCharge"`
+	Accumulated      money.Amount `json:"accumulated"`
+	BookValue        money.Amount `json:"bookValue"`
+}
+
+// depreciate writes the cost down in equal monthly charges over the useful life, one for each full
+// month since the purchase date. Without a useful life the device keeps its cost.
+func depreciate(cost money.Amount, purchased, asOf time.Time, lifeMonths int) Depreciation {
+	d := Depreciation{UsefulLifeMonths: lifeMonths, BookValue: cost}
+	if lifeMonths <= 0 || cost <= 0 {
+		return d
+	}
+
+	d.MonthlyCharge = cost.MulRatio(1, int64(lifeMonths))
+	d.MonthsElapsed = min(completedMonths(purchased, asOf), lifeMonths)
+	d.Accumulated = cost.MulRatio(int64(d.MonthsElapsed), int64(lifeMonths))
+	d.BookValue = cost - d.Accumulated
+	return d
+}
+
+// completedMonths counts the full months from one date to another, e.g. one from 15 March to 15 April
+func completedMonths(from, to time.Time) int {
+	months := (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	if to.Day() < from.Day() {
+		months--
+	}
+	return max(months, 0)
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestDepreciate(t *testing.T) {
+	purchased := time.Date(2026, time.January, 15, 0, 0, 0, 0, time.Local)
+	cost := money.FromMajor(3600)
+
+	d := depreciate(cost, purchased, time.Date(2026, time.October, 14, 0, 0, 0, 0, time.Local), 36)
+	assert.Equal(t, Depreciation{
+		UsefulLifeMonths: 36,
+		MonthsElapsed:    8,
+		MonthlyCharge:    money.FromMajor(100),
+		Accumulated:      money.FromMajor(800),
+		BookValue:        money.FromMajor(2800),
+	}, d)
+
+	d = depreciate(cost, purchased, time.Date(2030, time.March, 1, 0, 0, 0, 0, time.Local), 36)
+	assert.Equal(t, 36, d.MonthsElapsed, "depreciation stops at the end of the useful life")
+	assert.Equal(t, money.Amount(0), d.BookValue)
+
+	d = depreciate(money.FromMajor(1000), purchased, time.Date(2026, time.February, 15, 0, 0, 0, 0, time.Local), 3)
+	assert.Equal(t, money.MustParse("333.33"), d.Accumulated)
+	assert.Equal(t, money.MustParse("666.67"), d.BookValue)
+
+	assert.Equal(t, cost, depreciate(cost, purchased, purchased.AddDate(2, 0, 0), 0).BookValue, "types without a useful life keep their cost")
+	assert.Equal(t, 0, completedMonths(purchased, purchased.AddDate(0, 0, -1)))
+}
+
+func TestLoadUsefulLives(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	viper.Set("inventory.depreciation.useful_life_months", map[string]interface{}{"ONU": 36, "default": 60})
+	lives, err := LoadUsefulLives()
+	require.NoError(t, err)
+	assert.Equal(t, 36, lives.For(models.ONU))
+	assert.Equal(t, 60, lives.For(models.OLT))
+	assert.Equal(t, 0, UsefulLives{"onu": 36}.For(models.OLT))
+
+	viper.Set("inventory.depreciation.useful_life_months", map[string]interface{}{"onu": 0})
+	_, err = LoadUsefulLives()
+	assert.Error(t, err)
+}
+
+func TestBuildInventoryReport(t *testing.T) {
+	asOf := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.Local)
+	purchased := asOf.AddDate(0, -6, 0)
+	price := func(value string) *money.Amount {
+		amount := money.MustParse(value)
+		return &amount
+	}
+	devices := []models.Device{
+		{ID: "1", Type: models.ONU, Brand: "ZTE", Status: models.InStock, PurchasePrice: price("1200"), PurchaseDate: &purchased},
+		{ID: "2", Type: models.ONU, Brand: "ZTE", Status: models.Assigned, PurchasePrice: price("1200"), PurchaseDate: &purchased},
+		{ID: "3", Type: models.ONU, Brand: "Huawei", Status: models.PendingCollection},
+		{ID: "4", Type: models.Switch, Brand: "TP-Link", Status: models.UnderRepair, PurchasePrice: price("5000"), PurchaseDate: &purchased},
+		{ID: "5", Type: models.Router, Brand: "TP-Link", Status: models.WrittenOff, PurchasePrice: price("2000"), PurchaseDate: &purchased},
+	}
+	lives := UsefulLives{"onu": 12}
+
+	report := buildInventoryReport(devices, lives, asOf)
+	assert.Equal(t, 5, report.Total)
+	assert.Equal(t, map[models.DeviceType]int{models.ONU: 3, models.Switch: 1, models.Router: 1}, report.ByType)
+	assert.Equal(t, map[string]int{"ZTE": 2, "Huawei": 1, "TP-Link": 2}, report.ByBrand)
+	assert.Equal(t, 1, report.ByStatus[models.WrittenOff])
+	assert.Equal(t, InventoryValue{Devices: 1, Cost: money.FromMajor(1200), BookValue: money.FromMajor(600)}, report.OnHand)
+	assert.Equal(t, InventoryValue{Devices: 2, Cost: money.FromMajor(1200), BookValue: money.FromMajor(600)}, report.Deployed)
+	assert.Equal(t, InventoryValue{Devices: 1, Cost: money.FromMajor(5000), BookValue: money.FromMajor(5000)}, report.Impaired)
+	assert.Equal(t, 1, report.Unpriced)
+
+	schedule := buildDepreciationReport(devices, lives, asOf)
+	require.Len(t, schedule.Devices, 3, "unpriced and written-off devices are left out")
+	assert.Equal(t, money.FromMajor(7400), schedule.Cost)
+	assert.Equal(t, money.FromMajor(1200), schedule.Accumulated)
+	assert.Equal(t, money.FromMajor(6200), schedule.BookValue)
+}
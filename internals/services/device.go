@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeviceNotFound          = errors.New("device not found")
+	ErrInvalidDeviceTransition = errors.New("invalid device status transition")
+	ErrInvalidAssignment       = errors.New("invalid device assignment")
+	ErrAssignmentConflict      = errors.New("device cannot be assigned there")
+)
+
+// inventory moves devices through their statuses and between stock, subscriptions and buildings,
+// enforcing the transition table and recording every move in the device's history along with
+// who made it
+type inventory struct {
+	repo repositories.DeviceRepository
+}
+
+// assign hands an in-stock device to a subscription or building; callers check the target exists
+func (i inventory) assign(ctx context.Context, deviceID, assignmentType, assignmentID, note string) error {
+	if assignmentType != "Subscription" && assignmentType != "Building" {
+		return fmt.Errorf("%w: assignment type must be Subscription or Building", ErrInvalidAssignment)
+	}
+
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		device, err := i.lock(ctx, deviceID)
+		if err != nil {
+			return err
+		}
+		if !device.Status.CanTransitionTo(models.Assigned) {
+			return fmt.Errorf("%w: a device that is %s cannot be assigned", ErrInvalidDeviceTransition, device.Status)
+		}
+		if err := i.repo.AssignDevice(ctx, deviceID, assignmentType, assignmentID); err != nil {
+			return err
+		}
+
+		event := newDeviceEvent(ctx, device, models.DeviceEventAssigned, models.Assigned, note)
+		if assignmentType == "Subscription" {
+			event.SubscriptionID = &assignmentID
+		} else {
+			event.BuildingID = &assignmentID
+		}
+		return i.repo.CreateDeviceEvent(ctx, event)
+	})
+}
+
+// unassign takes the device back to stock from wherever it is assigned
+func (i inventory) unassign(ctx context.Context, deviceID, note string) error {
+	return i.move(ctx, deviceID, models.InStock, note, func(device *models.Device) error {
+		if !device.Status.IsAssigned() {
+			return fmt.Errorf("%w: a device that is %s is not assigned", ErrInvalidDeviceTransition, device.Status)
+		}
+		return nil
+	})
+}
+
+// markForCollection flags an assigned device for the field team; one already flagged is left alone
+func (i inventory) markForCollection(ctx context.Context, deviceID, note string) error {
+	return i.move(ctx, deviceID, models.PendingCollection, note, nil)
+}
+
+func (i inventory) setStatus(ctx context.Context, deviceID string, to models.DeviceStatus, note string) error {
+	return i.move(ctx, deviceID, to, note, nil)
+}
+
+// move takes the device to a new status if the transition table allows it. A device leaving a
+// subscription or building is unassigned from it, and the event keeps where it was taken from.
+// Moving a device to the status it already has changes nothing.
+func (i inventory) move(ctx context.Context, deviceID string, to models.DeviceStatus, note string, check func(*models.Device) error) error {
+	if !to.IsValid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidDeviceTransition, to)
+	}
+	if to == models.Assigned {
+		return fmt.Errorf("%w: devices are assigned to a subscription or building instead", ErrInvalidDeviceTransition)
+	}
+	if to == models.WrittenOff {
+		return fmt.Errorf("%w: devices are written off through a write-off, which posts the loss", ErrInvalidDeviceTransition)
+	}
+
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		device, err := i.lock(ctx, deviceID)
+		if err != nil {
+			return err
+		}
+		if check != nil {
+			if err := check(device); err != nil {
+				return err
+			}
+		}
+		if device.Status == to {
+			return nil
+		}
+		if !device.Status.CanTransitionTo(to) {
+			return fmt.Errorf("%w: cannot move from %s to %s", ErrInvalidDeviceTransition, device.Status, to)
+		}
+
+		switch {
+		case to == models.PendingCollection:
+			err = i.repo.MarkDeviceForCollection(ctx, deviceID)
+		case device.Status.IsAssigned() && !to.IsAssigned():
+			if err = i.repo.UnassignDevice(ctx, deviceID); err == nil && to != models.InStock {
+				err = i.repo.MarkDeviceStatus(ctx, deviceID, to)
+			}
+		default:
+			err = i.repo.MarkDeviceStatus(ctx, deviceID, to)
+		}
+		if err != nil {
+			return err
+		}
+		return i.repo.CreateDeviceEvent(ctx, newDeviceEvent(ctx, device, deviceEventType(device.Status, to), to, note))
+	})
+}
+
+// deviceEventType names a status change for the device's history
+func deviceEventType(from, to models.DeviceStatus) models.DeviceEventType {
+	switch {
+	case to == models.PendingCollection, from == models.PendingCollection:
+		return models.DeviceEventCollection
+	case from == models.Assigned && to == models.InStock:
+		return models.DeviceEventUnassigned
+	case to == models.UnderRepair, from == models.UnderRepair:
+		return models.DeviceEventRepair
+	}
+	return models.DeviceEventStatusChanged
+}
+
+func (i inventory) lock(ctx context.Context, deviceID string) (*models.Device, error) {
+	device, err := i.repo.GetDeviceForUpdate(ctx, deviceID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeviceNotFound
+	}
+	return device, err
+}
+
+// newDeviceEvent describes a change to the device, given as it was before the change
+func newDeviceEvent(ctx context.Context, device *models.Device, eventType models.DeviceEventType, to models.DeviceStatus, note string) *models.DeviceEvent {
+	return &models.DeviceEvent{
+		ID:             uuid.New().String(),
+		DeviceID:       device.ID,
+		Type:           eventType,
+		FromStatus:     device.Status,
+		ToStatus:       to,
+		SubscriptionID: device.SubscriptionID,
+		BuildingID:     device.BuildingID,
+		Note:           note,
+		Actor:          auth.ActorID(ctx),
+		OccurredAt:     time.Now(),
+	}
+}
+
+type DeviceService struct {
+	deviceRepo       repositories.DeviceRepository
+	subscriptionRepo repositories.SubscriptionRepository
+	buildingRepo     repositories.BuildingRepository
+	expenseRepo      repositories.ExpenseRepository
+	usefulLives      UsefulLives
+	inventory        inventory
+}
+
+func NewDeviceService(
+	dr repositories.DeviceRepository,
+	sr repositories.SubscriptionRepository,
+	br repositories.BuildingRepository,
+	er repositories.ExpenseRepository,
+	usefulLives UsefulLives) *DeviceService {
+	return &DeviceService{
+		deviceRepo:       dr,
+		subscriptionRepo: sr,
+		buildingRepo:     br,
+		expenseRepo:      er,
+		usefulLives:      usefulLives,
+		inventory:        inventory{repo: dr},
+	}
+}
+
+// AssignDevice hands an in-stock device to an existing subscription or building. A subscription
+// without a device of record takes this one.
+func (s *DeviceService) AssignDevice(ctx context.Context, deviceID, assignmentType, assignmentID string) error {
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		var subscription *models.Subscription
+		switch assignmentType {
+		case "Subscription":
+			var err error
+			subscription, err = s.subscriptionRepo.GetSubscriptionForUpdate(ctx, assignmentID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: subscription not found", ErrInvalidAssignment)
+			}
+			if err != nil {
+				return err
+			}
+			if subscription.Status == models.SubscriptionCancelled {
+				return fmt.Errorf("%w: the subscription is cancelled", ErrAssignmentConflict)
+			}
+		case "Building":
+			if _, err := s.buildingRepo.GetBuildingByID(ctx, assignmentID); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: building not found", ErrInvalidAssignment)
+				}
+				return err
+			}
+		}
+
+		if err := s.inventory.assign(ctx, deviceID, assignmentType, assignmentID, ""); err != nil {
+			return err
+		}
+		if subscription != nil && subscription.DeviceID == "" {
+			subscription.DeviceID = deviceID
+			return s.subscriptionRepo.UpdateSubscription(ctx, subscription)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Device assigned", zap.String("deviceID", deviceID), zap.String("assignmentType", assignmentType), zap.String("assignmentID", assignmentID))
+	return nil
+}
+
+func (s *DeviceService) UnassignDevice(ctx context.Context, deviceID string) error {
+	if err := s.inventory.unassign(ctx, deviceID, ""); err != nil {
+		return err
+	}
+	logger.Info("Device unassigned", zap.String("deviceID", deviceID))
+	return nil
+}
+
+// MarkDeviceStatus moves the device to a new status, e.g. when it is collected or found damaged.
+// A device with an open repair ticket moves on when the ticket closes.
+func (s *DeviceService) MarkDeviceStatus(ctx context.Context, deviceID string, status models.DeviceStatus, note string) error {
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := s.inventory.lock(ctx, deviceID); err != nil {
+			return err
+		}
+		open, err := s.deviceRepo.GetOpenRepairTicket(ctx, deviceID)
+		if err != nil {
+			return err
+		}
+		if open != nil {
+			return fmt.Errorf("%w: close repair ticket %s instead", ErrRepairInProgress, open.ID)
+		}
+		return s.inventory.setStatus(ctx, deviceID, status, note)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("Device status changed", zap.String("deviceID", deviceID), zap.String("status", string(status)))
+	return nil
+}
+
+// WriteOffDevice takes a damaged device off the books for good and posts what is left of its
+// cost, its book value today, as a capital expense. The expense is nil when nothing is left.
+func (s *DeviceService) WriteOffDevice(ctx context.Context, deviceID, note string) (*models.Expense, error) {
+	var expense *models.Expense
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		device, err := s.inventory.lock(ctx, deviceID)
+		if err != nil {
+			return err
+		}
+		if !device.Status.CanTransitionTo(models.WrittenOff) {
+			return fmt.Errorf("%w: only damaged devices can be written off, this one is %s", ErrInvalidDeviceTransition, device.Status)
+		}
+
+		now := time.Now()
+		if loss := depreciateDevice(device, s.usefulLives, now).BookValue; loss > 0 {
+			expense = &models.Expense{
+				ID:          uuid.New().String(),
+				Amount:      loss,
+				Currency:    money.DefaultCurrency,
+				Type:        models.CapitalExpense,
+				Description: fmt.Sprintf("Write-off of %s %s %s (serial %s)", device.Type, device.Brand, device.Model, device.SerialNumber),
+				PaidAt:      now,
+			}
+			if err := s.expenseRepo.CreateExpense(ctx, expense); err != nil {
+				return err
+			}
+		}
+
+		var lossExpenseID *string
+		if expense != nil {
+			lossExpenseID = &expense.ID
+		}
+		if err := s.deviceRepo.WriteOffDevice(ctx, deviceID, lossExpenseID); err != nil {
+			return err
+		}
+		return s.deviceRepo.CreateDeviceEvent(ctx, newDeviceEvent(ctx, device, models.DeviceEventWrittenOff, models.WrittenOff, note))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []zap.Field{zap.String("deviceID", deviceID)}
+	if expense != nil {
+		fields = append(fields, zap.String("expenseID", expense.ID), zap.String("loss", expense.Amount.String()))
+	}
+	logger.Info("Device written off", fields...)
+	return expense, nil
+}
+
+// UpdateDevice saves the descriptive details of the device. Its status only changes through
+// assignment and MarkDeviceStatus, so the history stays complete.
+func (s *DeviceService) UpdateDevice(ctx context.Context, id string, update models.Device) (*models.Device, error) {
+	var device *models.Device
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		device, err = s.inventory.lock(ctx, id)
+		if err != nil {
+			return err
+		}
+		if update.Status != "" && update.Status != device.Status {
+			return fmt.Errorf("%w: status cannot be updated directly", ErrInvalidDeviceTransition)
+		}
+
+		device.Brand = update.Brand
+		device.Model = update.Model
+		device.SerialNumber = update.SerialNumber
+		device.Type = update.Type
+		device.Usage = update.Usage
+		device.PurchasePrice = update.PurchasePrice
+		device.PurchaseDate = update.PurchaseDate
+		return s.deviceRepo.UpdateDevice(ctx, device)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// DeleteDevice removes a device from the inventory. Only devices in stock or written off can be
+// deleted, so no subscription or building is left pointing at a missing device.
+func (s *DeviceService) DeleteDevice(ctx context.Context, deviceID string) error {
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		device, err := s.inventory.lock(ctx, deviceID)
+		if err != nil {
+			return err
+		}
+		if device.Status != models.InStock && device.Status != models.WrittenOff {
+			return fmt.Errorf("%w: a device that is %s cannot be deleted", ErrInvalidDeviceTransition, device.Status)
+		}
+		return s.deviceRepo.DeleteDevice(ctx, deviceID)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Device deleted", zap.String("deviceID", deviceID))
+	return nil
+}
+
+// GetHistory returns the device's events, oldest first. The history of a deleted device is still returned.
+func (s *DeviceService) GetHistory(ctx context.Context, deviceID string) ([]models.DeviceEvent, error) {
+	events, err := s.deviceRepo.GetDeviceEvents(ctx, deviceID)
+	if err != nil || len(events) > 0 {
+		return events, err
+	}
+
+	if _, err := s.deviceRepo.GetDeviceByID(ctx, deviceID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	return events, nil
+}
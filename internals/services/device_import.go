@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"github.com/timam/uttarawave-backend/pkg/spreadsheet"
+	"go.uber.org/zap"
+)
+
+// maxDeviceImportRows bounds one import; larger shipments are split over several files
+const maxDeviceImportRows = 5000
+
+var ErrInvalidImport = errors.New("invalid device import")
+
+var errImportPriceDecimals = errors.New("too many decimal places")
+
+// deviceImportColumns maps the accepted header names, lowercased without spaces, dashes or
+// underscores, to the column they stand for
+var deviceImportColumns = map[string]string{
+	"type":          "type",
+	"devicetype":    "type",
+	"brand":         "brand",
+	"model":         "model",
+	"serial":        "serialNumber",
+	"serialnumber":  "serialNumber",
+	"serialno":      "serialNumber",
+	"purchaseprice": "purchasePrice",
+	"price":         "purchasePrice",
+	"purchasedate":  "purchaseDate",
+	"date":          "purchaseDate",
+}
+
+var requiredDeviceImportColumns = []string{"type", "brand", "model", "serialNumber"}
+
+// DeviceImportRowError lists what is wrong with one row; Row is its line number in the file
+type DeviceImportRowError struct {
+	Row          int          `json:"row"`
+	SerialNumber string       `json:"serialNumber,omitempty"`
+	Errors       []FieldError `json:"errors"`
+}
+
+// DeviceImportResult reports an import. Nothing is imported unless every row is valid, and
+// nothing at all on a dry run.
+type DeviceImportResult struct {
+	DryRun   bool                   `json:"dryRun"`
+	Rows     int                    `json:"rows"`
+	Imported int                    `json:"imported"`
+	Errors   []DeviceImportRowError `json:"errors,omitempty"`
+}
+
+func (r *DeviceImportResult) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// ImportDevices takes a shipment into stock from a CSV or XLSX file with a header row naming
+// the type, brand, model and serial columns, and optionally purchase price and date. Every row is
+// checked first, including serial numbers repeated within the file or already in stock, and then
+// all of them are inserted in one transaction.
+func (s *DeviceService) ImportDevices(ctx context.Context, fileName string, data []byte, dryRun bool) (*DeviceImportResult, error) {
+	rows, err := spreadsheet.Read(fileName, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	parsed, err := parseDeviceRows(rows, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	result := &DeviceImportResult{DryRun: dryRun, Rows: len(parsed)}
+
+	err = db.Transaction(ctx, func(ctx context.Context) error {
+		var serialNumbers []string
+		for _, row := range parsed {
+			if row.Device.SerialNumber != "" {
+				serialNumbers = append(serialNumbers, row.Device.SerialNumber)
+			}
+		}
+		existing, err := s.deviceRepo.GetExistingSerialNumbers(ctx, serialNumbers)
+		if err != nil {
+			return err
+		}
+		taken := make(map[string]bool, len(existing))
+		for _, serialNumber := range existing {
+			taken[strings.ToUpper(serialNumber)] = true
+		}
+
+		devices := make([]models.Device, 0, len(parsed))
+		for _, row := range parsed {
+			if taken[strings.ToUpper(row.Device.SerialNumber)] {
+				row.Errors = append(row.Errors, FieldError{Field: "serialNumber", Message: "a device with this serial number already exists"})
+			}
+			if len(row.Errors) > 0 {
+				result.Errors = append(result.Errors, DeviceImportRowError{Row: row.Line, SerialNumber: row.Device.SerialNumber, Errors: row.Errors})
+				continue
+			}
+			devices = append(devices, row.Device)
+		}
+
+		if !result.Valid() || dryRun {
+			return nil
+		}
+		if err := s.deviceRepo.CreateDevices(ctx, devices); err != nil {
+			return err
+		}
+		result.Imported = len(devices)
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to import devices", zap.Error(err))
+		return nil, err
+	}
+
+	logger.Info("Device import processed",
+		zap.Bool("dryRun", dryRun),
+		zap.Int("rows", result.Rows),
+		zap.Int("imported", result.Imported),
+		zap.Int("rejected", len(result.Errors)),
+	)
+	return result, nil
+}
+
+// deviceImportRow is one row of an import file, by its line number, with the problems found in it
+type deviceImportRow struct {
+	Line   int
+	Device models.Device
+	Errors []FieldError
+}
+
+// parseDeviceRows turns the rows after the header into in-stock devices, checking each on its own
+// and for serial numbers repeated within the file. Blank rows are skipped. Problems with the file
+// as a whole are returned as an error.
+func parseDeviceRows(rows [][]string, now time.Time) ([]deviceImportRow, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+	}
+
+	columns := make(map[string]int)
+	normalize := strings.NewReplacer(" ", "", "_", "", "-", "")
+	for i, header := range rows[0] {
+		key := normalize.Replace(strings.ToLower(strings.TrimSpace(header)))
+		if column, ok := deviceImportColumns[key]; ok {
+			if _, seen := columns[column]; !seen {
+				columns[column] = i
+			}
+		}
+	}
+	var missing []string
+	for _, column := range requiredDeviceImportColumns {
+		if _, ok := columns[column]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing column(s) %s", ErrInvalidImport, strings.Join(missing, ", "))
+	}
+
+	var parsed []deviceImportRow
+	firstLine := make(map[string]int)
+	for i, row := range rows[1:] {
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		if len(parsed) == maxDeviceImportRows {
+			return nil, fmt.Errorf("%w: at most %d devices can be imported at once", ErrInvalidImport, maxDeviceImportRows)
+		}
+		cell := func(column string) string {
+			index, ok := columns[column]
+			if !ok || index >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[index])
+		}
+
+		line := i + 2
+		validation := &ValidationError{}
+		device := models.Device{
+			ID:           uuid.New().String(),
+			Type:         models.DeviceType(strings.ToUpper(cell("type"))),
+			Brand:        cell("brand"),
+			Model:        cell("model"),
+			SerialNumber: cell("serialNumber"),
+			Status:       models.InStock,
+			Usage:        models.CompanyUse,
+		}
+
+		if device.Type == "" {
+			validation.add("type", "is required")
+		} else if !device.Type.IsValid() {
+			validation.add("type", fmt.Sprintf("unknown device type %q", cell("type")))
+		}
+		for _, field := range []struct{ name, value string }{
+			{"brand", device.Brand},
+			{"model", device.Model},
+			{"serialNumber", device.SerialNumber},
+		} {
+			if field.value == "" {
+				validation.add(field.name, "is required")
+			} else if len(field.value) > 50 {
+				validation.add(field.name, "must be at most 50 characters")
+			}
+		}
+		if device.SerialNumber != "" {
+			key := strings.ToUpper(device.SerialNumber)
+			if first, ok := firstLine[key]; ok {
+				validation.add("serialNumber", fmt.Sprintf("repeats the serial number on row %d", first))
+			} else {
+				firstLine[key] = line
+			}
+		}
+
+		if value := cell("purchasePrice"); value != "" {
+			price, err := parseImportPrice(value)
+			switch {
+			case errors.Is(err, errImportPriceDecimals):
+				validation.add("purchasePrice", fmt.Sprintf("invalid amount %q, at most %d decimal places are allowed", value, money.MinorDigits))
+			case err != nil || price < 0:
+				validation.add("purchasePrice", fmt.Sprintf("invalid amount %q", value))
+			default:
+				device.PurchasePrice = &price
+			}
+		}
+		purchaseDate := now
+		if value := cell("purchaseDate"); value != "" {
+			date, ok := parseImportDate(value)
+			if !ok {
+				validation.add("purchaseDate", fmt.Sprintf("invalid date %q, expected YYYY-MM-DD or DD/MM/YYYY", value))
+			} else if date.After(now) {
+				validation.add("purchaseDate", "cannot be in the future")
+			} else {
+				purchaseDate = date
+			}
+		}
+		device.PurchaseDate = &purchaseDate
+
+		parsed = append(parsed, deviceImportRow{Line: line, Device: device, Errors: validation.Errors})
+	}
+
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("%w: the file has no devices", ErrInvalidImport)
+	}
+	return parsed, nil
+}
+
+// parseImportPrice reads a price such as "1,450.00". Spreadsheets store numbers as floats, so a
+// price typed as 1450.1 may read back as 1450.0999999999999; only that float noise is rounded
+// away. A price with more decimal places than the currency has, or in exponent form, is refused.
+func parseImportPrice(value string) (money.Amount, error) {
+	value = strings.ReplaceAll(value, ",", "")
+	price, err := money.Parse(value)
+	if err == nil || strings.ContainsAny(value, "eE") {
+		return price, err
+	}
+	f, ferr := strconv.ParseFloat(value, 64)
+	if ferr != nil {
+		return 0, err
+	}
+	scale := math.Pow10(money.MinorDigits)
+	if minor := math.Round(f * scale); math.Abs(f*scale-minor) < 1e-6 {
+		return money.Parse(strconv.FormatFloat(minor/scale, 'f', money.MinorDigits, 64))
+	}
+	return 0, errImportPriceDecimals
+}
+
+// parseImportDate reads a date written out as text or stored by a spreadsheet as a date serial
+func parseImportDate(value string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "02/01/2006"} {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, true
+		}
+	}
+	return spreadsheet.SerialDate(value)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestParseDeviceRows(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.Local)
+	rows := [][]string{
+		{"Type", "Brand", "Model", "Serial Number", "Purchase Price", "Purchase_Date"},
+		{"onu", "ZTE", "F660", "ZTE-001", "1,450.50", "2026-10-01"},
+		{"", "", "", "", "", ""},
+		{"ONU", "ZTE", "F660", "zte-001", "1450", "46312"},
+		{"MODEM", "", "F660", "ZTE-003", "abc", "2027-01-01"},
+		{"ROUTER", "TP-Link", "C6", "TPL-001"},
+		{"ROUTER", "TP-Link", "C6", "TPL-002", "12.345"},
+		{"ROUTER", "TP-Link", "C6", "TPL-003", "1e3"},
+		{"ROUTER", "TP-Link", "C6", "TPL-004", "1450.0999999999999"},
+	}
+
+	parsed, err := parseDeviceRows(rows, now)
+	require.NoError(t, err)
+	require.Len(t, parsed, 7)
+
+	first := parsed[0]
+	assert.Equal(t, 2, first.Line)
+	assert.Empty(t, first.Errors)
+	assert.Equal(t, models.ONU, first.Device.Type)
+	assert.Equal(t, models.InStock, first.Device.Status)
+	assert.Equal(t, money.MustParse("1450.50"), *first.Device.PurchasePrice)
+	assert.Equal(t, "2026-10-01", first.Device.PurchaseDate.Format(time.DateOnly))
+
+	assert.Equal(t, 4, parsed[1].Line, "blank rows are skipped but keep their line")
+	assert.Equal(t, []FieldError{{Field: "serialNumber", Message: "repeats the serial number on row 2"}}, parsed[1].Errors)
+
+	assert.Equal(t, []FieldError{
+		{Field: "type", Message: `unknown device type "MODEM"`},
+		{Field: "brand", Message: "is required"},
+		{Field: "purchasePrice", Message: `invalid amount "abc"`},
+		{Field: "purchaseDate", Message: "cannot be in the future"},
+	}, parsed[2].Errors)
+
+	assert.Equal(t, []FieldError{
+		{Field: "purchasePrice", Message: `invalid amount "12.345", at most 2 decimal places are allowed`},
+	}, parsed[4].Errors, "prices are not rounded to the currency")
+	assert.Equal(t, []FieldError{{Field: "purchasePrice", Message: `invalid amount "1e3"`}}, parsed[5].Errors)
+	assert.Equal(t, money.MustParse("1450.10"), *parsed[6].Device.PurchasePrice, "float noise from the spreadsheet is rounded away")
+
+	last := parsed[3]
+	assert.Empty(t, last.Errors, "price and date columns are optional per row")
+	assert.Nil(t, last.Device.PurchasePrice)
+	assert.Equal(t, now, *last.Device.PurchaseDate)
+
+	_, err = parseDeviceRows([][]string{{"type", "brand", "serial"}}, now)
+	assert.ErrorIs(t, err, ErrInvalidImport)
+	assert.Contains(t, err.Error(), "missing column(s) model")
+
+	_, err = parseDeviceRows([][]string{{"type", "brand", "model", "serial"}}, now)
+	assert.ErrorIs(t, err, ErrInvalidImport, "a file without devices is rejected")
+}
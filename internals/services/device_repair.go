@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRepairTicketNotFound = errors.New("repair ticket not found")
+	ErrRepairTicketClosed   = errors.New("the repair ticket is already closed")
+	ErrRepairInProgress     = errors.New("the device has an open repair ticket")
+)
+
+// RepairRequest sends a damaged device to a vendor or technician
+type RepairRequest struct {
+	Fault    string
+	Vendor   string
+	SentDate time.Time
+	Note     string
+}
+
+// RepairClosure records how a repair ended. ReplacementSerialNumber is the serial number of the
+// unit the vendor sent back in its place, and only goes with the REPLACED outcome.
+type RepairClosure struct {
+	Outcome                 models.RepairOutcome
+	ReturnedDate            time.Time
+	Cost                    money.Amount
+	ReplacementSerialNumber string
+	Note                    string
+}
+
+// OpenRepair opens a repair ticket for a damaged device and marks it under repair. A device
+// already under repair without a ticket gets one.
+func (s *DeviceService) OpenRepair(ctx context.Context, deviceID string, request RepairRequest) (*models.RepairTicket, error) {
+	request.Fault = strings.TrimSpace(request.Fault)
+	request.Vendor = strings.TrimSpace(request.Vendor)
+	if err := validateRepairRequest(request, time.Now()).err(); err != nil {
+		return nil, err
+	}
+
+	var ticket *models.RepairTicket
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		device, err := s.inventory.lock(ctx, deviceID)
+		if err != nil {
+			return err
+		}
+		open, err := s.deviceRepo.GetOpenRepairTicket(ctx, deviceID)
+		if err != nil {
+			return err
+		}
+		if open != nil {
+			return fmt.Errorf("%w: it was sent to %s on %s", ErrRepairInProgress, open.Vendor, open.SentDate.Format(time.DateOnly))
+		}
+		if device.Status != models.UnderRepair && !device.Status.CanTransitionTo(models.UnderRepair) {
+			return fmt.Errorf("%w: only damaged devices are sent for repair, this one is %s", ErrInvalidDeviceTransition, device.Status)
+		}
+
+		ticket = &models.RepairTicket{
+			ID:           uuid.New().String(),
+			DeviceID:     deviceID,
+			Status:       models.RepairOpen,
+			Fault:        request.Fault,
+			Vendor:       request.Vendor,
+			SentDate:     request.SentDate,
+			Currency:     money.DefaultCurrency,
+			SerialNumber: device.SerialNumber,
+			Note:         request.Note,
+			OpenedBy:     auth.ActorID(ctx),
+		}
+		if err := s.deviceRepo.CreateRepairTicket(ctx, ticket); err != nil {
+			return err
+		}
+		return s.inventory.setStatus(ctx, deviceID, models.UnderRepair, fmt.Sprintf("Sent to %s: %s", request.Vendor, request.Fault))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Repair ticket opened", zap.String("ticketID", ticket.ID), zap.String("deviceID", deviceID), zap.String("vendor", ticket.Vendor))
+	return ticket, nil
+}
+
+// CloseRepair records the outcome of the device's repair ticket and posts its cost as an
+// operational expense. A repaired or replaced device goes back to stock, the latter under the
+// replacement's serial number; a scrapped one is written off.
+func (s *DeviceService) CloseRepair(ctx context.Context, deviceID, ticketID string, closure RepairClosure) (*models.RepairTicket, error) {
+	closure.ReplacementSerialNumber = strings.TrimSpace(closure.ReplacementSerialNumber)
+
+	var ticket *models.RepairTicket
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		ticket, err = s.deviceRepo.GetRepairTicketForUpdate(ctx, ticketID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && ticket.DeviceID != deviceID) {
+			return ErrRepairTicketNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if ticket.Status == models.RepairClosed {
+			return ErrRepairTicketClosed
+		}
+
+		// A return date without a time lands at midnight, which is before the time a device sent
+		// the same day left; it came back no earlier than it was sent
+		if closure.ReturnedDate.Before(ticket.SentDate) && startOfDay(closure.ReturnedDate).Equal(startOfDay(ticket.SentDate)) {
+			closure.ReturnedDate = ticket.SentDate
+		}
+
+		now := time.Now()
+		validation := validateRepairClosure(ticket, closure, now)
+		device, err := s.inventory.lock(ctx, deviceID)
+		if err != nil {
+			return err
+		}
+		if closure.Outcome == models.RepairReplaced && closure.ReplacementSerialNumber != "" {
+			taken, err := s.deviceRepo.GetExistingSerialNumbers(ctx, []string{closure.ReplacementSerialNumber})
+			if err != nil {
+				return err
+			}
+			if len(taken) > 0 {
+				validation.add("replacementSerialNumber", "a device with this serial number already exists")
+			}
+		}
+		if err := validation.err(); err != nil {
+			return err
+		}
+
+		if closure.Cost > 0 {
+			expense := &models.Expense{
+				ID:          uuid.New().String(),
+				Amount:      closure.Cost,
+				Currency:    ticket.Currency,
+				Type:        models.OperationalExpense,
+				Description: fmt.Sprintf("Repair of %s %s %s (serial %s) by %s", device.Type, device.Brand, device.Model, ticket.SerialNumber, ticket.Vendor),
+				PaidAt:      now,
+			}
+			if err := s.expenseRepo.CreateExpense(ctx, expense); err != nil {
+				return err
+			}
+			ticket.ExpenseID = &expense.ID
+		}
+
+		note := fmt.Sprintf("Returned by %s: %s", ticket.Vendor, strings.ToLower(string(closure.Outcome)))
+		switch closure.Outcome {
+		case models.RepairRepaired:
+			err = s.inventory.setStatus(ctx, deviceID, models.InStock, note)
+		case models.RepairReplaced:
+			device.SerialNumber = closure.ReplacementSerialNumber
+			if err = s.deviceRepo.UpdateDevice(ctx, device); err == nil {
+				err = s.inventory.setStatus(ctx, deviceID, models.InStock, fmt.Sprintf("%s, serial %s replaced by %s", note, ticket.SerialNumber, device.SerialNumber))
+			}
+		case models.RepairScrapped:
+			if err = s.inventory.setStatus(ctx, deviceID, models.Damaged, note); err == nil {
+				_, err = s.WriteOffDevice(ctx, deviceID, note)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		returned := closure.ReturnedDate
+		ticket.Status = models.RepairClosed
+		ticket.Outcome = closure.Outcome
+		ticket.ReturnedDate = &returned
+		ticket.Cost = closure.Cost
+		if closure.Outcome == models.RepairReplaced {
+			ticket.ReplacementSerialNumber = &closure.ReplacementSerialNumber
+		}
+		if closure.Note != "" {
+			ticket.Note = closure.Note
+		}
+		ticket.ClosedBy = auth.ActorID(ctx)
+		ticket.ClosedAt = &now
+		return s.deviceRepo.UpdateRepairTicket(ctx, ticket)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Repair ticket closed",
+		zap.String("ticketID", ticket.ID),
+		zap.String("deviceID", deviceID),
+		zap.String("outcome", string(ticket.Outcome)),
+		zap.String("cost", ticket.Cost.String()),
+	)
+	return ticket, nil
+}
+
+// GetRepairs returns the device's repair tickets, latest first, including those of a deleted device
+func (s *DeviceService) GetRepairs(ctx context.Context, deviceID string) ([]models.RepairTicket, error) {
+	tickets, err := s.deviceRepo.GetDeviceRepairTickets(ctx, deviceID)
+	if err != nil || len(tickets) > 0 {
+		return tickets, err
+	}
+
+	if _, err := s.deviceRepo.GetDeviceByID(ctx, deviceID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// ListRepairs returns the repair tickets with the given status, or all of them, longest out first
+func (s *DeviceService) ListRepairs(ctx context.Context, status models.RepairTicketStatus) ([]models.RepairTicket, error) {
+	if status != "" && status != models.RepairOpen && status != models.RepairClosed {
+		validation := &ValidationError{}
+		validation.add("status", "must be OPEN or CLOSED")
+		return nil, validation
+	}
+	return s.deviceRepo.GetRepairTickets(ctx, status)
+}
+
+func validateRepairRequest(request RepairRequest, now time.Time) *ValidationError {
+	validation := &ValidationError{}
+	if request.Fault == "" {
+		validation.add("fault", "is required")
+	}
+	if request.Vendor == "" {
+		validation.add("vendor", "is required")
+	} else if len(request.Vendor) > 100 {
+		validation.add("vendor", "must be at most 100 characters")
+	}
+	if request.SentDate.IsZero() {
+		validation.add("sentDate", "is required")
+	} else if request.SentDate.After(now) {
+		validation.add("sentDate", "cannot be in the future")
+	}
+	return validation
+}
+
+func validateRepairClosure(ticket *models.RepairTicket, closure RepairClosure, now time.Time) *ValidationError {
+	validation := &ValidationError{}
+	if !closure.Outcome.IsValid() {
+		validation.add("outcome", "must be REPAIRED, REPLACED or SCRAPPED")
+	}
+	switch {
+	case closure.ReturnedDate.IsZero():
+		validation.add("returnedDate", "is required")
+	case closure.ReturnedDate.Before(ticket.SentDate):
+		validation.add("returnedDate", "cannot be before the sent date")
+	case closure.ReturnedDate.After(now):
+		validation.add("returnedDate", "cannot be in the future")
+	}
+	if closure.Cost < 0 {
+		validation.add("cost", "cannot be negative")
+	}
+
+	switch {
+	case closure.Outcome == models.RepairReplaced && closure.ReplacementSerialNumber == "":
+		validation.add("replacementSerialNumber", "is required when the device was replaced")
+	case closure.Outcome != models.RepairReplaced && closure.ReplacementSerialNumber != "":
+		validation.add("replacementSerialNumber", "only goes with the REPLACED outcome")
+	case len(closure.ReplacementSerialNumber) > 50:
+		validation.add("replacementSerialNumber", "must be at most 50 characters")
+	case closure.ReplacementSerialNumber != "" && strings.EqualFold(closure.ReplacementSerialNumber, ticket.SerialNumber):
+		validation.add("replacementSerialNumber", "must differ from the serial number sent for repair")
+	}
+	return validation
+}
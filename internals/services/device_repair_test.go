@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestValidateRepairRequest(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.Local)
+
+	valid := RepairRequest{Fault: "No PON signal", Vendor: "ZTE RMA", SentDate: now.AddDate(0, 0, -1)}
+	assert.Empty(t, validateRepairRequest(valid, now).Errors)
+
+	assert.Equal(t, []FieldError{
+		{Field: "fault", Message: "is required"},
+		{Field: "vendor", Message: "is required"},
+		{Field: "sentDate", Message: "cannot be in the future"},
+	}, validateRepairRequest(RepairRequest{SentDate: now.AddDate(0, 0, 1)}, now).Errors)
+}
+
+func TestValidateRepairClosure(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.Local)
+	ticket := &models.RepairTicket{SerialNumber: "ZTE-001", SentDate: time.Date(2026, time.October, 1, 15, 0, 0, 0, time.Local)}
+
+	repaired := RepairClosure{Outcome: models.RepairRepaired, ReturnedDate: ticket.SentDate, Cost: money.FromMajor(350)}
+	assert.Empty(t, validateRepairClosure(ticket, repaired, now).Errors, "a device may come back the moment it was sent")
+
+	repaired.ReturnedDate = ticket.SentDate.Add(-time.Hour)
+	assert.Equal(t, []FieldError{
+		{Field: "returnedDate", Message: "cannot be before the sent date"},
+	}, validateRepairClosure(ticket, repaired, now).Errors, "the check matches the database constraint to the second")
+
+	replaced := RepairClosure{Outcome: models.RepairReplaced, ReturnedDate: now, ReplacementSerialNumber: "ZTE-002"}
+	assert.Empty(t, validateRepairClosure(ticket, replaced, now).Errors)
+
+	replaced.ReplacementSerialNumber = "zte-001"
+	assert.Equal(t, []FieldError{
+		{Field: "replacementSerialNumber", Message: "must differ from the serial number sent for repair"},
+	}, validateRepairClosure(ticket, replaced, now).Errors)
+
+	assert.Equal(t, []FieldError{
+		{Field: "outcome", Message: "must be REPAIRED, REPLACED or SCRAPPED"},
+		{Field: "returnedDate", Message: "cannot be before the sent date"},
+		{Field: "cost", Message: "cannot be negative"},
+	}, validateRepairClosure(ticket, RepairClosure{Outcome: "FIXED", ReturnedDate: ticket.SentDate.AddDate(0, 0, -1), Cost: -1}, now).Errors)
+
+	assert.Equal(t, []FieldError{
+		{Field: "returnedDate", Message: "is required"},
+		{Field: "replacementSerialNumber", Message: "only goes with the REPLACED outcome"},
+	}, validateRepairClosure(ticket, RepairClosure{Outcome: models.RepairScrapped, ReplacementSerialNumber: "ZTE-002"}, now).Errors)
+
+	assert.Equal(t, []FieldError{
+		{Field: "replacementSerialNumber", Message: "is required when the device was replaced"},
+	}, validateRepairClosure(ticket, RepairClosure{Outcome: models.RepairReplaced, ReturnedDate: now}, now).Errors)
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timam/uttarawave-backend/internals/models"
+)
+
+func TestDeviceEventType(t *testing.T) {
+	testCases := []struct {
+		from, to models.DeviceStatus
+		expected models.DeviceEventType
+	}{
+		{models.Assigned, models.PendingCollection, models.DeviceEventCollection},
+		{models.PendingCollection, models.InStock, models.DeviceEventCollection},
+		{models.PendingCollection, models.Damaged, models.DeviceEventCollection},
+		{models.Assigned, models.InStock, models.DeviceEventUnassigned},
+		{models.Damaged, models.UnderRepair, models.DeviceEventRepair},
+		{models.UnderRepair, models.InStock, models.DeviceEventRepair},
+		{models.InStock, models.Damaged, models.DeviceEventStatusChanged},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, deviceEventType(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+}
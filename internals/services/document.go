@@ -0,0 +1,383 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoReceipt        = errors.New("only incoming payments have receipts")
+	ErrBuildingNotFound = errors.New("building not found")
+)
+
+// DocumentCustomer is the customer block printed on invoices and receipts
+type DocumentCustomer struct {
+	Name     string `json:"name"`
+	Mobile   string `json:"mobile"`
+	Address  string `json:"address"`
+	Building string `json:"building,omitempty"`
+}
+
+type ReceiptInvoice struct {
+	Number        string       `json:"number"`
+	BillingPeriod string       `json:"billingPeriod,omitempty"`
+	Applied       money.Amount `json:"applied"`
+	Balance       money.Amount `json:"balance"`
+}
+
+type DocumentSubscription struct {
+	Type        models.PackageType `json:"type"`
+	PackageName string             `json:"packageName"`
+	PaidUntil   time.Time          `json:"paidUntil"`
+}
+
+// InvoiceDocumentLine is one printed line of an invoice
+type InvoiceDocumentLine struct {
+	Description string                 `json:"description"`
+	Type        models.InvoiceLineType `json:"type"`
+	Quantity    int                    `json:"quantity"`
+	UnitPrice   money.Amount           `json:"unitPrice"`
+	Amount      money.Amount           `json:"amount"`
+}
+
+// InvoiceDocument is what a printed invoice shows
+type InvoiceDocument struct {
+	ID            string                 `json:"id"`
+	Number        string                 `json:"number"`
+	Status        models.InvoiceStatus   `json:"status"`
+	BillingPeriod string                 `json:"billingPeriod,omitempty"`
+	IssuedAt      time.Time              `json:"issuedAt"`
+	DueDate       time.Time              `json:"dueDate"`
+	Currency      money.Currency         `json:"currency"`
+	Customer      DocumentCustomer       `json:"customer"`
+	Lines         []InvoiceDocumentLine  `json:"lines"`
+	Subscriptions []DocumentSubscription `json:"subscriptions"`
+	Total         money.Amount           `json:"total"`
+	Paid          money.Amount           `json:"paid"`
+	Balance       money.Amount           `json:"balance"`
+}
+
+// BuildingInvoices are the invoices of one building for a billing period, printed together
+type BuildingInvoices struct {
+	Building string            `json:"building"`
+	Period   string            `json:"period"`
+	Invoices []InvoiceDocument `json:"invoices"`
+}
+
+// Receipt is what a printed payment receipt shows
+type Receipt struct {
+	Number        string                 `json:"number"`
+	PaymentID     string                 `json:"paymentId"`
+	PaidAt        time.Time              `json:"paidAt"`
+	Amount        money.Amount           `json:"amount"`
+	Currency      money.Currency         `json:"currency"`
+	Method        string                 `json:"method"`
+	Description   string                 `json:"description,omitempty"`
+	Reversed      bool                   `json:"reversed"`
+	Customer      DocumentCustomer       `json:"customer"`
+	Invoices      []ReceiptInvoice       `json:"invoices"`
+	Subscriptions []DocumentSubscription `json:"subscriptions"`
+	Credit        money.Amount           `json:"credit"`
+}
+
+type DocumentService struct {
+	paymentRepo      repositories.PaymentRepository
+	invoiceRepo      repositories.InvoiceRepository
+	subscriptionRepo repositories.SubscriptionRepository
+	packageRepo      repositories.PackageRepository
+	customerRepo     repositories.CustomerRepository
+	creditRepo       repositories.CustomerCreditRepository
+	buildingRepo     repositories.BuildingRepository
+}
+
+func NewDocumentService(
+	pr repositories.PaymentRepository,
+	ir repositories.InvoiceRepository,
+	sr repositories.SubscriptionRepository,
+	pkr repositories.PackageRepository,
+	cr repositories.CustomerRepository,
+	ccr repositories.CustomerCreditRepository,
+	br repositories.BuildingRepository) *DocumentService {
+	return &DocumentService{
+		paymentRepo:      pr,
+		invoiceRepo:      ir,
+		subscriptionRepo: sr,
+		packageRepo:      pkr,
+		customerRepo:     cr,
+		creditRepo:       ccr,
+		buildingRepo:     br,
+	}
+}
+
+// GetReceipt builds the receipt of a payment, looked up by its ID or its receipt number
+func (s *DocumentService) GetReceipt(ctx context.Context, reference string) (*Receipt, error) {
+	payment, err := s.findPayment(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+	if payment.ReceiptNumber == nil {
+		return nil, ErrNoReceipt
+	}
+
+	receipt := &Receipt{
+		Number:        *payment.ReceiptNumber,
+		PaymentID:     payment.ID,
+		PaidAt:        payment.PaidAt,
+		Amount:        payment.Amount,
+		Currency:      payment.Currency,
+		Method:        "Manual",
+		Description:   payment.Description,
+		Reversed:      payment.ReversedAt != nil,
+		Invoices:      []ReceiptInvoice{},
+		Subscriptions: []DocumentSubscription{},
+	}
+	if payment.Gateway != nil {
+		receipt.Method = *payment.Gateway
+	}
+
+	customerID, err := s.addInvoices(ctx, receipt, payment)
+	if err != nil {
+		return nil, err
+	}
+	if payment.CustomerID != nil {
+		customerID = *payment.CustomerID
+	}
+	if customerID != "" {
+		if receipt.Customer, err = s.documentCustomer(ctx, customerID); err != nil {
+			return nil, err
+		}
+	}
+
+	if receipt.Credit, err = s.creditRepo.GetCreditFromPayment(ctx, payment.ID); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// GetInvoiceDocument builds the printable form of an invoice
+func (s *DocumentService) GetInvoiceDocument(ctx context.Context, id string) (*InvoiceDocument, error) {
+	invoice, err := s.invoiceRepo.GetInvoiceByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if invoice == nil {
+		return nil, ErrInvoiceNotFound
+	}
+
+	customer, err := s.documentCustomer(ctx, invoice.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	document, err := s.invoiceDocument(ctx, invoice, customer)
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// GetBuildingInvoices builds the invoices of every customer in the building for a billing
+// period, so collectors can print them as one file for their round
+func (s *DocumentService) GetBuildingInvoices(ctx context.Context, buildingID, period string, includePaid bool) (*BuildingInvoices, error) {
+	building, err := s.buildingRepo.GetBuildingByID(ctx, buildingID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBuildingNotFound
+		}
+		return nil, err
+	}
+
+	invoices, err := s.invoiceRepo.GetBuildingInvoices(ctx, buildingID, period, includePaid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BuildingInvoices{Building: building.Name, Period: period, Invoices: []InvoiceDocument{}}
+	customers := make(map[string]DocumentCustomer)
+	for i := range invoices {
+		invoice := &invoices[i]
+		customer, ok := customers[invoice.CustomerID]
+		if !ok {
+			if customer, err = s.documentCustomer(ctx, invoice.CustomerID); err != nil {
+				return nil, err
+			}
+			customers[invoice.CustomerID] = customer
+		}
+
+		document, err := s.invoiceDocument(ctx, invoice, customer)
+		if err != nil {
+			return nil, err
+		}
+		result.Invoices = append(result.Invoices, document)
+	}
+	return result, nil
+}
+
+func (s *DocumentService) invoiceDocument(ctx context.Context, invoice *models.Invoice, customer DocumentCustomer) (InvoiceDocument, error) {
+	document := InvoiceDocument{
+		ID:            invoice.ID,
+		Status:        invoice.Status,
+		IssuedAt:      invoice.CreatedAt,
+		DueDate:       invoice.DueDate,
+		Currency:      invoice.Currency,
+		Customer:      customer,
+		Lines:         make([]InvoiceDocumentLine, 0, len(invoice.Lines)),
+		Subscriptions: []DocumentSubscription{},
+		Total:         invoice.Amount,
+		Paid:          invoice.PaidAmount,
+	}
+	if invoice.Number != nil {
+		document.Number = *invoice.Number
+	}
+	if invoice.BillingPeriod != nil {
+		document.BillingPeriod = *invoice.BillingPeriod
+	}
+	if invoice.Status.IsOpen() {
+		document.Balance = invoice.Balance()
+	}
+
+	for _, line := range invoice.Lines {
+		document.Lines = append(document.Lines, InvoiceDocumentLine{
+			Description: line.Description,
+			Type:        line.Type,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			Amount:      line.Amount,
+		})
+	}
+	for _, charge := range subscriptionCharges(invoice) {
+		subscription, err := s.documentSubscription(ctx, charge.SubscriptionID)
+		if err != nil {
+			return InvoiceDocument{}, err
+		}
+		document.Subscriptions = append(document.Subscriptions, subscription)
+	}
+	return document, nil
+}
+
+// documentCustomer loads the customer block; a customer that no longer exists prints blank
+func (s *DocumentService) documentCustomer(ctx context.Context, customerID string) (DocumentCustomer, error) {
+	customer, err := s.customerRepo.GetCustomer(customerID)
+	if err != nil || customer == nil {
+		return DocumentCustomer{}, err
+	}
+
+	result := DocumentCustomer{
+		Name:    customer.Name,
+		Mobile:  customer.Mobile,
+		Address: FormatAddress(customer.Address),
+	}
+	if customer.Address.BuildingID != nil {
+		building, err := s.buildingRepo.GetBuildingByID(ctx, *customer.Address.BuildingID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return DocumentCustomer{}, err
+		}
+		if building != nil {
+			result.Building = building.Name
+		}
+	}
+	return result, nil
+}
+
+func (s *DocumentService) findPayment(ctx context.Context, reference string) (*models.Payment, error) {
+	if number := strings.ToUpper(reference); strings.HasPrefix(number, "RCT-") {
+		payment, err := s.paymentRepo.GetPaymentByReceiptNumber(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+		if payment == nil {
+			return nil, ErrPaymentNotFound
+		}
+		return payment, nil
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByID(ctx, reference)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, err
+}
+
+// addInvoices lists the invoices the payment settled and the subscriptions they bill, and
+// returns the customer of those invoices
+func (s *DocumentService) addInvoices(ctx context.Context, receipt *Receipt, payment *models.Payment) (string, error) {
+	allocations, err := s.paymentRepo.GetAllocationsByPaymentID(ctx, payment.ID)
+	if err != nil {
+		return "", err
+	}
+
+	var customerID string
+	seen := make(map[string]bool)
+	for _, a := range allocations {
+		invoice, err := s.invoiceRepo.GetInvoiceByID(ctx, a.InvoiceID)
+		if err != nil {
+			return "", err
+		}
+		if invoice == nil {
+			continue
+		}
+		customerID = invoice.CustomerID
+
+		line := ReceiptInvoice{Applied: a.Amount, Balance: invoice.Balance()}
+		if invoice.Number != nil {
+			line.Number = *invoice.Number
+		}
+		if invoice.BillingPeriod != nil {
+			line.BillingPeriod = *invoice.BillingPeriod
+		}
+		receipt.Invoices = append(receipt.Invoices, line)
+
+		for _, charge := range subscriptionCharges(invoice) {
+			if seen[charge.SubscriptionID] {
+				continue
+			}
+			seen[charge.SubscriptionID] = true
+			subscription, err := s.documentSubscription(ctx, charge.SubscriptionID)
+			if err != nil {
+				return "", err
+			}
+			receipt.Subscriptions = append(receipt.Subscriptions, subscription)
+		}
+	}
+	return customerID, nil
+}
+
+func (s *DocumentService) documentSubscription(ctx context.Context, id string) (DocumentSubscription, error) {
+	subscription, err := s.subscriptionRepo.GetSubscription(ctx, id)
+	if err != nil {
+		return DocumentSubscription{}, err
+	}
+	pkg, err := s.packageRepo.GetPackageByID(ctx, subscription.PackageID)
+	if err != nil {
+		return DocumentSubscription{}, err
+	}
+	return DocumentSubscription{
+		Type:        pkg.Type,
+		PackageName: pkg.Name,
+		PaidUntil:   subscription.PaidUntil,
+	}, nil
+}
+
+// FormatAddress joins the filled-in parts of an address on one line
+func FormatAddress(address models.Address) string {
+	var parts []string
+	for _, part := range []struct{ label, value string }{
+		{"Flat ", address.Flat},
+		{"House ", address.House},
+		{"Road ", address.Road},
+		{"Block ", address.Block},
+		{"", address.Area},
+		{"", address.City},
+	} {
+		if value := strings.TrimSpace(part.value); value != "" {
+			parts = append(parts, part.label+value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
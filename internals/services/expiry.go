@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const defaultExpiryGraceDays = 7
+
+// ExpiryGracePeriod is how long a subscription may stay unpaid past its renewal date before it expires
+func ExpiryGracePeriod() time.Duration {
+	days := defaultExpiryGraceDays
+	if viper.IsSet("subscriptions.expiry.grace_days") {
+		days = viper.GetInt("subscriptions.expiry.grace_days")
+	}
+	if days < 0 {
+		days = 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+type ExpiryRunResult struct {
+	Expired       int `json:"expired"`
+	DevicesMarked int `json:"devicesMarked"`
+	Failed        int `json:"failed"`
+}
+
+type ExpiryService struct {
+	subscriptionRepo repositories.SubscriptionRepository
+	deviceRepo       repositories.DeviceRepository
+	inventory        inventory
+	gracePeriod      time.Duration
+}
+
+func NewExpiryService(sr repositories.SubscriptionRepository, dr repositories.DeviceRepository, gracePeriod time.Duration) *ExpiryService {
+	return &ExpiryService{
+		subscriptionRepo: sr,
+		deviceRepo:       dr,
+		inventory:        inventory{repo: dr},
+		gracePeriod:      gracePeriod,
+	}
+}
+
+// ProcessExpiredSubscriptions expires subscriptions that are past their renewal date plus the
+// grace period and flags their devices for collection, together so a subscription renewed in the
+// meantime keeps its devices.
+func (s *ExpiryService) ProcessExpiredSubscriptions(ctx context.Context, now time.Time) (*ExpiryRunResult, error) {
+	result := &ExpiryRunResult{}
+
+	cutoff := now.Add(-s.gracePeriod)
+	expiredSubscriptions, err := s.subscriptionRepo.GetExpiredSubscriptions(ctx, cutoff)
+	if err != nil {
+		logger.Error("Failed to get expired subscriptions", zap.Error(err))
+		return nil, err
+	}
+
+	for i := range expiredSubscriptions {
+		subscription := &expiredSubscriptions[i]
+
+		expired := false
+		var deviceIDs []string
+		err := db.Transaction(ctx, func(ctx context.Context) error {
+			locked, err := s.subscriptionRepo.GetSubscriptionForUpdate(ctx, subscription.ID)
+			if err != nil {
+				return err
+			}
+			// A payment may have renewed it since it was listed
+			if locked.Status != models.SubscriptionActive || locked.RenewalDate.After(cutoff) {
+				return nil
+			}
+
+			if deviceIDs, err = s.subscriptionDeviceIDs(ctx, locked); err != nil {
+				return fmt.Errorf("failed to get subscription devices: %w", err)
+			}
+			for _, deviceID := range deviceIDs {
+				if err := s.inventory.markForCollection(ctx, deviceID, "Subscription "+locked.ID+" expired"); err != nil {
+					return fmt.Errorf("failed to mark device %s for collection: %w", deviceID, err)
+				}
+			}
+
+			if _, err = transitionSubscription(ctx, s.subscriptionRepo, locked, models.SubscriptionExpired, "Unpaid past the renewal date"); err != nil {
+				return err
+			}
+			expired = true
+			return nil
+		})
+		if err != nil {
+			logger.Error("Failed to expire subscription", zap.Error(err), zap.String("subscriptionID", subscription.ID))
+			result.Failed++
+			continue
+		}
+		if !expired {
+			continue
+		}
+
+		logger.Info("Subscription expired", zap.String("subscriptionID", subscription.ID), zap.Int("devices", len(deviceIDs)))
+		result.DevicesMarked += len(deviceIDs)
+		result.Expired++
+	}
+
+	if result.Failed > 0 {
+		return result, fmt.Errorf("failed to process %d expired subscription(s)", result.Failed)
+	}
+	return result, nil
+}
+
+// subscriptionDeviceIDs collects the device referenced by the subscription and any device assigned to
+// it. A referenced device that is no longer assigned to the subscription is left where it is.
+func (s *ExpiryService) subscriptionDeviceIDs(ctx context.Context, subscription *models.Subscription) ([]string, error) {
+	var deviceIDs []string
+	if subscription.DeviceID != "" {
+		device, err := s.deviceRepo.GetDeviceByID(ctx, subscription.DeviceID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if device != nil && device.SubscriptionID != nil && *device.SubscriptionID == subscription.ID {
+			deviceIDs = append(deviceIDs, device.ID)
+		}
+	}
+
+	device, err := s.deviceRepo.GetDeviceByAssignment(ctx, "Subscription", subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+	if device != nil && device.ID != subscription.DeviceID {
+		deviceIDs = append(deviceIDs, device.ID)
+	}
+
+	return deviceIDs, nil
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/gateway"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownGateway             = errors.New("unknown payment gateway")
+	ErrSimulationNotSupported     = errors.New("payment gateway cannot simulate payments")
+	ErrGatewayTransactionNotFound = errors.New("gateway transaction not found")
+	ErrInvalidCheckout            = errors.New("invalid checkout")
+)
+
+// CheckoutRequest opens a gateway checkout for an invoice, or for a customer's account when
+// InvoiceID is nil. The amount defaults to the invoice balance.
+type CheckoutRequest struct {
+	Gateway    string
+	InvoiceID  *string
+	CustomerID *string
+	Amount     money.Amount
+	Currency   money.Currency
+}
+
+type GatewayService struct {
+	gateways        map[string]gateway.Gateway
+	transactionRepo repositories.GatewayTransactionRepository
+	invoiceRepo     repositories.InvoiceRepository
+	customerRepo    repositories.CustomerRepository
+	paymentService  *PaymentService
+}
+
+func NewGatewayService(
+	gateways map[string]gateway.Gateway,
+	tr repositories.GatewayTransactionRepository,
+	ir repositories.InvoiceRepository,
+	cr repositories.CustomerRepository,
+	ps *PaymentService) *GatewayService {
+	return &GatewayService{
+		gateways:        gateways,
+		transactionRepo: tr,
+		invoiceRepo:     ir,
+		customerRepo:    cr,
+		paymentService:  ps,
+	}
+}
+
+func (s *GatewayService) gateway(name string) (gateway.Gateway, error) {
+	gw, ok := s.gateways[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGateway, name)
+	}
+	return gw, nil
+}
+
+func (s *GatewayService) CreateCheckout(ctx context.Context, req CheckoutRequest) (*models.GatewayTransaction, error) {
+	gw, err := s.gateway(req.Gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := &models.GatewayTransaction{
+		ID:        uuid.New().String(),
+		Gateway:   req.Gateway,
+		InvoiceID: req.InvoiceID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Status:    models.GatewayPending,
+	}
+	if req.CustomerID != nil {
+		transaction.CustomerID = *req.CustomerID
+	}
+	if err := s.checkoutInvoice(ctx, transaction); err != nil {
+		return nil, err
+	}
+
+	if transaction.CustomerID == "" {
+		return nil, fmt.Errorf("%w: customerId or invoiceId is required", ErrInvalidCheckout)
+	}
+	customer, err := s.customerRepo.GetCustomer(transaction.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if customer == nil {
+		return nil, fmt.Errorf("%w: customer not found", ErrInvalidCheckout)
+	}
+	if transaction.Currency == "" {
+		transaction.Currency = money.DefaultCurrency
+	}
+	if transaction.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidCheckout)
+	}
+
+	checkout, err := gw.CreateCheckout(ctx, gateway.CheckoutRequest{
+		Reference:      transaction.ID,
+		Amount:         transaction.Amount,
+		Currency:       transaction.Currency,
+		CustomerMobile: customer.Mobile,
+		CallbackURL:    webhookURL(req.Gateway),
+	})
+	if err != nil {
+		logger.Error("Failed to create gateway checkout", zap.Error(err), zap.String("gateway", req.Gateway))
+		return nil, err
+	}
+	transaction.TransactionID = checkout.TransactionID
+	transaction.RedirectURL = checkout.RedirectURL
+
+	if err := s.transactionRepo.CreateGatewayTransaction(ctx, transaction); err != nil {
+		logger.Error("Failed to save gateway transaction", zap.Error(err))
+		return nil, err
+	}
+
+	logger.Info("Gateway checkout created",
+		zap.String("gateway", transaction.Gateway),
+		zap.String("transactionID", transaction.TransactionID),
+		zap.Stringer("amount", transaction.Amount),
+	)
+	return transaction, nil
+}
+
+// checkoutInvoice fills in the customer, currency and amount of a checkout from its invoice
+func (s *GatewayService) checkoutInvoice(ctx context.Context, transaction *models.GatewayTransaction) error {
+	if transaction.InvoiceID == nil {
+		return nil
+	}
+
+	invoice, err := s.invoiceRepo.GetInvoiceByID(ctx, *transaction.InvoiceID)
+	if err != nil {
+		return err
+	}
+	if invoice == nil {
+		return ErrInvoiceNotFound
+	}
+	if invoice.Status == models.InvoicePaid {
+		return ErrInvoiceAlreadyPaid
+	}
+	if !invoice.Status.IsOpen() {
+		return ErrInvoiceNotOpen
+	}
+	if transaction.CustomerID != "" && transaction.CustomerID != invoice.CustomerID {
+		return fmt.Errorf("%w: the invoice belongs to a different customer", ErrInvalidCheckout)
+	}
+	if transaction.Currency != "" && transaction.Currency != invoice.Currency {
+		return ErrCurrencyMismatch
+	}
+
+	transaction.CustomerID = invoice.CustomerID
+	transaction.Currency = invoice.Currency
+	if transaction.Amount == 0 {
+		transaction.Amount = invoice.Balance()
+	}
+	return nil
+}
+
+func webhookURL(name string) string {
+	base := strings.TrimSuffix(viper.GetString("payments.callback_base_url"), "/")
+	return base + "/api/v1/payments/gateways/" + name + "/webhook"
+}
+
+// HandleWebhook verifies a gateway callback and applies the transaction status it reports
+func (s *GatewayService) HandleWebhook(ctx context.Context, name string, header http.Header, body []byte) (*models.GatewayTransaction, error) {
+	gw, err := s.gateway(name)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := gw.VerifyWebhook(header, body)
+	if err != nil {
+		logger.Warn("Rejected gateway webhook", zap.Error(err), zap.String("gateway", name))
+		return nil, err
+	}
+	return s.apply(ctx, name, remote)
+}
+
+// RefreshStatus asks the gateway for the status of a pending checkout, for when its webhook
+// never arrived
+func (s *GatewayService) RefreshStatus(ctx context.Context, id string) (*models.GatewayTransaction, error) {
+	transaction, err := s.transactionRepo.GetGatewayTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if transaction == nil {
+		return nil, ErrGatewayTransactionNotFound
+	}
+	if transaction.Status != models.GatewayPending {
+		return transaction, nil
+	}
+
+	gw, err := s.gateway(transaction.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := gw.QueryStatus(ctx, transaction.TransactionID)
+	if err != nil {
+		logger.Error("Failed to query gateway transaction", zap.Error(err), zap.String("transactionID", transaction.TransactionID))
+		return nil, err
+	}
+	return s.apply(ctx, transaction.Gateway, remote)
+}
+
+// Simulate completes or fails a checkout on a gateway that supports it and delivers the
+// resulting webhook, so the whole flow can be exercised without the real provider
+func (s *GatewayService) Simulate(ctx context.Context, name, transactionID string, status gateway.Status) (*models.GatewayTransaction, error) {
+	gw, err := s.gateway(name)
+	if err != nil {
+		return nil, err
+	}
+	simulator, ok := gw.(gateway.Simulator)
+	if !ok {
+		return nil, ErrSimulationNotSupported
+	}
+
+	header, body, err := simulator.Simulate(transactionID, status)
+	if err != nil {
+		if errors.Is(err, gateway.ErrTransactionNotFound) {
+			return nil, ErrGatewayTransactionNotFound
+		}
+		return nil, err
+	}
+	return s.HandleWebhook(ctx, name, header, body)
+}
+
+// apply records the gateway's status on the checkout and posts the payment once it completes.
+// The checkout row is locked first, so a webhook delivered twice, or racing a status query,
+// posts the payment exactly once.
+func (s *GatewayService) apply(ctx context.Context, name string, remote *gateway.Transaction) (*models.GatewayTransaction, error) {
+	var transaction *models.GatewayTransaction
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		transaction, err = s.transactionRepo.GetGatewayTransactionForUpdate(ctx, name, remote.TransactionID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrGatewayTransactionNotFound
+			}
+			return err
+		}
+		if transaction.Status != models.GatewayPending {
+			return nil
+		}
+		if remote.Reference != "" && remote.Reference != transaction.ID {
+			return fmt.Errorf("%w: reference does not match the transaction", gateway.ErrInvalidPayload)
+		}
+
+		switch remote.Status {
+		case gateway.StatusCompleted:
+			payment, err := s.postPayment(ctx, transaction, remote)
+			if err != nil {
+				return err
+			}
+			transaction.PaymentID = &payment.ID
+			transaction.CompletedAt = &payment.PaidAt
+		case gateway.StatusFailed, gateway.StatusCancelled:
+			now := time.Now()
+			transaction.CompletedAt = &now
+		default:
+			return nil
+		}
+
+		transaction.Status = models.GatewayTransactionStatus(remote.Status)
+		return s.transactionRepo.UpdateGatewayTransaction(ctx, transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Gateway transaction updated",
+		zap.String("gateway", name),
+		zap.String("transactionID", transaction.TransactionID),
+		zap.String("status", string(transaction.Status)),
+	)
+	return transaction, nil
+}
+
+func (s *GatewayService) postPayment(ctx context.Context, transaction *models.GatewayTransaction, remote *gateway.Transaction) (*models.Payment, error) {
+	if remote.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be greater than zero", gateway.ErrInvalidPayload)
+	}
+	if remote.Currency != "" && remote.Currency != transaction.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	payment := &models.Payment{
+		ID:                   uuid.New().String(),
+		InvoiceID:            transaction.InvoiceID,
+		CustomerID:           &transaction.CustomerID,
+		Amount:               remote.Amount,
+		Currency:             transaction.Currency,
+		Type:                 models.PaymentIncoming,
+		Description:          fmt.Sprintf("%s payment %s", transaction.Gateway, transaction.TransactionID),
+		Gateway:              &transaction.Gateway,
+		GatewayTransactionID: &transaction.TransactionID,
+		PaidAt:               time.Now(),
+	}
+	if remote.PaidAt != nil {
+		payment.PaidAt = *remote.PaidAt
+	}
+
+	_, err := s.paymentService.PostPayment(ctx, payment)
+	if errors.Is(err, ErrInvoiceAlreadyPaid) || errors.Is(err, ErrInvoiceNotOpen) {
+		// The invoice was settled or closed while the customer was paying. The money has been
+		// collected regardless, so it goes to their other open invoices or to credit.
+		payment.InvoiceID = nil
+		_, err = s.paymentService.PostPayment(ctx, payment)
+	}
+	if err != nil {
+		logger.Error("Failed to post gateway payment", zap.Error(err), zap.String("transactionID", transaction.TransactionID))
+		return nil, err
+	}
+	return payment, nil
+}
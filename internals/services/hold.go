@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidHold = errors.New("invalid hold")
+	ErrHoldExists  = errors.New("the subscription already has a scheduled or running hold")
+	ErrNoOpenHold  = errors.New("the subscription has no scheduled or running hold")
+)
+
+// DefaultHoldFee reads subscriptions.hold.fee, the fee charged for a hold when the request does not name one
+func DefaultHoldFee() (money.Amount, error) {
+	value := viper.GetString("subscriptions.hold.fee")
+	if value == "" {
+		return 0, nil
+	}
+	fee, err := money.Parse(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid subscriptions.hold.fee: %w", err)
+	}
+	return fee, nil
+}
+
+// HoldRequest pauses a subscription from StartDate until EndDate, the day service resumes.
+// A nil Fee charges the configured default hold fee; zero charges nothing.
+type HoldRequest struct {
+	StartDate time.Time
+	EndDate   time.Time
+	Reason    string
+	Fee       *money.Amount
+}
+
+type HoldRunResult struct {
+	Started int `json:"started"`
+	Ended   int `json:"ended"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+type HoldService struct {
+	subscriptionRepo repositories.SubscriptionRepository
+	invoiceRepo      repositories.InvoiceRepository
+	settlement       settlement
+	ledger           ledger
+	defaultFee       money.Amount
+}
+
+func NewHoldService(
+	sr repositories.SubscriptionRepository,
+	ir repositories.InvoiceRepository,
+	cr repositories.CustomerCreditRepository,
+	lr repositories.LedgerRepository,
+	defaultFee money.Amount) *HoldService {
+	return &HoldService{
+		subscriptionRepo: sr,
+		invoiceRepo:      ir,
+		settlement:       settlement{invoiceRepo: ir, subscriptionRepo: sr, creditRepo: cr},
+		ledger:           ledger{repo: lr},
+		defaultFee:       defaultFee,
+	}
+}
+
+// PlaceHold schedules a hold on an active subscription, starting it straight away when its start
+// date is today
+func (s *HoldService) PlaceHold(ctx context.Context, subscriptionID string, request HoldRequest) (*models.SubscriptionHold, error) {
+	now := time.Now()
+	start, end := startOfDay(request.StartDate), startOfDay(request.EndDate)
+	if start.Before(startOfDay(now)) {
+		return nil, fmt.Errorf("%w: the start date is in the past", ErrInvalidHold)
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("%w: the end date must be after the start date", ErrInvalidHold)
+	}
+	fee := s.defaultFee
+	if request.Fee != nil {
+		fee = *request.Fee
+	}
+	if fee < 0 {
+		return nil, fmt.Errorf("%w: the hold fee cannot be negative", ErrInvalidHold)
+	}
+
+	var hold *models.SubscriptionHold
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		subscription, err := s.lockSubscription(ctx, subscriptionID)
+		if err != nil {
+			return err
+		}
+		if !subscription.Status.CanHold() {
+			return fmt.Errorf("%w: cannot put a %s subscription on hold", ErrInvalidTransition, subscription.Status)
+		}
+		open, err := s.subscriptionRepo.GetOpenSubscriptionHold(ctx, subscription.ID)
+		if err != nil {
+			return err
+		}
+		if open != nil {
+			return ErrHoldExists
+		}
+
+		hold = &models.SubscriptionHold{
+			ID:             uuid.New().String(),
+			SubscriptionID: subscription.ID,
+			StartDate:      start,
+			EndDate:        end,
+			Status:         models.HoldScheduled,
+			Fee:            fee,
+			Currency:       subscription.Currency,
+			Reason:         request.Reason,
+			CreatedBy:      auth.ActorID(ctx),
+		}
+		if err := s.subscriptionRepo.CreateSubscriptionHold(ctx, hold); err != nil {
+			return err
+		}
+		if start.After(now) {
+			return nil
+		}
+		return s.startHold(ctx, subscription, hold, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Subscription hold placed", zap.String("subscriptionID", subscriptionID), zap.String("holdID", hold.ID), zap.String("status", string(hold.Status)))
+	return hold, nil
+}
+
+// EndHold resumes a subscription on hold before its end date, giving back the days not used, or
+// cancels a hold that has not started yet
+func (s *HoldService) EndHold(ctx context.Context, subscriptionID, reason string) (*models.SubscriptionHold, error) {
+	var hold *models.SubscriptionHold
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		subscription, err := s.lockSubscription(ctx, subscriptionID)
+		if err != nil {
+			return err
+		}
+		if hold, err = s.subscriptionRepo.GetOpenSubscriptionHold(ctx, subscription.ID); err != nil {
+			return err
+		}
+		if hold == nil {
+			return ErrNoOpenHold
+		}
+
+		now := time.Now()
+		if hold.Status == models.HoldScheduled {
+			hold.Status = models.HoldCancelled
+			hold.EndedAt = &now
+			return s.subscriptionRepo.UpdateSubscriptionHold(ctx, hold)
+		}
+		if reason == "" {
+			reason = "Hold ended early"
+		}
+		return s.finishHold(ctx, subscription, hold, now, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Subscription hold ended", zap.String("subscriptionID", subscriptionID), zap.String("holdID", hold.ID), zap.String("status", string(hold.Status)))
+	return hold, nil
+}
+
+func (s *HoldService) GetHolds(ctx context.Context, subscriptionID string) ([]models.SubscriptionHold, error) {
+	if _, err := s.subscriptionRepo.GetSubscription(ctx, subscriptionID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, err
+	}
+	return s.subscriptionRepo.GetSubscriptionHolds(ctx, subscriptionID)
+}
+
+// ProcessHolds starts the scheduled holds whose start date has come and resumes the
+// subscriptions whose hold has run to its end date
+func (s *HoldService) ProcessHolds(ctx context.Context, now time.Time) (*HoldRunResult, error) {
+	result := &HoldRunResult{}
+
+	toStart, err := s.subscriptionRepo.GetHoldsToStart(ctx, now)
+	if err != nil {
+		logger.Error("Failed to get holds to start", zap.Error(err))
+		return nil, err
+	}
+	for i := range toStart {
+		started, err := s.runHold(ctx, toStart[i], models.HoldScheduled, func(ctx context.Context, subscription *models.Subscription, hold *models.SubscriptionHold) (bool, error) {
+			if !subscription.Status.CanHold() {
+				hold.Status = models.HoldCancelled
+				hold.EndedAt = &now
+				return false, s.subscriptionRepo.UpdateSubscriptionHold(ctx, hold)
+			}
+			return true, s.startHold(ctx, subscription, hold, now)
+		})
+		switch {
+		case err != nil:
+			logger.Error("Failed to start hold", zap.Error(err), zap.String("holdID", toStart[i].ID))
+			result.Failed++
+		case started:
+			result.Started++
+		default:
+			result.Skipped++
+		}
+	}
+
+	toEnd, err := s.subscriptionRepo.GetHoldsToEnd(ctx, now)
+	if err != nil {
+		logger.Error("Failed to get holds to end", zap.Error(err))
+		return nil, err
+	}
+	for i := range toEnd {
+		_, err := s.runHold(ctx, toEnd[i], models.HoldActive, func(ctx context.Context, subscription *models.Subscription, hold *models.SubscriptionHold) (bool, error) {
+			return true, s.finishHold(ctx, subscription, hold, now, "Hold ended")
+		})
+		if err != nil {
+			logger.Error("Failed to end hold", zap.Error(err), zap.String("holdID", toEnd[i].ID))
+			result.Failed++
+			continue
+		}
+		result.Ended++
+	}
+
+	logger.Info("Hold run completed",
+		zap.Int("started", result.Started),
+		zap.Int("ended", result.Ended),
+		zap.Int("skipped", result.Skipped),
+		zap.Int("failed", result.Failed),
+	)
+
+	if result.Failed > 0 {
+		return result, fmt.Errorf("failed to process %d hold(s)", result.Failed)
+	}
+	return result, nil
+}
+
+// runHold locks the hold's subscription and calls fn if the hold is still in the expected status,
+// since staff may have ended or cancelled it after it was listed
+func (s *HoldService) runHold(
+	ctx context.Context,
+	listed models.SubscriptionHold,
+	status models.HoldStatus,
+	fn func(context.Context, *models.Subscription, *models.SubscriptionHold) (bool, error)) (bool, error) {
+	done := false
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		subscription, err := s.lockSubscription(ctx, listed.SubscriptionID)
+		if err != nil {
+			return err
+		}
+		hold, err := s.subscriptionRepo.GetOpenSubscriptionHold(ctx, subscription.ID)
+		if err != nil || hold == nil || hold.ID != listed.ID || hold.Status != status {
+			return err
+		}
+		done, err = fn(ctx, subscription, hold)
+		return err
+	})
+	return done, err
+}
+
+// startHold puts the subscription on hold, pushes its paid period and renewal forward by the
+// days held and invoices the hold fee
+func (s *HoldService) startHold(ctx context.Context, subscription *models.Subscription, hold *models.SubscriptionHold, now time.Time) error {
+	days := daysBetween(hold.StartDate, hold.EndDate)
+	subscription.PaidUntil = subscription.PaidUntil.AddDate(0, 0, days)
+	subscription.RenewalDate = subscription.RenewalDate.AddDate(0, 0, days)
+	reason := "On hold until " + hold.EndDate.Format("2006-01-02")
+	if hold.Reason != "" {
+		reason += ": " + hold.Reason
+	}
+	if _, err := transitionSubscription(ctx, s.subscriptionRepo, subscription, models.SubscriptionOnHold, reason); err != nil {
+		return err
+	}
+
+	hold.Status = models.HoldActive
+	hold.DaysHeld = days
+	hold.StartedAt = &now
+	if hold.Fee > 0 {
+		invoice, err := s.feeInvoice(ctx, subscription, hold)
+		if err != nil {
+			return err
+		}
+		hold.InvoiceID = &invoice.ID
+	}
+	return s.subscriptionRepo.UpdateSubscriptionHold(ctx, hold)
+}
+
+// finishHold resumes the subscription. Ending before the end date takes the days not used back
+// off the paid period and renewal date.
+func (s *HoldService) finishHold(ctx context.Context, subscription *models.Subscription, hold *models.SubscriptionHold, now time.Time, reason string) error {
+	if unused := daysBetween(startOfDay(now), hold.EndDate); unused > 0 {
+		subscription.PaidUntil = subscription.PaidUntil.AddDate(0, 0, -unused)
+		subscription.RenewalDate = subscription.RenewalDate.AddDate(0, 0, -unused)
+		hold.DaysHeld -= unused
+	}
+
+	// A subscription cancelled while on hold stays cancelled
+	var err error
+	if subscription.Status == models.SubscriptionOnHold {
+		_, err = transitionSubscription(ctx, s.subscriptionRepo, subscription, models.SubscriptionActive, reason)
+	} else {
+		err = s.subscriptionRepo.UpdateSubscription(ctx, subscription)
+	}
+	if err != nil {
+		return err
+	}
+
+	hold.Status = models.HoldCompleted
+	hold.EndedAt = &now
+	return s.subscriptionRepo.UpdateSubscriptionHold(ctx, hold)
+}
+
+// feeInvoice bills the hold fee. The line is not tied to the subscription, so paying the fee
+// does not renew the subscription.
+func (s *HoldService) feeInvoice(ctx context.Context, subscription *models.Subscription, hold *models.SubscriptionHold) (*models.Invoice, error) {
+	invoice := &models.Invoice{
+		ID:         uuid.New().String(),
+		CustomerID: subscription.CustomerID,
+		Currency:   subscription.Currency,
+		Status:     models.InvoicePending,
+		DueDate:    hold.StartDate,
+	}
+	lines := []models.InvoiceLine{{
+		Type:        models.LineOther,
+		Description: fmt.Sprintf("Hold fee (%s to %s)", hold.StartDate.Format("2006-01-02"), hold.EndDate.Format("2006-01-02")),
+		UnitPrice:   hold.Fee,
+	}}
+	if err := SetInvoiceLines(invoice, lines); err != nil {
+		return nil, err
+	}
+	if err := s.invoiceRepo.CreateInvoice(ctx, invoice); err != nil {
+		return nil, err
+	}
+	if err := s.ledger.postInvoice(ctx, invoice); err != nil {
+		return nil, err
+	}
+	if _, err := s.settlement.applyCredit(ctx, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+func (s *HoldService) lockSubscription(ctx context.Context, id string) (*models.Subscription, error) {
+	subscription, err := s.subscriptionRepo.GetSubscriptionForUpdate(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSubscriptionNotFound
+	}
+	return subscription, err
+}
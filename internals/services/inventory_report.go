@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+// InventoryValue totals the devices in one part of the inventory at cost and at book value
+type InventoryValue struct {
+	Devices   int          `json:"devices"`
+	Cost      money.Amount `json:"cost"`
+	BookValue money.Amount `json:"bookValue"`
+}
+
+func (v *InventoryValue) add(device *models.Device, d Depreciation) {
+	v.Devices++
+	if device.PurchasePrice != nil {
+		v.Cost += *device.PurchasePrice
+	}
+	v.BookValue += d.BookValue
+}
+
+// InventoryReport counts the devices and values stock on hand against what is deployed with
+// subscriptions and buildings, including devices awaiting collection. Impaired devices are damaged
+// or under repair; written-off devices are counted but carry no value.
+type InventoryReport struct {
+	AsOf     time.Time                   `json:"asOf"`
+	Total    int                         `json:"total"`
+	ByType   map[models.DeviceType]int   `json:"byType"`
+	ByBrand  map[string]int              `json:"byBrand"`
+	ByStatus map[models.DeviceStatus]int `json:"byStatus"`
+	OnHand   InventoryValue              `json:"onHand"`
+	Deployed InventoryValue              `json:"deployed"`
+	Impaired InventoryValue              `json:"impaired"`
+	// Unpriced counts the devices without a purchase price, which are valued at zero
+	Unpriced int `json:"unpriced"`
+}
+
+// DeviceDepreciation is one line of the depreciation schedule
+type DeviceDepreciation struct {
+	DeviceID     string              `json:"deviceId"`
+	Type         models.DeviceType   `json:"type"`
+	Brand        string              `json:"brand"`
+	Model        string              `json:"model"`
+	SerialNumber string              `json:"serialNumber"`
+	Status       models.DeviceStatus `json:"status"`
+	PurchaseDate *time.Time          `json:"purchaseDate,omitempty"`
+	Cost         money.Amount        `json:"cost"`
+	Depreciation
+}
+
+// DepreciationReport is the depreciation schedule of every priced device still on the books
+type DepreciationReport struct {
+	AsOf        time.Time            `json:"asOf"`
+	Devices     []DeviceDepreciation `json:"devices"`
+	Cost        money.Amount         `json:"cost"`
+	Accumulated money.Amount         `json:"accumulated"`
+	BookValue   money.Amount         `json:"bookValue"`
+}
+
+// InventoryReport counts and values the current inventory, depreciated to asOf
+func (s *DeviceService) InventoryReport(ctx context.Context, asOf time.Time) (*InventoryReport, error) {
+	devices, err := s.deviceRepo.GetInventory(ctx)
+	if err != nil {
+		logger.Error("Failed to get inventory", zap.Error(err))
+		return nil, err
+	}
+	return buildInventoryReport(devices, s.usefulLives, asOf), nil
+}
+
+// DepreciationReport lists the depreciation of the current inventory as of asOf
+func (s *DeviceService) DepreciationReport(ctx context.Context, asOf time.Time) (*DepreciationReport, error) {
+	devices, err := s.deviceRepo.GetInventory(ctx)
+	if err != nil {
+		logger.Error("Failed to get inventory", zap.Error(err))
+		return nil, err
+	}
+	return buildDepreciationReport(devices, s.usefulLives, asOf), nil
+}
+
+func buildInventoryReport(devices []models.Device, lives UsefulLives, asOf time.Time) *InventoryReport {
+	report := &InventoryReport{
+		AsOf:     asOf,
+		ByType:   make(map[models.DeviceType]int),
+		ByBrand:  make(map[string]int),
+		ByStatus: make(map[models.DeviceStatus]int),
+	}
+
+	for i := range devices {
+		device := &devices[i]
+		report.Total++
+		report.ByType[device.Type]++
+		report.ByBrand[device.Brand]++
+		report.ByStatus[device.Status]++
+
+		if device.Status == models.WrittenOff {
+			continue
+		}
+		if device.PurchasePrice == nil {
+			report.Unpriced++
+		}
+
+		d := depreciateDevice(device, lives, asOf)
+		switch {
+		case device.Status == models.InStock:
+			report.OnHand.add(device, d)
+		case device.Status.IsAssigned():
+			report.Deployed.add(device, d)
+		default:
+			report.Impaired.add(device, d)
+		}
+	}
+	return report
+}
+
+func buildDepreciationReport(devices []models.Device, lives UsefulLives, asOf time.Time) *DepreciationReport {
+	report := &DepreciationReport{AsOf: asOf, Devices: []DeviceDepreciation{}}
+
+	for i := range devices {
+		device := &devices[i]
+		if device.Status == models.WrittenOff || device.PurchasePrice == nil {
+			continue
+		}
+
+		d := depreciateDevice(device, lives, asOf)
+		report.Devices = append(report.Devices, DeviceDepreciation{
+			DeviceID:     device.ID,
+			Type:         device.Type,
+			Brand:        device.Brand,
+			Model:        device.Model,
+			SerialNumber: device.SerialNumber,
+			Status:       device.Status,
+			PurchaseDate: device.PurchaseDate,
+			Cost:         *device.PurchasePrice,
+			Depreciation: d,
+		})
+		report.Cost += *device.PurchasePrice
+		report.Accumulated += d.Accumulated
+		report.BookValue += d.BookValue
+	}
+	return report
+}
+
+// depreciateDevice depreciates the device's purchase price; a device without a purchase date has
+// not started depreciating
+func depreciateDevice(device *models.Device, lives UsefulLives, asOf time.Time) Depreciation {
+	var cost money.Amount
+	if device.PurchasePrice != nil {
+		cost = *device.PurchasePrice
+	}
+	purchased := asOf
+	if device.PurchaseDate != nil {
+		purchased = *device.PurchaseDate
+	}
+	return depreciate(cost, purchased, asOf, lives.For(device.Type))
+}
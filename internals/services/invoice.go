@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvoiceNotOpen     = errors.New("invoice is no longer open")
+	ErrInvoiceHasPayments = errors.New("invoice has payments applied")
+)
+
+// InvoiceDetail is an invoice with its lines and the payment allocations that settled it
+type InvoiceDetail struct {
+	models.Invoice
+	Allocations []models.PaymentAllocation `json:"allocations"`
+	Balance     money.Amount               `json:"balance"`
+}
+
+// InvoiceUpdate holds the editable fields of an open invoice; nil fields are left unchanged
+type InvoiceUpdate struct {
+	Lines   []models.InvoiceLine
+	DueDate *time.Time
+}
+
+type InvoiceService struct {
+	invoiceRepo      repositories.InvoiceRepository
+	paymentRepo      repositories.PaymentRepository
+	subscriptionRepo repositories.SubscriptionRepository
+	packageRepo      repositories.PackageRepository
+	settlement       settlement
+	ledger           ledger
+}
+
+func NewInvoiceService(
+	ir repositories.InvoiceRepository,
+	pr repositories.PaymentRepository,
+	sr repositories.SubscriptionRepository,
+	pkr repositories.PackageRepository,
+	cr repositories.CustomerCreditRepository,
+	lr repositories.LedgerRepository) *InvoiceService {
+	return &InvoiceService{
+		invoiceRepo:      ir,
+		paymentRepo:      pr,
+		subscriptionRepo: sr,
+		packageRepo:      pkr,
+		settlement:       settlement{invoiceRepo: ir, subscriptionRepo: sr, creditRepo: cr},
+		ledger:           ledger{repo: lr},
+	}
+}
+
+// CreateInvoice issues a manual invoice. An invoice for a subscription without lines of its
+// own bills one month of that subscription; the amount is always the total of the lines.
+// Available customer credit is applied to the new invoice straight away. A manual invoice starts
+// unpaid and open, and never claims a billing period, which is the billing run's to invoice.
+func (s *InvoiceService) CreateInvoice(ctx context.Context, invoice *models.Invoice) error {
+	invoice.ID = uuid.New().String()
+	invoice.Number = nil
+	invoice.Status = models.InvoicePending
+	invoice.PaidAmount, invoice.PaidDate = 0, nil
+	invoice.BillingPeriod = nil
+	invoice.ClosedAt, invoice.ClosedBy, invoice.CloseReason = nil, nil, nil
+	lines := invoice.Lines
+
+	if invoice.SubscriptionID != nil {
+		subscription, err := s.subscriptionRepo.GetSubscription(ctx, *invoice.SubscriptionID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: subscription not found", ErrInvalidInvoice)
+			}
+			return err
+		}
+
+		invoice.CustomerID = subscription.CustomerID
+		invoice.Currency = subscription.Currency
+		if invoice.DueDate.IsZero() {
+			invoice.DueDate = subscription.RenewalDate
+		}
+
+		if len(lines) == 0 {
+			pkg, err := s.packageRepo.GetPackageByID(ctx, subscription.PackageID)
+			if err != nil {
+				return fmt.Errorf("failed to get package: %w", err)
+			}
+			lines = SubscriptionLines(subscription, pkg.Name, "")
+		}
+	}
+
+	if invoice.CustomerID == "" {
+		return fmt.Errorf("%w: customerId or subscriptionId is required", ErrInvalidInvoice)
+	}
+	if invoice.Currency == "" {
+		invoice.Currency = money.DefaultCurrency
+	}
+	if err := SetInvoiceLines(invoice, lines); err != nil {
+		return err
+	}
+
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.invoiceRepo.CreateInvoice(ctx, invoice); err != nil {
+			return err
+		}
+		if err := s.ledger.postInvoice(ctx, invoice); err != nil {
+			return err
+		}
+		_, err := s.settlement.applyCredit(ctx, invoice)
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to create invoice", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetInvoiceDetail returns nil without error when the invoice does not exist
+func (s *InvoiceService) GetInvoiceDetail(ctx context.Context, id string) (*InvoiceDetail, error) {
+	invoice, err := s.invoiceRepo.GetInvoiceByID(ctx, id)
+	if err != nil || invoice == nil {
+		return nil, err
+	}
+
+	allocations, err := s.paymentRepo.GetAllocationsByInvoiceID(ctx, invoice.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &InvoiceDetail{
+		Invoice:     *invoice,
+		Allocations: allocations,
+	}
+	if invoice.Status.IsOpen() {
+		detail.Balance = invoice.Balance()
+	}
+	return detail, nil
+}
+
+// UpdateInvoice amends the lines or due date of an open invoice. An amendment that brings the
+// total down to what was already paid settles the invoice and renews what it bills.
+func (s *InvoiceService) UpdateInvoice(ctx context.Context, id string, update InvoiceUpdate) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		invoice, err = s.lockOpenInvoice(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if update.DueDate != nil {
+			invoice.DueDate = *update.DueDate
+		}
+		if update.Lines == nil {
+			if invoice.Lines, err = s.invoiceRepo.GetInvoiceLines(ctx, invoice.ID); err != nil {
+				return err
+			}
+			return s.invoiceRepo.UpdateInvoice(ctx, invoice)
+		}
+
+		previous := invoice.Amount
+		if err := SetInvoiceLines(invoice, update.Lines); err != nil {
+			return err
+		}
+		if invoice.Amount < invoice.PaidAmount {
+			return fmt.Errorf("%w: the new total is less than the %s already paid", ErrInvalidInvoice, invoice.PaidAmount)
+		}
+		if err := s.invoiceRepo.ReplaceInvoiceLines(ctx, invoice); err != nil {
+			return err
+		}
+		if err := s.ledger.postInvoiceChange(ctx, invoice, previous, "Invoice amended"); err != nil {
+			return err
+		}
+		if invoice.Balance() > 0 {
+			return nil
+		}
+		// What was already paid now covers the invoice, which settles it like a final payment would
+		return s.settlement.settle(ctx, invoice, 0, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Invoice updated", zap.String("invoiceID", invoice.ID))
+	return invoice, nil
+}
+
+// CancelInvoice withdraws an unpaid invoice; the charge is waived and the billing period stays invoiced
+func (s *InvoiceService) CancelInvoice(ctx context.Context, id, reason string) (*models.Invoice, error) {
+	return s.closeInvoice(ctx, id, models.InvoiceCancelled, reason)
+}
+
+// VoidInvoice nullifies an invoice issued in error, which lets the billing run issue its period again
+func (s *InvoiceService) VoidInvoice(ctx context.Context, id, reason string) (*models.Invoice, error) {
+	return s.closeInvoice(ctx, id, models.InvoiceVoid, reason)
+}
+
+func (s *InvoiceService) closeInvoice(ctx context.Context, id string, status models.InvoiceStatus, reason string) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		invoice, err = s.lockOpenInvoice(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if invoice.PaidAmount > 0 {
+			return ErrInvoiceHasPayments
+		}
+
+		now := time.Now()
+		actor := auth.ActorID(ctx)
+		invoice.Status = status
+		invoice.ClosedAt = &now
+		invoice.ClosedBy = &actor
+		if reason != "" {
+			invoice.CloseReason = &reason
+		}
+		if err := s.invoiceRepo.UpdateInvoice(ctx, invoice); err != nil {
+			return err
+		}
+		return s.ledger.postInvoiceClosed(ctx, invoice)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Invoice closed", zap.String("invoiceID", invoice.ID), zap.String("status", string(status)))
+	return invoice, nil
+}
+
+// lockOpenInvoice loads and locks the invoice, failing unless it is still awaiting payment
+func (s *InvoiceService) lockOpenInvoice(ctx context.Context, id string) (*models.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetInvoiceForUpdate(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+
+	if invoice.Status == models.InvoicePaid {
+		return nil, ErrInvoiceAlreadyPaid
+	}
+	if !invoice.Status.IsOpen() {
+		return nil, ErrInvoiceNotOpen
+	}
+	return invoice, nil
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+var ErrInvalidInvoice = errors.New("invalid invoice")
+
+// SubscriptionLines bills one month of a subscription: the package price followed by a
+// discount line when the subscription has a monthly discount
+func SubscriptionLines(subscription *models.Subscription, packageName, period string) []models.InvoiceLine {
+	subscriptionID := subscription.ID
+	description := packageName + " subscription"
+	if period != "" {
+		description = fmt.Sprintf("%s (%s)", description, period)
+	}
+
+	price := getMonthlyPrice(subscription)
+	lines := []models.InvoiceLine{{
+		Type:           models.LineSubscription,
+		Description:    description,
+		SubscriptionID: &subscriptionID,
+		Quantity:       1,
+		UnitPrice:      price,
+	}}
+
+	if discount := money.Min(subscription.MonthlyDiscount, price); discount > 0 {
+		lines = append(lines, models.InvoiceLine{
+			Type:           models.LineDiscount,
+			Description:    "Monthly discount",
+			SubscriptionID: &subscriptionID,
+			Quantity:       1,
+			UnitPrice:      -discount,
+		})
+	}
+	return lines
+}
+
+// SetInvoiceLines validates the lines, numbers them and attaches them to the invoice,
+// whose amount becomes the total of the lines
+func SetInvoiceLines(invoice *models.Invoice, lines []models.InvoiceLine) error {
+	if len(lines) == 0 {
+		return fmt.Errorf("%w: at least one line is required", ErrInvalidInvoice)
+	}
+
+	for i := range lines {
+		line := &lines[i]
+		if !line.Type.IsValid() {
+			return fmt.Errorf("%w: line %d has unknown type %q", ErrInvalidInvoice, i+1, line.Type)
+		}
+		if line.Quantity == 0 {
+			line.Quantity = 1
+		}
+		if line.Quantity < 0 {
+			return fmt.Errorf("%w: line %d has a negative quantity", ErrInvalidInvoice, i+1)
+		}
+		if line.Type == models.LineDiscount && line.UnitPrice > 0 {
+			line.UnitPrice = -line.UnitPrice
+		}
+		if line.Type != models.LineDiscount && line.Type != models.LineProration && line.UnitPrice < 0 {
+			return fmt.Errorf("%w: line %d has a negative price", ErrInvalidInvoice, i+1)
+		}
+
+		if line.ID == "" {
+			line.ID = uuid.New().String()
+		}
+		line.InvoiceID = invoice.ID
+		line.Position = i
+		line.Amount = line.UnitPrice.MulRatio(int64(line.Quantity), 1)
+	}
+
+	invoice.Lines = lines
+	invoice.Amount = InvoiceTotal(lines)
+	return nil
+}
+
+// InvoiceTotal sums the lines; discounts never take an invoice below zero
+func InvoiceTotal(lines []models.InvoiceLine) money.Amount {
+	var total money.Amount
+	for _, line := range lines {
+		total += line.Amount
+	}
+	return money.Max(total, 0)
+}
+
+type subscriptionCharge struct {
+	SubscriptionID string
+	Amount         money.Amount
+	// Prorated charges bill part of a month, up to the subscription's renewal date
+	Prorated bool
+}
+
+// subscriptionCharges totals the subscription, proration and discount lines per subscription, in
+// line order. A charge made only of proration lines is prorated. Device, installation, arrears and
+// other one-off lines do not pay for service, and late fees are penalties, so neither counts. A
+// legacy invoice without lines, apart from late fees added since, charges its whole amount less
+// those fees to its own subscription, if any.
+func subscriptionCharges(invoice *models.Invoice) []subscriptionCharge {
+	var charges []subscriptionCharge
+	var lateFees money.Amount
+	billed := make(map[string]bool)
+	index := make(map[string]int)
+	legacy := true
+	for _, line := range invoice.Lines {
+		if line.Type == models.LineLateFee {
+			lateFees += line.Amount
+			continue
+		}
+		legacy = false
+		if line.SubscriptionID == nil || !line.Type.IsServiceCharge() {
+			continue
+		}
+		i, ok := index[*line.SubscriptionID]
+		if !ok {
+			i = len(charges)
+			index[*line.SubscriptionID] = i
+			charges = append(charges, subscriptionCharge{SubscriptionID: *line.SubscriptionID, Prorated: true})
+		}
+		charges[i].Amount += line.Amount
+		if line.Type != models.LineDiscount {
+			billed[*line.SubscriptionID] = true
+			charges[i].Prorated = charges[i].Prorated && line.Type == models.LineProration
+		}
+	}
+
+	if legacy {
+		if invoice.SubscriptionID == nil {
+			return nil
+		}
+		return []subscriptionCharge{{SubscriptionID: *invoice.SubscriptionID, Amount: money.Max(invoice.Amount-lateFees, 0)}}
+	}
+
+	// A discount on its own pays for no service
+	service := charges[:0]
+	for _, charge := range charges {
+		if billed[charge.SubscriptionID] {
+			service = append(service, charge)
+		}
+	}
+	return service
+}
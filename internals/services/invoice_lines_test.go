@@ -0,0 +1,109 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestSubscriptionLines(t *testing.T) {
+	subscription := &models.Subscription{
+		ID:              "sub-1",
+		PackagePrice:    money.FromMajor(800),
+		MonthlyDiscount: money.FromMajor(100),
+	}
+
+	lines := SubscriptionLines(subscription, "Home 20 Mbps", "2026-11")
+	require.Len(t, lines, 2)
+	assert.Equal(t, models.LineSubscription, lines[0].Type)
+	assert.Equal(t, "Home 20 Mbps subscription (2026-11)", lines[0].Description)
+	assert.Equal(t, money.FromMajor(800), lines[0].UnitPrice)
+	assert.Equal(t, models.LineDiscount, lines[1].Type)
+	assert.Equal(t, money.FromMajor(-100), lines[1].UnitPrice)
+
+	invoice := &models.Invoice{ID: "inv-1"}
+	require.NoError(t, SetInvoiceLines(invoice, lines))
+	assert.Equal(t, MonthlyCharge(subscription), invoice.Amount)
+
+	subscription.MonthlyDiscount = 0
+	assert.Len(t, SubscriptionLines(subscription, "Home 20 Mbps", ""), 1)
+}
+
+func TestSetInvoiceLines(t *testing.T) {
+	invoice := &models.Invoice{ID: "inv-1"}
+	lines := []models.InvoiceLine{
+		{Type: models.LineInstallation, Description: "Installation", UnitPrice: money.FromMajor(500)},
+		{Type: models.LineDeviceFee, Description: "ONU rent", Quantity: 2, UnitPrice: money.FromMajor(50)},
+		{Type: models.LineDiscount, Description: "Promotion", UnitPrice: money.FromMajor(150)},
+	}
+
+	require.NoError(t, SetInvoiceLines(invoice, lines))
+	assert.Equal(t, money.FromMajor(450), invoice.Amount)
+	for i, line := range invoice.Lines {
+		assert.Equal(t, "inv-1", line.InvoiceID)
+		assert.Equal(t, i, line.Position)
+		assert.NotEmpty(t, line.ID)
+	}
+	assert.Equal(t, 1, invoice.Lines[0].Quantity)
+	assert.Equal(t, money.FromMajor(100), invoice.Lines[1].Amount)
+	assert.Equal(t, money.FromMajor(-150), invoice.Lines[2].Amount)
+
+	err := SetInvoiceLines(invoice, []models.InvoiceLine{{Type: models.LineDiscount, UnitPrice: money.FromMajor(10)}})
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), invoice.Amount, "discounts never make an invoice negative")
+
+	assert.ErrorIs(t, SetInvoiceLines(invoice, nil), ErrInvalidInvoice)
+	assert.ErrorIs(t, SetInvoiceLines(invoice, []models.InvoiceLine{{Type: "RENT"}}), ErrInvalidInvoice)
+	assert.ErrorIs(t, SetInvoiceLines(invoice, []models.InvoiceLine{{Type: models.LineOther, UnitPrice: -1}}), ErrInvalidInvoice)
+}
+
+func TestSubscriptionCharges(t *testing.T) {
+	internet, cable := "sub-internet", "sub-cable"
+	invoice := &models.Invoice{
+		Lines: []models.InvoiceLine{
+			{Type: models.LineSubscription, SubscriptionID: &internet, Amount: money.FromMajor(800)},
+			{Type: models.LineSubscription, SubscriptionID: &cable, Amount: money.FromMajor(300)},
+			{Type: models.LineDiscount, SubscriptionID: &internet, Amount: money.FromMajor(-100)},
+			{Type: models.LineDeviceFee, SubscriptionID: &cable, Amount: money.FromMajor(1500)},
+			{Type: models.LineOther, Amount: money.FromMajor(500)},
+		},
+	}
+
+	assert.Equal(t, []subscriptionCharge{
+		{SubscriptionID: internet, Amount: money.FromMajor(700)},
+		{SubscriptionID: cable, Amount: money.FromMajor(300)},
+	}, subscriptionCharges(invoice))
+
+	first := &models.Invoice{
+		Lines: []models.InvoiceLine{
+			{Type: models.LineProration, SubscriptionID: &internet, Amount: money.FromMajor(400)},
+			{Type: models.LineProration, SubscriptionID: &internet, Amount: money.FromMajor(-50)},
+		},
+	}
+	assert.Equal(t, []subscriptionCharge{{SubscriptionID: internet, Amount: money.FromMajor(350), Prorated: true}}, subscriptionCharges(first))
+
+	first.Lines = append(first.Lines, models.InvoiceLine{Type: models.LineLateFee, SubscriptionID: &internet, Amount: money.FromMajor(50)})
+	assert.Equal(t, []subscriptionCharge{{SubscriptionID: internet, Amount: money.FromMajor(350), Prorated: true}}, subscriptionCharges(first),
+		"a late fee neither adds to the charge nor stops it being prorated")
+
+	manual := &models.Invoice{
+		SubscriptionID: &cable,
+		Amount:         money.FromMajor(1200),
+		Lines: []models.InvoiceLine{
+			{Type: models.LineInstallation, SubscriptionID: &cable, Amount: money.FromMajor(1000)},
+			{Type: models.LineArrears, SubscriptionID: &cable, Amount: money.FromMajor(300)},
+			{Type: models.LineDiscount, SubscriptionID: &cable, Amount: money.FromMajor(-100)},
+		},
+	}
+	assert.Empty(t, subscriptionCharges(manual), "one-off charges and a lone discount renew nothing")
+
+	legacy := &models.Invoice{SubscriptionID: &cable, Amount: money.FromMajor(300)}
+	assert.Equal(t, []subscriptionCharge{{SubscriptionID: cable, Amount: money.FromMajor(300)}}, subscriptionCharges(legacy))
+
+	legacy.Amount = money.FromMajor(330)
+	legacy.Lines = []models.InvoiceLine{{Type: models.LineLateFee, Amount: money.FromMajor(30)}}
+	assert.Equal(t, []subscriptionCharge{{SubscriptionID: cable, Amount: money.FromMajor(300)}}, subscriptionCharges(legacy))
+}
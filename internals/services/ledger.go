@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+// ledger posts the customer account entries that mirror invoice and payment changes.
+// It is called inside the same transaction as the change it records.
+type ledger struct {
+	repo repositories.LedgerRepository
+}
+
+func (l ledger) post(ctx context.Context, entry models.LedgerEntry) error {
+	entry.ID = uuid.New().String()
+	entry.PostedBy = auth.ActorID(ctx)
+	if entry.PostedAt.IsZero() {
+		entry.PostedAt = time.Now()
+	}
+	if err := l.repo.CreateLedgerEntry(ctx, &entry); err != nil {
+		logger.Error("Failed to post ledger entry", zap.Error(err), zap.String("customerID", entry.CustomerID))
+		return err
+	}
+	return nil
+}
+
+func (l ledger) postInvoice(ctx context.Context, invoice *models.Invoice) error {
+	if invoice.Amount <= 0 {
+		return nil
+	}
+	description := "Invoice"
+	if invoice.BillingPeriod != nil {
+		description = "Invoice for " + *invoice.BillingPeriod
+	}
+	return l.post(ctx, models.LedgerEntry{
+		CustomerID:  invoice.CustomerID,
+		Type:        models.LedgerInvoice,
+		Debit:       invoice.Amount,
+		Currency:    invoice.Currency,
+		InvoiceID:   &invoice.ID,
+		Description: description,
+	})
+}
+
+// postInvoiceChange records a change of an invoice's total from previous to its current amount
+func (l ledger) postInvoiceChange(ctx context.Context, invoice *models.Invoice, previous money.Amount, description string) error {
+	delta := invoice.Amount - previous
+	if delta == 0 {
+		return nil
+	}
+
+	entry := models.LedgerEntry{
+		CustomerID:  invoice.CustomerID,
+		Type:        models.LedgerAdjustment,
+		Currency:    invoice.Currency,
+		InvoiceID:   &invoice.ID,
+		Description: description,
+	}
+	if delta > 0 {
+		entry.Debit = delta
+	} else {
+		entry.Credit = -delta
+	}
+	return l.post(ctx, entry)
+}
+
+// postInvoiceClosed reverses the debit of a cancelled or voided invoice
+func (l ledger) postInvoiceClosed(ctx context.Context, invoice *models.Invoice) error {
+	if invoice.Amount <= 0 {
+		return nil
+	}
+	description := "Invoice cancelled"
+	if invoice.Status == models.InvoiceVoid {
+		description = "Invoice voided"
+	}
+	return l.post(ctx, models.LedgerEntry{
+		CustomerID:  invoice.CustomerID,
+		Type:        models.LedgerAdjustment,
+		Credit:      invoice.Amount,
+		Currency:    invoice.Currency,
+		InvoiceID:   &invoice.ID,
+		Description: description,
+	})
+}
+
+// postCredit records credit granted to the customer outside of a payment, e.g. a prorated refund
+func (l ledger) postCredit(ctx context.Context, credit *models.CustomerCredit) error {
+	return l.post(ctx, models.LedgerEntry{
+		CustomerID:  credit.CustomerID,
+		Type:        models.LedgerAdjustment,
+		Credit:      credit.Amount,
+		Currency:    credit.Currency,
+		Description: credit.Description,
+	})
+}
+
+func (l ledger) postPayment(ctx context.Context, payment *models.Payment) error {
+	description := "Payment received"
+	if payment.Description != "" {
+		description = payment.Description
+	}
+	return l.post(ctx, models.LedgerEntry{
+		CustomerID:  *payment.CustomerID,
+		Type:        models.LedgerPayment,
+		Credit:      payment.Amount,
+		Currency:    payment.Currency,
+		PaymentID:   &payment.ID,
+		Description: description,
+		PostedAt:    payment.PaidAt,
+	})
+}
+
+// postPaymentReversal debits the customer with the amount a reversal takes back
+func (l ledger) postPaymentReversal(ctx context.Context, reversal *models.Payment) error {
+	description := "Payment reversed"
+	if reversal.Reason != nil && *reversal.Reason != "" {
+		description += ": " + *reversal.Reason
+	}
+	return l.post(ctx, models.LedgerEntry{
+		CustomerID:  *reversal.CustomerID,
+		Type:        models.LedgerPayment,
+		Debit:       -reversal.Amount,
+		Currency:    reversal.Currency,
+		PaymentID:   &reversal.ID,
+		Description: description,
+		PostedAt:    reversal.PaidAt,
+	})
+}
+
+type StatementLine struct {
+	models.LedgerEntry
+	Balance money.Amount `json:"balance"`
+}
+
+// Statement is a customer's account activity over [From, To) with the balance carried through it.
+// A positive balance is owed by the customer, a negative one is held in their favour.
+type Statement struct {
+	CustomerID     string          `json:"customerId"`
+	Currency       money.Currency  `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance money.Amount    `json:"openingBalance"`
+	TotalDebit     money.Amount    `json:"totalDebit"`
+	TotalCredit    money.Amount    `json:"totalCredit"`
+	ClosingBalance money.Amount    `json:"closingBalance"`
+	Lines          []StatementLine `json:"lines"`
+}
+
+// buildStatement runs the balance forward from the opening balance through each entry
+func buildStatement(opening money.Amount, entries []models.LedgerEntry) Statement {
+	statement := Statement{
+		OpeningBalance: opening,
+		ClosingBalance: opening,
+		Lines:          make([]StatementLine, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		statement.TotalDebit += entry.Debit
+		statement.TotalCredit += entry.Credit
+		statement.ClosingBalance += entry.Debit - entry.Credit
+		statement.Lines = append(statement.Lines, StatementLine{LedgerEntry: entry, Balance: statement.ClosingBalance})
+	}
+	return statement
+}
+
+type LedgerService struct {
+	ledgerRepo repositories.LedgerRepository
+}
+
+func NewLedgerService(lr repositories.LedgerRepository) *LedgerService {
+	return &LedgerService{
+		ledgerRepo: lr,
+	}
+}
+
+// GetStatement returns the customer's statement for entries posted in [from, to)
+func (s *LedgerService) GetStatement(ctx context.Context, customerID string, currency money.Currency, from, to time.Time) (*Statement, error) {
+	opening, err := s.ledgerRepo.GetBalanceBefore(ctx, customerID, currency, from)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.ledgerRepo.GetLedgerEntries(ctx, customerID, currency, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := buildStatement(opening, entries)
+	statement.CustomerID = customerID
+	statement.Currency = currency
+	statement.From = from
+	statement.To = to
+	return &statement, nil
+}
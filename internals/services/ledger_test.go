@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestBuildStatement(t *testing.T) {
+	entries := []models.LedgerEntry{
+		{Type: models.LedgerInvoice, Debit: money.FromMajor(800)},
+		{Type: models.LedgerPayment, Credit: money.FromMajor(1000)},
+		{Type: models.LedgerAdjustment, Debit: money.FromMajor(50)},
+	}
+
+	statement := buildStatement(money.FromMajor(300), entries)
+	require.Len(t, statement.Lines, 3)
+	assert.Equal(t, money.FromMajor(300), statement.OpeningBalance)
+	assert.Equal(t, money.FromMajor(1100), statement.Lines[0].Balance)
+	assert.Equal(t, money.FromMajor(100), statement.Lines[1].Balance)
+	assert.Equal(t, money.FromMajor(150), statement.Lines[2].Balance)
+	assert.Equal(t, money.FromMajor(850), statement.TotalDebit)
+	assert.Equal(t, money.FromMajor(1000), statement.TotalCredit)
+	assert.Equal(t, money.FromMajor(150), statement.ClosingBalance)
+
+	empty := buildStatement(money.FromMajor(-200), nil)
+	assert.Empty(t, empty.Lines)
+	assert.NotNil(t, empty.Lines, "an empty statement still lists its lines as []")
+	assert.Equal(t, money.FromMajor(-200), empty.ClosingBalance)
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+type LateFeeType string
+
+const (
+	LateFeeFlat       LateFeeType = "flat"
+	LateFeePercentage LateFeeType = "percentage"
+)
+
+// defaultLateFeeKey is the policy used for charges that have no package type of their own
+const defaultLateFeeKey = "default"
+
+// LateFeePolicy is either a flat Amount or a percentage of the overdue charge, optionally capped
+type LateFeePolicy struct {
+	Type LateFeeType
+	// Amount is the fee for flat policies
+	Amount money.Amount
+	// RateBasisPoints is the rate for percentage policies, e.g. 500 is 5%
+	RateBasisPoints int64
+	// Cap limits percentage fees when positive
+	Cap money.Amount
+}
+
+// Fee is the late fee for an overdue charge of the given amount
+func (p LateFeePolicy) Fee(charge money.Amount) money.Amount {
+	if charge <= 0 {
+		return 0
+	}
+
+	switch p.Type {
+	case LateFeeFlat:
+		return money.Max(p.Amount, 0)
+	case LateFeePercentage:
+		fee := charge.Percent(p.RateBasisPoints)
+		if p.Cap > 0 {
+			fee = money.Min(fee, p.Cap)
+		}
+		return money.Max(fee, 0)
+	}
+	return 0
+}
+
+type lateFeeConfig struct {
+	Type    string  `mapstructure:"type"`
+	Amount  string  `mapstructure:"amount"`
+	Percent float64 `mapstructure:"percent"`
+	Cap     string  `mapstructure:"cap"`
+}
+
+// LoadLateFeePolicies reads billing.late_fee.policies, keyed by package type (case-insensitive)
+// with an optional "default" entry for charges not tied to a package
+func LoadLateFeePolicies() (map[string]LateFeePolicy, error) {
+	var configs map[string]lateFeeConfig
+	if err := viper.UnmarshalKey("billing.late_fee.policies", &configs); err != nil {
+		return nil, fmt.Errorf("invalid late fee policies: %w", err)
+	}
+
+	policies := make(map[string]LateFeePolicy, len(configs))
+	for key, config := range configs {
+		policy, err := config.policy()
+		if err != nil {
+			return nil, fmt.Errorf("invalid late fee policy %q: %w", key, err)
+		}
+		policies[strings.ToLower(key)] = policy
+	}
+	return policies, nil
+}
+
+func (c lateFeeConfig) policy() (LateFeePolicy, error) {
+	policy := LateFeePolicy{Type: LateFeeType(strings.ToLower(c.Type))}
+
+	var err error
+	switch policy.Type {
+	case LateFeeFlat:
+		if policy.Amount, err = money.Parse(c.Amount); err != nil {
+			return policy, fmt.Errorf("amount: %w", err)
+		}
+	case LateFeePercentage:
+		if c.Percent < 0 {
+			return policy, errors.New("percent cannot be negative")
+		}
+		policy.RateBasisPoints = int64(math.Round(c.Percent * 100))
+		if c.Cap != "" {
+			if policy.Cap, err = money.Parse(c.Cap); err != nil {
+				return policy, fmt.Errorf("cap: %w", err)
+			}
+		}
+	default:
+		return policy, fmt.Errorf("unknown type %q, expected flat or percentage", c.Type)
+	}
+	return policy, nil
+}
+
+// LateFeeGracePeriod is how long after its due date an invoice becomes overdue
+func LateFeeGracePeriod() time.Duration {
+	days := viper.GetInt("billing.late_fee.grace_days")
+	if days < 0 {
+		days = 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+type OverdueRunResult struct {
+	Overdue     int          `json:"overdue"`
+	FeesApplied int          `json:"feesApplied"`
+	FeesTotal   money.Amount `json:"feesTotal"`
+	Failed      int          `json:"failed"`
+}
+
+type OverdueService struct {
+	invoiceRepo      repositories.InvoiceRepository
+	subscriptionRepo repositories.SubscriptionRepository
+	packageRepo      repositories.PackageRepository
+	ledger           ledger
+	policies         map[string]LateFeePolicy
+	gracePeriod      time.Duration
+}
+
+func NewOverdueService(
+	ir repositories.InvoiceRepository,
+	sr repositories.SubscriptionRepository,
+	pr repositories.PackageRepository,
+	lr repositories.LedgerRepository,
+	policies map[string]LateFeePolicy,
+	gracePeriod time.Duration) *OverdueService {
+	return &OverdueService{
+		invoiceRepo:      ir,
+		subscriptionRepo: sr,
+		packageRepo:      pr,
+		ledger:           ledger{repo: lr},
+		policies:         policies,
+		gracePeriod:      gracePeriod,
+	}
+}
+
+// ProcessOverdueInvoices marks pending invoices past their due date (plus the grace period) as
+// overdue and adds late fee lines to them. Each invoice is handled once, since only pending
+// invoices are picked up.
+func (s *OverdueService) ProcessOverdueInvoices(ctx context.Context, now time.Time) (*OverdueRunResult, error) {
+	result := &OverdueRunResult{}
+
+	invoices, err := s.invoiceRepo.GetPendingInvoicesDueBefore(ctx, now.Add(-s.gracePeriod))
+	if err != nil {
+		logger.Error("Failed to get overdue invoices", zap.Error(err))
+		return nil, err
+	}
+
+	for i := range invoices {
+		fee, err := s.markOverdue(ctx, invoices[i].ID)
+		if err != nil {
+			logger.Error("Failed to mark invoice overdue", zap.Error(err), zap.String("invoiceID", invoices[i].ID))
+			result.Failed++
+			continue
+		}
+
+		result.Overdue++
+		if fee > 0 {
+			result.FeesApplied++
+			result.FeesTotal += fee
+		}
+	}
+
+	logger.Info("Overdue run completed",
+		zap.Int("overdue", result.Overdue),
+		zap.Int("feesApplied", result.FeesApplied),
+		zap.Int("failed", result.Failed),
+	)
+
+	if result.Failed > 0 {
+		return result, fmt.Errorf("failed to process %d overdue invoice(s)", result.Failed)
+	}
+	return result, nil
+}
+
+// markOverdue flips one invoice to OVERDUE and returns the late fee added to it
+func (s *OverdueService) markOverdue(ctx context.Context, invoiceID string) (money.Amount, error) {
+	var fee money.Amount
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		invoice, err := s.invoiceRepo.GetInvoiceForUpdate(ctx, invoiceID)
+		if err != nil {
+			return err
+		}
+		if invoice.Status != models.InvoicePending {
+			return nil
+		}
+		if invoice.Lines, err = s.invoiceRepo.GetInvoiceLines(ctx, invoice.ID); err != nil {
+			return err
+		}
+
+		feeLines, err := s.lateFeeLines(ctx, invoice)
+		if err != nil {
+			return err
+		}
+
+		invoice.Status = models.InvoiceOverdue
+		if len(feeLines) == 0 {
+			return s.invoiceRepo.UpdateInvoice(ctx, invoice)
+		}
+
+		previous := invoice.Amount
+		if err := SetInvoiceLines(invoice, append(invoice.Lines, feeLines...)); err != nil {
+			return err
+		}
+		fee = invoice.Amount - previous
+		if err := s.invoiceRepo.AddInvoiceLines(ctx, invoice, invoice.Lines[len(invoice.Lines)-len(feeLines):]); err != nil {
+			return err
+		}
+		return s.ledger.postInvoiceChange(ctx, invoice, previous, "Late payment fee")
+	})
+	return fee, err
+}
+
+// lateFeeLines charges one late fee per billed subscription using the policy for its package type,
+// or a single fee under the default policy for invoices that bill no subscription. An invoice that
+// already carries a late fee, e.g. one reopened by a payment reversal, is not charged again.
+func (s *OverdueService) lateFeeLines(ctx context.Context, invoice *models.Invoice) ([]models.InvoiceLine, error) {
+	for _, line := range invoice.Lines {
+		if line.Type == models.LineLateFee {
+			return nil, nil
+		}
+	}
+
+	charges := subscriptionCharges(invoice)
+	if len(charges) == 0 {
+		policy, ok := s.policies[defaultLateFeeKey]
+		if !ok {
+			return nil, nil
+		}
+		return lateFeeLine(nil, policy.Fee(invoice.Amount)), nil
+	}
+
+	var lines []models.InvoiceLine
+	for _, charge := range charges {
+		packageType, err := s.subscriptionPackageType(ctx, charge.SubscriptionID)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, ok := s.policies[strings.ToLower(string(packageType))]
+		if !ok {
+			policy, ok = s.policies[defaultLateFeeKey]
+		}
+		if !ok {
+			continue
+		}
+
+		subscriptionID := charge.SubscriptionID
+		lines = append(lines, lateFeeLine(&subscriptionID, policy.Fee(charge.Amount))...)
+	}
+	return lines, nil
+}
+
+func (s *OverdueService) subscriptionPackageType(ctx context.Context, subscriptionID string) (models.PackageType, error) {
+	subscription, err := s.subscriptionRepo.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get subscription %s: %w", subscriptionID, err)
+	}
+	pkg, err := s.packageRepo.GetPackageByID(ctx, subscription.PackageID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get package %s: %w", subscription.PackageID, err)
+	}
+	return pkg.Type, nil
+}
+
+func lateFeeLine(subscriptionID *string, fee money.Amount) []models.InvoiceLine {
+	if fee <= 0 {
+		return nil
+	}
+	return []models.InvoiceLine{{
+		Type:           models.LineLateFee,
+		Description:    "Late payment fee",
+		SubscriptionID: subscriptionID,
+		Quantity:       1,
+		UnitPrice:      fee,
+	}}
+}
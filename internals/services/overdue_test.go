@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestLateFeePolicyFee(t *testing.T) {
+	flat := LateFeePolicy{Type: LateFeeFlat, Amount: money.FromMajor(50)}
+	assert.Equal(t, money.FromMajor(50), flat.Fee(money.FromMajor(800)))
+	assert.Equal(t, money.Amount(0), flat.Fee(0))
+
+	percentage := LateFeePolicy{Type: LateFeePercentage, RateBasisPoints: 500, Cap: money.FromMajor(100)}
+	assert.Equal(t, money.FromMajor(40), percentage.Fee(money.FromMajor(800)))
+	assert.Equal(t, money.FromMajor(100), percentage.Fee(money.FromMajor(5000)), "percentage fees are capped")
+
+	uncapped := LateFeePolicy{Type: LateFeePercentage, RateBasisPoints: 250}
+	assert.Equal(t, money.MustParse("31.25"), uncapped.Fee(money.FromMajor(1250)))
+}
+
+func TestLoadLateFeePolicies(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	viper.Set("billing.late_fee.policies", map[string]interface{}{
+		"Internet": map[string]interface{}{"type": "percentage", "percent": 2.5, "cap": "75.50"},
+		"cabletv":  map[string]interface{}{"type": "flat", "amount": 50},
+	})
+
+	policies, err := LoadLateFeePolicies()
+	require.NoError(t, err)
+	assert.Equal(t, LateFeePolicy{Type: LateFeePercentage, RateBasisPoints: 250, Cap: money.MustParse("75.50")}, policies["internet"])
+	assert.Equal(t, LateFeePolicy{Type: LateFeeFlat, Amount: money.FromMajor(50)}, policies["cabletv"])
+
+	viper.Set("billing.late_fee.policies", map[string]interface{}{
+		"internet": map[string]interface{}{"type": "daily"},
+	})
+	_, err = LoadLateFeePolicies()
+	assert.Error(t, err)
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPackageChange    = errors.New("invalid package change")
+	ErrPackageChangeNotAllowed = errors.New("the subscription's package cannot be changed in its current status")
+)
+
+// PackageChangeRequest moves a subscription to another package, either right away with prorated
+// billing for the rest of the cycle or, when NextCycle is set, from its next renewal
+type PackageChangeRequest struct {
+	PackageID string
+	NextCycle bool
+	Reason    string
+}
+
+// PackageChangeResult is the recorded change with the adjustment invoice or credit it produced
+type PackageChangeResult struct {
+	Change  *models.SubscriptionPackageChange `json:"change"`
+	Invoice *models.Invoice                   `json:"invoice,omitempty"`
+	Credit  money.Amount                      `json:"credit"`
+}
+
+// proration is the part of a monthly charge covering the days left until the renewal date
+type proration struct {
+	Days      int
+	CycleDays int
+}
+
+// newProration measures the days from at until renewalDate against the month-long cycle ending
+// on renewalDate. A renewal date already past leaves nothing to prorate.
+func newProration(renewalDate, at time.Time) proration {
+	cycleDays := daysBetween(addMonths(renewalDate, -1), renewalDate)
+	days := daysBetween(startOfDay(at), renewalDate)
+	return proration{Days: min(max(days, 0), cycleDays), CycleDays: cycleDays}
+}
+
+func (p proration) of(monthly money.Amount) money.Amount {
+	if p.CycleDays <= 0 {
+		return 0
+	}
+	return monthly.MulRatio(int64(p.Days), int64(p.CycleDays))
+}
+
+// daysBetween counts calendar days from a to b, ignoring daylight saving shifts
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return int(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC).Sub(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
+
+type PackageChangeService struct {
+	subscriptionRepo repositories.SubscriptionRepository
+	packageRepo      repositories.PackageRepository
+	invoiceRepo      repositories.InvoiceRepository
+	creditRepo       repositories.CustomerCreditRepository
+	settlement       settlement
+	ledger           ledger
+}
+
+func NewPackageChangeService(
+	sr repositories.SubscriptionRepository,
+	pr repositories.PackageRepository,
+	ir repositories.InvoiceRepository,
+	cr repositories.CustomerCreditRepository,
+	lr repositories.LedgerRepository) *PackageChangeService {
+	return &PackageChangeService{
+		subscriptionRepo: sr,
+		packageRepo:      pr,
+		invoiceRepo:      ir,
+		creditRepo:       cr,
+		settlement:       settlement{invoiceRepo: ir, subscriptionRepo: sr, creditRepo: cr},
+		ledger:           ledger{repo: lr},
+	}
+}
+
+// ChangePackage switches the subscription to another package of the same type. Applied now, the
+// customer is credited for the unused days of the old package and charged for the same days of
+// the new one; what is owed on balance goes on an adjustment invoice and what is due back is kept
+// as customer credit. Scheduled for the next cycle, the change waits for the billing run of the
+// cycle starting at the renewal date. Either way it replaces any change still waiting.
+func (s *PackageChangeService) ChangePackage(ctx context.Context, subscriptionID string, request PackageChangeRequest) (*PackageChangeResult, error) {
+	result := &PackageChangeResult{}
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		subscription, err := s.subscriptionRepo.GetSubscriptionForUpdate(ctx, subscriptionID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSubscriptionNotFound
+			}
+			return err
+		}
+		switch subscription.Status {
+		case models.SubscriptionActive, models.SubscriptionSuspended, models.SubscriptionPendingActivation:
+		default:
+			return fmt.Errorf("%w: %s", ErrPackageChangeNotAllowed, subscription.Status)
+		}
+
+		current, target, err := s.packages(ctx, subscription, request.PackageID)
+		if err != nil {
+			return err
+		}
+		if err := s.supersedeScheduled(ctx, subscription.ID); err != nil {
+			return err
+		}
+
+		change := &models.SubscriptionPackageChange{
+			ID:             uuid.New().String(),
+			SubscriptionID: subscription.ID,
+			FromPackageID:  current.ID,
+			ToPackageID:    target.ID,
+			FromPrice:      subscription.PackagePrice,
+			ToPrice:        target.Price,
+			Kind:           models.PackageDowngrade,
+			Status:         models.PackageChangeScheduled,
+			EffectiveAt:    subscription.RenewalDate,
+			Currency:       subscription.Currency,
+			Reason:         request.Reason,
+			ChangedBy:      auth.ActorID(ctx),
+		}
+		if target.Price > subscription.PackagePrice {
+			change.Kind = models.PackageUpgrade
+		}
+		result.Change = change
+
+		if request.NextCycle {
+			return s.subscriptionRepo.CreatePackageChange(ctx, change)
+		}
+		return s.applyNow(ctx, subscription, current, target, change, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Subscription package changed",
+		zap.String("subscriptionID", subscriptionID),
+		zap.String("packageID", request.PackageID),
+		zap.String("status", string(result.Change.Status)),
+	)
+	return result, nil
+}
+
+func (s *PackageChangeService) applyNow(
+	ctx context.Context,
+	subscription *models.Subscription,
+	current, target *models.Package,
+	change *models.SubscriptionPackageChange,
+	result *PackageChangeResult) error {
+	now := time.Now()
+	oldCharge := MonthlyCharge(subscription)
+	subscription.PackageID = target.ID
+	subscription.PackagePrice = target.Price
+	newCharge := MonthlyCharge(subscription)
+
+	p := newProration(subscription.RenewalDate, now)
+	change.Status = models.PackageChangeApplied
+	change.EffectiveAt = now
+	change.AppliedAt = &now
+	change.ProratedDays = p.Days
+	change.CycleDays = p.CycleDays
+	change.Credit = p.of(oldCharge)
+	change.Charge = p.of(newCharge)
+
+	if err := s.subscriptionRepo.UpdateSubscription(ctx, subscription); err != nil {
+		return err
+	}
+
+	switch net := change.Charge - change.Credit; {
+	case net > 0:
+		invoice, err := s.adjustmentInvoice(ctx, subscription, current, target, change)
+		if err != nil {
+			return err
+		}
+		change.InvoiceID = &invoice.ID
+		result.Invoice = invoice
+	case net < 0:
+		credit := &models.CustomerCredit{
+			ID:          uuid.New().String(),
+			CustomerID:  subscription.CustomerID,
+			Amount:      -net,
+			Currency:    subscription.Currency,
+			Description: fmt.Sprintf("Prorated credit for changing from %s to %s", current.Name, target.Name),
+		}
+		if err := s.creditRepo.CreateCreditEntry(ctx, credit); err != nil {
+			return err
+		}
+		if err := s.ledger.postCredit(ctx, credit); err != nil {
+			return err
+		}
+		result.Credit = credit.Amount
+	}
+	return s.subscriptionRepo.CreatePackageChange(ctx, change)
+}
+
+// adjustmentInvoice bills the prorated difference. Its lines are not tied to the subscription,
+// so paying it settles the difference without renewing the subscription for another month.
+func (s *PackageChangeService) adjustmentInvoice(
+	ctx context.Context,
+	subscription *models.Subscription,
+	current, target *models.Package,
+	change *models.SubscriptionPackageChange) (*models.Invoice, error) {
+	days := fmt.Sprintf("%d of %d days", change.ProratedDays, change.CycleDays)
+	lines := []models.InvoiceLine{{
+		Type:        models.LineProration,
+		Description: fmt.Sprintf("%s until %s (%s)", target.Name, subscription.RenewalDate.Format("2006-01-02"), days),
+		UnitPrice:   change.Charge,
+	}}
+	if change.Credit > 0 {
+		lines = append(lines, models.InvoiceLine{
+			Type:        models.LineProration,
+			Description: fmt.Sprintf("Unused %s (%s)", current.Name, days),
+			UnitPrice:   -change.Credit,
+		})
+	}
+
+	invoice := &models.Invoice{
+		ID:         uuid.New().String(),
+		CustomerID: subscription.CustomerID,
+		Currency:   subscription.Currency,
+		Status:     models.InvoicePending,
+		DueDate:    subscription.RenewalDate,
+	}
+	if today := startOfDay(time.Now()); invoice.DueDate.Before(today) {
+		invoice.DueDate = today
+	}
+	if err := SetInvoiceLines(invoice, lines); err != nil {
+		return nil, err
+	}
+	if err := s.invoiceRepo.CreateInvoice(ctx, invoice); err != nil {
+		return nil, err
+	}
+	if err := s.ledger.postInvoice(ctx, invoice); err != nil {
+		return nil, err
+	}
+	if _, err := s.settlement.applyCredit(ctx, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// packages loads the subscription's current package and checks the requested one can replace it
+func (s *PackageChangeService) packages(ctx context.Context, subscription *models.Subscription, packageID string) (*models.Package, *models.Package, error) {
+	if packageID == "" {
+		return nil, nil, fmt.Errorf("%w: packageId is required", ErrInvalidPackageChange)
+	}
+	if packageID == subscription.PackageID {
+		return nil, nil, fmt.Errorf("%w: the subscription is already on this package", ErrInvalidPackageChange)
+	}
+
+	current, err := s.packageRepo.GetPackageByID(ctx, subscription.PackageID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get package %s: %w", subscription.PackageID, err)
+	}
+	target, err := s.packageRepo.GetPackageByID(ctx, packageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("%w: package not found", ErrInvalidPackageChange)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case !target.IsActive:
+		return nil, nil, fmt.Errorf("%w: package %s is not active", ErrInvalidPackageChange, target.Name)
+	case target.Type != current.Type:
+		return nil, nil, fmt.Errorf("%w: a %s subscription cannot move to a %s package", ErrInvalidPackageChange, current.Type, target.Type)
+	case target.Currency != subscription.Currency:
+		return nil, nil, fmt.Errorf("%w: package %s is priced in %s, the subscription in %s", ErrInvalidPackageChange, target.Name, target.Currency, subscription.Currency)
+	}
+	return current, target, nil
+}
+
+func (s *PackageChangeService) supersedeScheduled(ctx context.Context, subscriptionID string) error {
+	scheduled, err := s.subscriptionRepo.GetScheduledPackageChange(ctx, subscriptionID)
+	if err != nil || scheduled == nil {
+		return err
+	}
+	scheduled.Status = models.PackageChangeSuperseded
+	return s.subscriptionRepo.UpdatePackageChange(ctx, scheduled)
+}
+
+// GetPackageHistory returns the package changes of the subscription, newest first
+func (s *PackageChangeService) GetPackageHistory(ctx context.Context, subscriptionID string) ([]models.SubscriptionPackageChange, error) {
+	if _, err := s.subscriptionRepo.GetSubscription(ctx, subscriptionID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, err
+	}
+	return s.subscriptionRepo.GetPackageChanges(ctx, subscriptionID)
+}
+
+// applyScheduledPackageChanges puts into effect the changes scheduled for cycles starting before
+// the given time, so the billing run invoices those cycles at the new package's price
+func applyScheduledPackageChanges(ctx context.Context, repo repositories.SubscriptionRepository, before time.Time) error {
+	changes, err := repo.GetDuePackageChanges(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	for i := range changes {
+		change := &changes[i]
+		err := db.Transaction(ctx, func(ctx context.Context) error {
+			subscription, err := repo.GetSubscriptionForUpdate(ctx, change.SubscriptionID)
+			if err != nil {
+				return err
+			}
+			subscription.PackageID = change.ToPackageID
+			subscription.PackagePrice = change.ToPrice
+			if err := repo.UpdateSubscription(ctx, subscription); err != nil {
+				return err
+			}
+
+			now := time.Now()
+			change.Status = models.PackageChangeApplied
+			change.AppliedAt = &now
+			return repo.UpdatePackageChange(ctx, change)
+		})
+		if err != nil {
+			logger.Error("Failed to apply scheduled package change", zap.Error(err), zap.String("subscriptionID", change.SubscriptionID))
+			return err
+		}
+		logger.Info("Scheduled package change applied", zap.String("subscriptionID", change.SubscriptionID), zap.String("packageID", change.ToPackageID))
+	}
+	return nil
+}
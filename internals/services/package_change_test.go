@@ -0,0 +1,28 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestProration(t *testing.T) {
+	renewal := time.Date(2026, 12, 1, 0, 0, 0, 0, time.Local)
+
+	p := newProration(renewal, time.Date(2026, 11, 19, 15, 30, 0, 0, time.Local))
+	assert.Equal(t, proration{Days: 12, CycleDays: 30}, p)
+	assert.Equal(t, money.FromMajor(320), p.of(money.FromMajor(800)))
+	assert.Equal(t, money.FromMajor(480), p.of(money.FromMajor(1200)))
+
+	p = newProration(renewal, time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local))
+	assert.Equal(t, money.FromMajor(800), p.of(money.FromMajor(800)), "a change on the first day covers the whole cycle")
+
+	p = newProration(renewal, time.Date(2026, 12, 5, 0, 0, 0, 0, time.Local))
+	assert.Equal(t, 0, p.Days, "a renewal date already past leaves nothing to prorate")
+	assert.Equal(t, money.Amount(0), p.of(money.FromMajor(800)))
+
+	p = newProration(renewal, time.Date(2026, 9, 1, 0, 0, 0, 0, time.Local))
+	assert.Equal(t, 30, p.Days, "never more than one cycle")
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvoiceNotFound    = errors.New("invoice not found")
+	ErrInvoiceAlreadyPaid = errors.New("invoice is already paid")
+	ErrCurrencyMismatch   = errors.New("payment currency does not match the invoice currency")
+	ErrInvalidPayment     = errors.New("invalid payment")
+
+	ErrPaymentNotFound        = errors.New("payment not found")
+	ErrPaymentAlreadyReversed = errors.New("payment has already been reversed")
+	ErrCreditAlreadyUsed      = errors.New("the credit kept from this payment has already been used")
+)
+
+// PaymentPosting is a posted payment with the invoices it settled and the excess kept as credit
+type PaymentPosting struct {
+	models.Payment
+	Allocations []models.PaymentAllocation `json:"allocations"`
+	Credit      money.Amount               `json:"credit"`
+}
+
+// PaymentReversal is a reversal with the invoice allocations and the credit it took back
+type PaymentReversal struct {
+	models.Payment
+	Allocations []models.PaymentAllocation `json:"allocations"`
+	Credit      money.Amount               `json:"credit"`
+}
+
+type PaymentService struct {
+	paymentRepo repositories.PaymentRepository
+	invoiceRepo repositories.InvoiceRepository
+	creditRepo  repositories.CustomerCreditRepository
+	settlement  settlement
+	ledger      ledger
+}
+
+func NewPaymentService(
+	pr repositories.PaymentRepository,
+	ir repositories.InvoiceRepository,
+	sr repositories.SubscriptionRepository,
+	cr repositories.CustomerCreditRepository,
+	lr repositories.LedgerRepository) *PaymentService {
+	return &PaymentService{
+		paymentRepo: pr,
+		invoiceRepo: ir,
+		creditRepo:  cr,
+		settlement:  settlement{invoiceRepo: ir, subscriptionRepo: sr, creditRepo: cr},
+		ledger:      ledger{repo: lr},
+	}
+}
+
+// PostPayment records the payment and allocates it in one transaction: first to the invoice it
+// names, if any, then to the customer's other open invoices oldest first. Whatever is left over
+// is kept as customer credit. The invoice rows are locked first, so concurrent postings for the
+// same customer are serialized and a failure at any step leaves nothing behind.
+func (s *PaymentService) PostPayment(ctx context.Context, payment *models.Payment) (*PaymentPosting, error) {
+	posting := &PaymentPosting{}
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		invoices, err := s.invoicesToSettle(ctx, payment)
+		if err != nil {
+			return err
+		}
+		if err := s.creditRepo.LockCustomerCredit(ctx, *payment.CustomerID); err != nil {
+			return err
+		}
+
+		if err := s.paymentRepo.CreatePayment(ctx, payment); err != nil {
+			logger.Error("Failed to create payment", zap.Error(err))
+			return err
+		}
+		if err := s.ledger.postPayment(ctx, payment); err != nil {
+			return err
+		}
+
+		allocations, excess := allocateFIFO(payment.Amount, invoices)
+		for _, a := range allocations {
+			if err := s.settlement.settle(ctx, a.Invoice, a.Amount, payment.PaidAt); err != nil {
+				logger.Error("Failed to settle invoice", zap.Error(err), zap.String("invoiceID", a.Invoice.ID))
+				return err
+			}
+			posting.Allocations = append(posting.Allocations, models.PaymentAllocation{
+				ID:        uuid.New().String(),
+				PaymentID: payment.ID,
+				InvoiceID: a.Invoice.ID,
+				Amount:    a.Amount,
+			})
+		}
+		if err := s.paymentRepo.CreatePaymentAllocations(ctx, posting.Allocations); err != nil {
+			logger.Error("Failed to create payment allocations", zap.Error(err))
+			return err
+		}
+
+		if excess > 0 {
+			paymentID := payment.ID
+			credit := models.CustomerCredit{
+				ID:          uuid.New().String(),
+				CustomerID:  *payment.CustomerID,
+				Amount:      excess,
+				Currency:    payment.Currency,
+				PaymentID:   &paymentID,
+				Description: "Overpayment kept as credit",
+			}
+			if err := s.creditRepo.CreateCreditEntry(ctx, &credit); err != nil {
+				logger.Error("Failed to record customer credit", zap.Error(err))
+				return err
+			}
+			posting.Credit = excess
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	posting.Payment = *payment
+	return posting, nil
+}
+
+// ReversePayment undoes an incoming payment, for a refund or a cashier's mistake. It records a
+// linked negative payment and takes back every allocation the payment made: invoices it paid off
+// are reopened and the subscriptions they renewed are rolled back. Credit kept from the payment
+// is withdrawn as well, which fails if the customer has already used it.
+func (s *PaymentService) ReversePayment(ctx context.Context, id, reason string) (*PaymentReversal, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidPayment)
+	}
+
+	result := &PaymentReversal{}
+	reversal := &models.Payment{}
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		original, err := s.paymentRepo.GetPaymentForUpdate(ctx, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPaymentNotFound
+			}
+			return err
+		}
+		if original.ReversedAt != nil {
+			return ErrPaymentAlreadyReversed
+		}
+		if original.Type != models.PaymentIncoming || original.Amount <= 0 {
+			return fmt.Errorf("%w: only incoming payments can be reversed", ErrInvalidPayment)
+		}
+
+		allocations, err := s.paymentRepo.GetAllocationsByPaymentID(ctx, original.ID)
+		if err != nil {
+			return err
+		}
+		invoices := make([]*models.Invoice, len(allocations))
+		for i, a := range allocations {
+			if invoices[i], err = s.invoiceRepo.GetInvoiceForUpdate(ctx, a.InvoiceID); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		*reversal = models.Payment{
+			ID:          uuid.New().String(),
+			InvoiceID:   original.InvoiceID,
+			CustomerID:  original.CustomerID,
+			Amount:      -original.Amount,
+			Currency:    original.Currency,
+			Type:        models.PaymentReversal,
+			Description: "Reversal of payment " + original.ID,
+			ReversalOf:  &original.ID,
+			Reason:      &reason,
+			PaidAt:      now,
+		}
+		if reversal.CustomerID == nil && len(invoices) > 0 {
+			reversal.CustomerID = &invoices[0].CustomerID
+		}
+		if reversal.CustomerID == nil {
+			return fmt.Errorf("%w: the payment has no customer", ErrInvalidPayment)
+		}
+
+		if result.Credit, err = s.withdrawCredit(ctx, original, reversal); err != nil {
+			return err
+		}
+		if err := s.paymentRepo.CreatePayment(ctx, reversal); err != nil {
+			logger.Error("Failed to create payment reversal", zap.Error(err))
+			return err
+		}
+		if err := s.ledger.postPaymentReversal(ctx, reversal); err != nil {
+			return err
+		}
+
+		for i, a := range allocations {
+			if err := s.settlement.unsettle(ctx, invoices[i], a.Amount); err != nil {
+				logger.Error("Failed to reopen invoice", zap.Error(err), zap.String("invoiceID", a.InvoiceID))
+				return err
+			}
+			result.Allocations = append(result.Allocations, models.PaymentAllocation{
+				ID:        uuid.New().String(),
+				PaymentID: reversal.ID,
+				InvoiceID: a.InvoiceID,
+				Amount:    -a.Amount,
+			})
+		}
+		if err := s.paymentRepo.CreatePaymentAllocations(ctx, result.Allocations); err != nil {
+			logger.Error("Failed to create payment allocations", zap.Error(err))
+			return err
+		}
+		if result.Credit > 0 {
+			credit := models.CustomerCredit{
+				ID:          uuid.New().String(),
+				CustomerID:  *reversal.CustomerID,
+				Amount:      -result.Credit,
+				Currency:    reversal.Currency,
+				PaymentID:   &reversal.ID,
+				Description: "Credit withdrawn by payment reversal",
+			}
+			if err := s.creditRepo.CreateCreditEntry(ctx, &credit); err != nil {
+				logger.Error("Failed to withdraw customer credit", zap.Error(err))
+				return err
+			}
+		}
+
+		original.ReversedAt = &now
+		return s.paymentRepo.UpdatePayment(ctx, original)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Payment reversed", zap.String("paymentID", id), zap.String("reversalID", reversal.ID))
+	result.Payment = *reversal
+	return result, nil
+}
+
+// withdrawCredit returns how much credit the reversal has to take back from the customer,
+// checking that the credit kept from the original payment is still available
+func (s *PaymentService) withdrawCredit(ctx context.Context, original, reversal *models.Payment) (money.Amount, error) {
+	kept, err := s.creditRepo.GetCreditFromPayment(ctx, original.ID)
+	if err != nil || kept <= 0 {
+		return 0, err
+	}
+	if err := s.creditRepo.LockCustomerCredit(ctx, *reversal.CustomerID); err != nil {
+		return 0, err
+	}
+	available, err := s.creditRepo.GetCreditBalance(ctx, *reversal.CustomerID, reversal.Currency)
+	if err != nil {
+		return 0, err
+	}
+	if available < kept {
+		return 0, ErrCreditAlreadyUsed
+	}
+	return kept, nil
+}
+
+// invoicesToSettle locks and orders the invoices the payment may settle, filling in the
+// payment's customer from its invoice when needed
+func (s *PaymentService) invoicesToSettle(ctx context.Context, payment *models.Payment) ([]*models.Invoice, error) {
+	var invoices []*models.Invoice
+	if payment.InvoiceID != nil {
+		invoice, err := s.lockPayableInvoice(ctx, *payment.InvoiceID, payment.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if payment.CustomerID == nil {
+			payment.CustomerID = &invoice.CustomerID
+		} else if *payment.CustomerID != invoice.CustomerID {
+			return nil, fmt.Errorf("%w: the invoice belongs to a different customer", ErrInvalidPayment)
+		}
+		invoices = append(invoices, invoice)
+	}
+	if payment.CustomerID == nil {
+		return nil, fmt.Errorf("%w: customerId or invoiceId is required", ErrInvalidPayment)
+	}
+
+	open, err := s.invoiceRepo.GetOpenInvoicesForUpdate(ctx, *payment.CustomerID)
+	if err != nil {
+		logger.Error("Failed to get open invoices", zap.Error(err))
+		return nil, err
+	}
+	for i := range open {
+		invoice := &open[i]
+		if invoice.Currency != payment.Currency || (payment.InvoiceID != nil && invoice.ID == *payment.InvoiceID) {
+			continue
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices, nil
+}
+
+func (s *PaymentService) lockPayableInvoice(ctx context.Context, id string, currency money.Currency) (*models.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetInvoiceForUpdate(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvoiceNotFound
+		}
+		logger.Error("Failed to get invoice", zap.Error(err))
+		return nil, err
+	}
+
+	if invoice.Status == models.InvoicePaid {
+		return nil, ErrInvoiceAlreadyPaid
+	}
+	if !invoice.Status.IsOpen() {
+		return nil, ErrInvoiceNotOpen
+	}
+	if invoice.Currency != currency {
+		return nil, ErrCurrencyMismatch
+	}
+	return invoice, nil
+}
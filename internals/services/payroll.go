@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+type PayrollRunResult struct {
+	Period  string       `json:"period"`
+	Paid    int          `json:"paid"`
+	Skipped int          `json:"skipped"`
+	Failed  int          `json:"failed"`
+	Total   money.Amount `json:"total"`
+}
+
+type PayrollService struct {
+	employeeRepo repositories.EmployeeRepository
+}
+
+func NewPayrollService(er repositories.EmployeeRepository) *PayrollService {
+	return &PayrollService{
+		employeeRepo: er,
+	}
+}
+
+// CreateEmployee adds the employee and, with a salary, its first salary history entry effective
+// from the join date, in one transaction
+func (s *PayrollService) CreateEmployee(ctx context.Context, employee *models.Employee, salary money.Amount) error {
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.employeeRepo.CreateEmployee(ctx, employee); err != nil {
+			return err
+		}
+		if salary <= 0 {
+			return nil
+		}
+
+		employee.Salary = salary
+		history := models.SalaryHistory{
+			ID:            uuid.New().String(),
+			EmployeeID:    employee.ID,
+			Salary:        salary,
+			EffectiveFrom: employee.JoinDate,
+			Reason:        "Initial salary",
+			ChangedBy:     auth.ActorID(ctx),
+		}
+		return s.employeeRepo.UpdateSalary(ctx, employee, &history)
+	})
+}
+
+// PayEmployee records one month's salary payout for the employee and books it as an operational
+// expense. It returns nil without error when the employee was already paid for the period.
+func (s *PayrollService) PayEmployee(ctx context.Context, employee *models.Employee, period BillingPeriod) (*models.SalaryPayout, error) {
+	now := time.Now()
+
+	expense := models.Expense{
+		ID:          uuid.New().String(),
+		Amount:      employee.Salary,
+		Currency:    money.DefaultCurrency,
+		Type:        models.OperationalExpense,
+		Description: fmt.Sprintf("Salary for %s (%s)", employee.Name, period),
+		PaidAt:      now,
+	}
+
+	payout := models.SalaryPayout{
+		ID:         uuid.New().String(),
+		EmployeeID: employee.ID,
+		Period:     period.String(),
+		Amount:     employee.Salary,
+		PaidAt:     now,
+		PaidBy:     auth.ActorID(ctx),
+	}
+
+	created, err := s.employeeRepo.CreateSalaryPayout(ctx, &payout, &expense)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, nil
+	}
+	return &payout, nil
+}
+
+// RunPayroll pays every active employee with a salary for the period, skipping those already paid
+func (s *PayrollService) RunPayroll(ctx context.Context, period BillingPeriod) (*PayrollRunResult, error) {
+	result := &PayrollRunResult{Period: period.String()}
+
+	employees, err := s.employeeRepo.GetActiveEmployees(ctx)
+	if err != nil {
+		logger.Error("Failed to get active employees", zap.Error(err))
+		return nil, err
+	}
+
+	for i := range employees {
+		employee := &employees[i]
+		if employee.Salary <= 0 {
+			result.Skipped++
+			continue
+		}
+
+		payout, err := s.PayEmployee(ctx, employee, period)
+		if err != nil {
+			logger.Error("Failed to pay employee", zap.Error(err), zap.String("employeeID", employee.ID), zap.String("period", result.Period))
+			result.Failed++
+			continue
+		}
+		if payout == nil {
+			result.Skipped++
+			continue
+		}
+
+		result.Paid++
+		result.Total += payout.Amount
+	}
+
+	logger.Info("Payroll run completed",
+		zap.String("period", result.Period),
+		zap.Int("paid", result.Paid),
+		zap.Int("skipped", result.Skipped),
+		zap.Int("failed", result.Failed),
+	)
+
+	if result.Failed > 0 {
+		return result, fmt.Errorf("failed to pay %d employee(s) for period %s", result.Failed, result.Period)
+	}
+	return result, nil
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+type allocation struct {
+	Invoice *models.Invoice
+	Amount  money.Amount
+}
+
+// allocateFIFO splits amount over the invoices in the given order, each up to its balance,
+// and returns the part of the amount that no invoice could take
+func allocateFIFO(amount money.Amount, invoices []*models.Invoice) ([]allocation, money.Amount) {
+	var allocations []allocation
+	for _, invoice := range invoices {
+		if amount <= 0 {
+			break
+		}
+		applied := money.Min(amount, invoice.Balance())
+		if applied <= 0 {
+			continue
+		}
+		allocations = append(allocations, allocation{Invoice: invoice, Amount: applied})
+		amount -= applied
+	}
+	return allocations, amount
+}
+
+// settlement applies money to invoices, whether it comes from a payment or from customer credit.
+// Callers are expected to run it inside a transaction holding the invoice row locks.
+type settlement struct {
+	invoiceRepo      repositories.InvoiceRepository
+	subscriptionRepo repositories.SubscriptionRepository
+	creditRepo       repositories.CustomerCreditRepository
+}
+
+// settle records amount as paid on the invoice. Once the invoice is paid off, every subscription
+// it bills is renewed for another month, or up to its renewal date for a prorated charge, and
+// expired ones become active again.
+func (s settlement) settle(ctx context.Context, invoice *models.Invoice, amount money.Amount, paidAt time.Time) error {
+	invoice.PaidAmount += amount
+	if invoice.Balance() > 0 {
+		return s.invoiceRepo.UpdateInvoice(ctx, invoice)
+	}
+
+	invoice.Status = models.InvoicePaid
+	invoice.PaidDate = &paidAt
+	if err := s.invoiceRepo.UpdateInvoice(ctx, invoice); err != nil {
+		return err
+	}
+
+	if invoice.Lines == nil {
+		lines, err := s.invoiceRepo.GetInvoiceLines(ctx, invoice.ID)
+		if err != nil {
+			return err
+		}
+		invoice.Lines = lines
+	}
+
+	for _, charge := range subscriptionCharges(invoice) {
+		if err := s.renewSubscription(ctx, invoice.ID, charge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s settlement) renewSubscription(ctx context.Context, invoiceID string, charge subscriptionCharge) error {
+	subscription, err := s.subscriptionRepo.GetSubscriptionForUpdate(ctx, charge.SubscriptionID)
+	if err != nil {
+		logger.Error("Failed to get subscription", zap.Error(err), zap.String("subscriptionID", charge.SubscriptionID))
+		return err
+	}
+
+	renewal := models.SubscriptionRenewal{
+		ID:                  uuid.New().String(),
+		SubscriptionID:      subscription.ID,
+		InvoiceID:           invoiceID,
+		PreviousPaidUntil:   subscription.PaidUntil,
+		PreviousRenewalDate: subscription.RenewalDate,
+		PreviousDueAmount:   subscription.DueAmount,
+	}
+
+	subscription.DueAmount = money.Max(subscription.DueAmount-charge.Amount, 0)
+	if charge.Prorated {
+		subscription.PaidUntil = subscription.RenewalDate
+	} else {
+		subscription.PaidUntil = addMonths(subscription.PaidUntil, 1)
+	}
+	subscription.RenewalDate = FirstDayOfNextMonth(subscription.PaidUntil)
+
+	if subscription.Status == models.SubscriptionExpired {
+		_, err = transitionSubscription(ctx, s.subscriptionRepo, subscription, models.SubscriptionActive, "Renewed by payment of invoice "+invoiceID)
+	} else {
+		err = s.subscriptionRepo.UpdateSubscription(ctx, subscription)
+	}
+	if err != nil {
+		logger.Error("Failed to update subscription", zap.Error(err), zap.String("subscriptionID", charge.SubscriptionID))
+		return err
+	}
+
+	renewal.PaidUntil = subscription.PaidUntil
+	return s.subscriptionRepo.CreateSubscriptionRenewal(ctx, &renewal)
+}
+
+// unsettle takes amount back off the invoice. A paid invoice is reopened as pending and the
+// month each of its subscriptions was renewed for is taken back, with the charge owed again.
+func (s settlement) unsettle(ctx context.Context, invoice *models.Invoice, amount money.Amount) error {
+	wasPaid := invoice.Status == models.InvoicePaid
+	invoice.PaidAmount = money.Max(invoice.PaidAmount-amount, 0)
+	if !wasPaid {
+		return s.invoiceRepo.UpdateInvoice(ctx, invoice)
+	}
+
+	invoice.Status = models.InvoicePending
+	invoice.PaidDate = nil
+	if err := s.invoiceRepo.UpdateInvoice(ctx, invoice); err != nil {
+		return err
+	}
+
+	if invoice.Lines == nil {
+		lines, err := s.invoiceRepo.GetInvoiceLines(ctx, invoice.ID)
+		if err != nil {
+			return err
+		}
+		invoice.Lines = lines
+	}
+
+	for _, charge := range subscriptionCharges(invoice) {
+		if err := s.revokeRenewal(ctx, invoice.ID, charge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeRenewal undoes renewSubscription. The subscription gets its recorded values back unless
+// a later payment has renewed it since, in which case only this invoice's month and charge are
+// taken back; a prorated charge only becomes owed again. The expiry job deals with a subscription
+// left unpaid past its renewal date.
+func (s settlement) revokeRenewal(ctx context.Context, invoiceID string, charge subscriptionCharge) error {
+	subscription, err := s.subscriptionRepo.GetSubscriptionForUpdate(ctx, charge.SubscriptionID)
+	if err != nil {
+		logger.Error("Failed to get subscription", zap.Error(err), zap.String("subscriptionID", charge.SubscriptionID))
+		return err
+	}
+
+	renewal, err := s.subscriptionRepo.GetSubscriptionRenewal(ctx, invoiceID, subscription.ID)
+	if err != nil {
+		return err
+	}
+	if renewal != nil && renewal.PaidUntil.Equal(subscription.PaidUntil) {
+		subscription.PaidUntil = renewal.PreviousPaidUntil
+		subscription.RenewalDate = renewal.PreviousRenewalDate
+		subscription.DueAmount = renewal.PreviousDueAmount
+	} else if charge.Prorated {
+		subscription.DueAmount += money.Max(charge.Amount, 0)
+	} else {
+		subscription.DueAmount += money.Max(charge.Amount, 0)
+		subscription.PaidUntil = addMonths(subscription.PaidUntil, -1)
+		subscription.RenewalDate = FirstDayOfNextMonth(subscription.PaidUntil)
+	}
+	if renewal != nil {
+		if err := s.subscriptionRepo.DeleteSubscriptionRenewal(ctx, renewal.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.subscriptionRepo.UpdateSubscription(ctx, subscription); err != nil {
+		logger.Error("Failed to update subscription", zap.Error(err), zap.String("subscriptionID", charge.SubscriptionID))
+		return err
+	}
+	return nil
+}
+
+// applyCredit settles as much of the invoice as the customer's available credit covers
+// and returns the amount of credit used
+func (s settlement) applyCredit(ctx context.Context, invoice *models.Invoice) (money.Amount, error) {
+	if invoice.Balance() <= 0 {
+		return 0, nil
+	}
+	if err := s.creditRepo.LockCustomerCredit(ctx, invoice.CustomerID); err != nil {
+		return 0, err
+	}
+
+	available, err := s.creditRepo.GetCreditBalance(ctx, invoice.CustomerID, invoice.Currency)
+	if err != nil {
+		return 0, err
+	}
+
+	used := money.Min(available, invoice.Balance())
+	if used <= 0 {
+		return 0, nil
+	}
+
+	invoiceID := invoice.ID
+	entry := models.CustomerCredit{
+		ID:          uuid.New().String(),
+		CustomerID:  invoice.CustomerID,
+		Amount:      -used,
+		Currency:    invoice.Currency,
+		InvoiceID:   &invoiceID,
+		Description: "Credit applied to invoice",
+	}
+	if err := s.creditRepo.CreateCreditEntry(ctx, &entry); err != nil {
+		return 0, err
+	}
+
+	if err := s.settle(ctx, invoice, used, time.Now()); err != nil {
+		return 0, err
+	}
+
+	logger.Info("Customer credit applied", zap.String("invoiceID", invoice.ID), zap.Stringer("amount", used))
+	return used, nil
+}
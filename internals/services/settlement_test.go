@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestAllocateFIFO(t *testing.T) {
+	newInvoices := func() []*models.Invoice {
+		return []*models.Invoice{
+			{ID: "sep", Amount: money.FromMajor(800), PaidAmount: money.FromMajor(300)},
+			{ID: "oct", Amount: money.FromMajor(800)},
+			{ID: "nov", Amount: money.FromMajor(800)},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		amount   money.Amount
+		expected map[string]money.Amount
+		excess   money.Amount
+	}{
+		{"partial first invoice", money.FromMajor(200), map[string]money.Amount{"sep": money.FromMajor(200)}, 0},
+		{"oldest first", money.FromMajor(1000), map[string]money.Amount{"sep": money.FromMajor(500), "oct": money.FromMajor(500)}, 0},
+		{"everything owed", money.FromMajor(2100), map[string]money.Amount{"sep": money.FromMajor(500), "oct": money.FromMajor(800), "nov": money.FromMajor(800)}, 0},
+		{"overpayment", money.FromMajor(2500), map[string]money.Amount{"sep": money.FromMajor(500), "oct": money.FromMajor(800), "nov": money.FromMajor(800)}, money.FromMajor(400)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			allocations, excess := allocateFIFO(tc.amount, newInvoices())
+
+			applied := make(map[string]money.Amount)
+			for _, a := range allocations {
+				applied[a.Invoice.ID] = a.Amount
+			}
+			assert.Equal(t, tc.expected, applied)
+			assert.Equal(t, tc.excess, excess)
+		})
+	}
+
+	allocations, excess := allocateFIFO(money.FromMajor(100), nil)
+	assert.Empty(t, allocations)
+	assert.Equal(t, money.FromMajor(100), excess, "with nothing owed the whole payment becomes credit")
+}
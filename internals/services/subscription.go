@@ -0,0 +1,432 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/internals/repositories"
+	"github.com/timam/uttarawave-backend/pkg/auth"
+	"github.com/timam/uttarawave-backend/pkg/db"
+	"github.com/timam/uttarawave-backend/pkg/logger"
+	"github.com/timam/uttarawave-backend/pkg/money"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrInvalidTransition    = errors.New("invalid subscription status transition")
+	ErrReasonRequired       = errors.New("a reason is required")
+	ErrSubscriptionInUse    = errors.New("the subscription cannot be deleted")
+)
+
+// SubscriptionTransition is a subscription after a status change, with the recorded change
+type SubscriptionTransition struct {
+	Subscription *models.Subscription             `json:"subscription"`
+	Change       *models.SubscriptionStatusChange `json:"change"`
+}
+
+// SubscriptionRequest is what staff enter to sign a customer up for a package
+type SubscriptionRequest struct {
+	CustomerID      string
+	Type            models.SubscriptionType
+	PackageID       string
+	DeviceID        string
+	MonthlyDiscount money.Amount
+}
+
+// NewSubscription is a created subscription with its first invoice
+type NewSubscription struct {
+	Subscription *models.Subscription `json:"subscription"`
+	Invoice      *models.Invoice      `json:"invoice"`
+}
+
+type SubscriptionService struct {
+	subscriptionRepo repositories.SubscriptionRepository
+	packageRepo      repositories.PackageRepository
+	customerRepo     repositories.CustomerRepository
+	deviceRepo       repositories.DeviceRepository
+	invoiceRepo      repositories.InvoiceRepository
+	inventory        inventory
+	settlement       settlement
+	ledger           ledger
+}
+
+func NewSubscriptionService(
+	sr repositories.SubscriptionRepository,
+	pkr repositories.PackageRepository,
+	cr repositories.CustomerRepository,
+	dr repositories.DeviceRepository,
+	ir repositories.InvoiceRepository,
+	ccr repositories.CustomerCreditRepository,
+	lr repositories.LedgerRepository) *SubscriptionService {
+	return &SubscriptionService{
+		subscriptionRepo: sr,
+		packageRepo:      pkr,
+		customerRepo:     cr,
+		deviceRepo:       dr,
+		invoiceRepo:      ir,
+		inventory:        inventory{repo: dr},
+		settlement:       settlement{invoiceRepo: ir, subscriptionRepo: sr, creditRepo: ccr},
+		ledger:           ledger{repo: lr},
+	}
+}
+
+// CreateSubscription signs the customer up once the request passes validation, reporting every
+// problem found as a ValidationError. The subscription is created, its device assigned to it and
+// its first invoice issued in one transaction, so a failure at any step leaves nothing behind.
+func (s *SubscriptionService) CreateSubscription(ctx context.Context, request SubscriptionRequest) (*NewSubscription, error) {
+	result := &NewSubscription{}
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		pkg, device, err := s.validateRequest(ctx, request)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		subscription := &models.Subscription{
+			ID:              uuid.New().String(),
+			CustomerID:      request.CustomerID,
+			Type:            request.Type,
+			PackageID:       pkg.ID,
+			PackagePrice:    pkg.Price,
+			MonthlyDiscount: request.MonthlyDiscount,
+			Currency:        pkg.Currency,
+			Status:          models.SubscriptionActive,
+			StartDate:       now,
+			RenewalDate:     FirstDayOfNextMonth(now),
+			PaidUntil:       now,
+		}
+		if subscription.Currency == "" {
+			subscription.Currency = money.DefaultCurrency
+		}
+		if device != nil {
+			subscription.DeviceID = device.ID
+		}
+
+		lines := firstInvoiceLines(subscription, pkg.Name)
+		subscription.DueAmount = InvoiceTotal(lines)
+		if err := s.subscriptionRepo.CreateSubscription(ctx, subscription); err != nil {
+			return err
+		}
+		if device != nil {
+			if err := s.inventory.assign(ctx, device.ID, "Subscription", subscription.ID, "Subscription created"); err != nil {
+				return fmt.Errorf("failed to assign device: %w", err)
+			}
+		}
+
+		invoice, err := s.firstInvoice(ctx, subscription, lines)
+		if err != nil {
+			return err
+		}
+		// Customer credit may have paid the invoice off, renewing the subscription already
+		if invoice.Status == models.InvoicePaid {
+			if subscription, err = s.subscriptionRepo.GetSubscription(ctx, subscription.ID); err != nil {
+				return err
+			}
+		}
+		result.Subscription, result.Invoice = subscription, invoice
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Subscription created",
+		zap.String("subscriptionID", result.Subscription.ID),
+		zap.String("customerID", result.Subscription.CustomerID),
+		zap.String("invoiceID", result.Invoice.ID),
+	)
+	return result, nil
+}
+
+// validateRequest checks the customer, package and device the request refers to. The device row
+// stays locked until the transaction ends, so two sign-ups cannot take the same device.
+func (s *SubscriptionService) validateRequest(ctx context.Context, request SubscriptionRequest) (*models.Package, *models.Device, error) {
+	validation := &ValidationError{}
+
+	if request.CustomerID == "" {
+		validation.add("customerId", "is required")
+	} else {
+		customer, err := s.customerRepo.GetCustomer(request.CustomerID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if customer == nil {
+			validation.add("customerId", "customer not found")
+		}
+	}
+
+	if request.Type == "" {
+		validation.add("type", "is required")
+	} else if !request.Type.IsValid() {
+		validation.add("type", fmt.Sprintf("must be %s or %s", models.Internet, models.CableTV))
+	}
+
+	var pkg *models.Package
+	if request.PackageID == "" {
+		validation.add("packageId", "is required")
+	} else {
+		found, err := s.packageRepo.GetPackageByID(ctx, request.PackageID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			validation.add("packageId", "package not found")
+		case err != nil:
+			return nil, nil, err
+		case !found.IsActive:
+			validation.add("packageId", "package is not active")
+		case request.Type.IsValid() && string(found.Type) != string(request.Type):
+			validation.add("packageId", fmt.Sprintf("package is for %s, not %s", found.Type, request.Type))
+		default:
+			pkg = found
+		}
+	}
+
+	if request.MonthlyDiscount < 0 {
+		validation.add("monthlyDiscount", "cannot be negative")
+	} else if pkg != nil && request.MonthlyDiscount > pkg.Price {
+		validation.add("monthlyDiscount", "cannot exceed the package price")
+	}
+
+	var device *models.Device
+	if request.DeviceID != "" {
+		found, err := s.deviceRepo.GetDeviceForUpdate(ctx, request.DeviceID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			validation.add("deviceId", "device not found")
+		case err != nil:
+			return nil, nil, err
+		case found.Status != models.InStock:
+			validation.add("deviceId", fmt.Sprintf("device is %s, not in stock", found.Status))
+		default:
+			device = found
+		}
+	}
+
+	if err := validation.err(); err != nil {
+		return nil, nil, err
+	}
+	return pkg, device, nil
+}
+
+// firstInvoice bills the days from the start date until the first renewal. It stands in for the
+// subscription's charge in the billing period of that renewal, so the billing run does not bill
+// those days again, and paying it pays the subscription up to the renewal date.
+func (s *SubscriptionService) firstInvoice(ctx context.Context, subscription *models.Subscription, lines []models.InvoiceLine) (*models.Invoice, error) {
+	subscriptionID := subscription.ID
+	billingPeriod := NewBillingPeriod(subscription.RenewalDate).String()
+	invoice := &models.Invoice{
+		ID:             uuid.New().String(),
+		CustomerID:     subscription.CustomerID,
+		SubscriptionID: &subscriptionID,
+		Currency:       subscription.Currency,
+		Status:         models.InvoicePending,
+		DueDate:        subscription.RenewalDate,
+		BillingPeriod:  &billingPeriod,
+	}
+	if err := SetInvoiceLines(invoice, lines); err != nil {
+		return nil, err
+	}
+	if err := s.invoiceRepo.CreateInvoice(ctx, invoice); err != nil {
+		return nil, err
+	}
+	if err := s.ledger.postInvoice(ctx, invoice); err != nil {
+		return nil, err
+	}
+	if _, err := s.settlement.applyCredit(ctx, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// firstInvoiceLines prorates the monthly price, and any discount, over the days left until the
+// subscription first renews
+func firstInvoiceLines(subscription *models.Subscription, packageName string) []models.InvoiceLine {
+	days := newProration(subscription.RenewalDate, subscription.StartDate)
+	label := fmt.Sprintf("%s to %s, %d of %d days",
+		subscription.StartDate.Format("2006-01-02"),
+		subscription.RenewalDate.AddDate(0, 0, -1).Format("2006-01-02"),
+		days.Days, days.CycleDays)
+
+	subscriptionID := subscription.ID
+	price := getMonthlyPrice(subscription)
+	lines := []models.InvoiceLine{{
+		Type:           models.LineProration,
+		Description:    fmt.Sprintf("%s subscription (%s)", packageName, label),
+		SubscriptionID: &subscriptionID,
+		Quantity:       1,
+		UnitPrice:      days.of(price),
+	}}
+	if discount := days.of(money.Min(subscription.MonthlyDiscount, price)); discount > 0 {
+		lines = append(lines, models.InvoiceLine{
+			Type:           models.LineProration,
+			Description:    fmt.Sprintf("Monthly discount (%s)", label),
+			SubscriptionID: &subscriptionID,
+			Quantity:       1,
+			UnitPrice:      -discount,
+		})
+	}
+	return lines
+}
+
+// Activate switches on a signed-up subscription once it is connected
+func (s *SubscriptionService) Activate(ctx context.Context, id, reason string) (*SubscriptionTransition, error) {
+	return s.changeStatus(ctx, id, models.SubscriptionActive, reason, models.SubscriptionStatus.CanActivate, nil)
+}
+
+// Suspend switches an active subscription off until it is resumed
+func (s *SubscriptionService) Suspend(ctx context.Context, id, reason string) (*SubscriptionTransition, error) {
+	return s.changeStatus(ctx, id, models.SubscriptionSuspended, reason, models.SubscriptionStatus.CanSuspend, nil)
+}
+
+// Resume switches a suspended subscription back on
+func (s *SubscriptionService) Resume(ctx context.Context, id, reason string) (*SubscriptionTransition, error) {
+	return s.changeStatus(ctx, id, models.SubscriptionActive, reason, models.SubscriptionStatus.CanResume, nil)
+}
+
+// Cancel ends a subscription for good, unless it is reactivated later
+func (s *SubscriptionService) Cancel(ctx context.Context, id, reason string) (*SubscriptionTransition, error) {
+	return s.changeStatus(ctx, id, models.SubscriptionCancelled, reason, models.SubscriptionStatus.CanCancel, nil)
+}
+
+// Reactivate brings back an expired or cancelled subscription. A renewal date that has already
+// passed moves to today, so the customer gets the usual grace period to settle before it expires again.
+func (s *SubscriptionService) Reactivate(ctx context.Context, id, reason string) (*SubscriptionTransition, error) {
+	return s.changeStatus(ctx, id, models.SubscriptionActive, reason, models.SubscriptionStatus.CanReactivate,
+		func(subscription *models.Subscription) {
+			today := startOfDay(time.Now())
+			if subscription.RenewalDate.Before(today) {
+				subscription.RenewalDate = today
+			}
+		})
+}
+
+// DeleteSubscription deletes a subscription entered by mistake. One with a device assigned or
+// any invoice has a history to keep and is cancelled instead.
+func (s *SubscriptionService) DeleteSubscription(ctx context.Context, id string) error {
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := s.subscriptionRepo.GetSubscriptionForUpdate(ctx, id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSubscriptionNotFound
+			}
+			return err
+		}
+
+		device, err := s.deviceRepo.GetDeviceByAssignment(ctx, "Subscription", id)
+		if err != nil {
+			return err
+		}
+		if device != nil {
+			return fmt.Errorf("%w: device %s is still assigned to it, cancel it instead", ErrSubscriptionInUse, device.SerialNumber)
+		}
+		invoices, err := s.invoiceRepo.GetInvoicesBySubscriptionID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(invoices) > 0 {
+			return fmt.Errorf("%w: it has been invoiced, cancel it instead", ErrSubscriptionInUse)
+		}
+		return s.subscriptionRepo.DeleteSubscription(ctx, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Subscription deleted", zap.String("subscriptionID", id))
+	return nil
+}
+
+// GetStatusHistory returns the status changes of the subscription, oldest first
+func (s *SubscriptionService) GetStatusHistory(ctx context.Context, id string) ([]models.SubscriptionStatusChange, error) {
+	if _, err := s.subscriptionRepo.GetSubscription(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, err
+	}
+	return s.subscriptionRepo.GetSubscriptionStatusChanges(ctx, id)
+}
+
+func (s *SubscriptionService) changeStatus(
+	ctx context.Context,
+	id string,
+	to models.SubscriptionStatus,
+	reason string,
+	guard func(models.SubscriptionStatus) bool,
+	apply func(*models.Subscription)) (*SubscriptionTransition, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	result := &SubscriptionTransition{}
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		subscription, err := s.subscriptionRepo.GetSubscriptionForUpdate(ctx, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSubscriptionNotFound
+			}
+			return err
+		}
+		if !guard(subscription.Status) {
+			return fmt.Errorf("%w: cannot move from %s to %s", ErrInvalidTransition, subscription.Status, to)
+		}
+		if apply != nil {
+			apply(subscription)
+		}
+
+		change, err := transitionSubscription(ctx, s.subscriptionRepo, subscription, to, reason)
+		if err != nil {
+			return err
+		}
+		result.Subscription, result.Change = subscription, change
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Subscription status changed",
+		zap.String("subscriptionID", id),
+		zap.String("from", string(result.Change.FromStatus)),
+		zap.String("to", string(to)),
+		zap.String("by", result.Change.ChangedBy),
+	)
+	return result, nil
+}
+
+// transitionSubscription moves the subscription to a new status if the transition table allows
+// it, saves it and records who made the change and why. Callers hold the subscription row lock.
+func transitionSubscription(
+	ctx context.Context,
+	repo repositories.SubscriptionRepository,
+	subscription *models.Subscription,
+	to models.SubscriptionStatus,
+	reason string) (*models.SubscriptionStatusChange, error) {
+	from := subscription.Status
+	if !from.CanTransitionTo(to) {
+		return nil, fmt.Errorf("%w: cannot move from %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	subscription.Status = to
+	if err := repo.UpdateSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	change := &models.SubscriptionStatusChange{
+		ID:             uuid.New().String(),
+		SubscriptionID: subscription.ID,
+		FromStatus:     from,
+		ToStatus:       to,
+		Reason:         reason,
+		ChangedBy:      auth.ActorID(ctx),
+		ChangedAt:      time.Now(),
+	}
+	if err := repo.CreateSubscriptionStatusChange(ctx, change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timam/uttarawave-backend/internals/models"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestFirstInvoiceLines(t *testing.T) {
+	start := time.Date(2026, time.October, 17, 15, 30, 0, 0, time.UTC)
+	subscription := &models.Subscription{
+		ID:              "sub-1",
+		PackagePrice:    money.FromMajor(3000),
+		MonthlyDiscount: money.FromMajor(300),
+		StartDate:       start,
+		RenewalDate:     FirstDayOfNextMonth(start),
+	}
+
+	lines := firstInvoiceLines(subscription, "Home 20")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "Home 20 subscription (2026-10-17 to 2026-10-31, 15 of 31 days)", lines[0].Description)
+	assert.Equal(t, money.MustParse("1451.61"), lines[0].UnitPrice)
+	assert.Equal(t, money.MustParse("-145.16"), lines[1].UnitPrice)
+
+	invoice := &models.Invoice{}
+	require.NoError(t, SetInvoiceLines(invoice, lines))
+	assert.Equal(t, money.MustParse("1306.45"), invoice.Amount)
+	assert.Equal(t, []subscriptionCharge{{SubscriptionID: "sub-1", Amount: invoice.Amount, Prorated: true}}, subscriptionCharges(invoice))
+
+	subscription.StartDate = time.Date(2026, time.November, 1, 9, 0, 0, 0, time.UTC)
+	subscription.RenewalDate = FirstDayOfNextMonth(subscription.StartDate)
+	subscription.MonthlyDiscount = 0
+	lines = firstInvoiceLines(subscription, "Home 20")
+	require.Len(t, lines, 1, "no discount line without a discount")
+	assert.Equal(t, money.FromMajor(3000), lines[0].UnitPrice, "starting on the first bills the whole month")
+}
+
+func TestValidationError(t *testing.T) {
+	validation := &ValidationError{}
+	assert.NoError(t, validation.err())
+
+	validation.add("customerId", "customer not found")
+	validation.add("deviceId", "device is Assigned, not in stock")
+	err := validation.err()
+	require.Error(t, err)
+	assert.Equal(t, "validation failed: customerId: customer not found; deviceId: device is Assigned, not in stock", err.Error())
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/timam/uttarawave-backend/cmd"
 	"github.com/timam/uttarawave-backend/internals/configs"
+	"github.com/timam/uttarawave-backend/internals/jobs"
+	"github.com/timam/uttarawave-backend/internals/migrations"
 	"github.com/timam/uttarawave-backend/pkg/db"
 	"github.com/timam/uttarawave-backend/pkg/logger"
 	"github.com/timam/uttarawave-backend/pkg/metrics"
@@ -46,12 +49,27 @@ func init() {
 }
 
 func serve() error {
+	pending, err := migrations.Pending(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to check database migrations: %w", err)
+	}
+	if len(pending) > 0 {
+		for _, m := range pending {
+			logger.Warn("Pending migration", zap.Int64("version", m.Version), zap.String("name", m.Name))
+		}
+		return fmt.Errorf("database has %d pending migration(s), run `migrate up` before serving", len(pending))
+	}
+
 	serverInstance, err := cmd.InitializeServer()
 	if err != nil {
 		return fmt.Errorf("server initialization failed: %w", err)
 	}
 	logger.Info("Server initialized successfully")
 
+	scheduler := jobs.InitializeJobs()
+	scheduler.Start(context.Background())
+	defer scheduler.Stop()
+
 	errChan := make(chan error, 1)
 	go func() {
 		errChan <- serverInstance.RunServer()
@@ -78,14 +96,8 @@ func serve() error {
 	return nil
 }
 
-func migrate() error {
-	logger.Info("TODO: Implement database migration!!")
-	return nil
-}
-
 func main() {
 	cmd.Serve = serve
-	cmd.Migrate = migrate
 
 	if err := cmd.Execute(); err != nil {
 		logger.Error("Failed to execute", zap.Error(err))
@@ -0,0 +1,24 @@
+package auth
+
+import "context"
+
+type ctxKey struct{}
+
+// WithClaims returns a new context carrying the authenticated employee's claims
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, ctxKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the authenticated employee, if any
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(ctxKey{}).(*Claims)
+	return claims, ok
+}
+
+// ActorID returns the ID of the authenticated employee, or "system" for background work
+func ActorID(ctx context.Context) string {
+	if claims, ok := ClaimsFromContext(ctx); ok && claims.EmployeeID != "" {
+		return claims.EmployeeID
+	}
+	return "system"
+}
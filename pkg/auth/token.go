@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+const defaultTokenTTL = 12 * time.Hour
+
+var ErrSecretNotConfigured = errors.New("jwt secret is not configured, set auth.jwt.secret or JWT_SECRET")
+
+type Claims struct {
+	EmployeeID string `json:"eid"`
+	Name       string `json:"name"`
+	Role       string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+func secret() ([]byte, error) {
+	value := os.Getenv("JWT_SECRET")
+	if value == "" {
+		value = viper.GetString("auth.jwt.secret")
+	}
+	if value == "" {
+		return nil, ErrSecretNotConfigured
+	}
+	return []byte(value), nil
+}
+
+func tokenTTL() time.Duration {
+	ttl := viper.GetDuration("auth.jwt.ttl")
+	if ttl <= 0 {
+		return defaultTokenTTL
+	}
+	return ttl
+}
+
+// GenerateToken issues a signed HS256 token for the employee and returns it with its expiry
+func GenerateToken(employeeID, name, role string) (string, time.Time, error) {
+	key, err := secret()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(tokenTTL())
+	claims := Claims{
+		EmployeeID: employeeID,
+		Name:       name,
+		Role:       role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   employeeID,
+			Issuer:    viper.GetString("server.name"),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// ParseToken verifies the signature and expiry of a token and returns its claims
+func ParseToken(tokenString string) (*Claims, error) {
+	key, err := secret()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
@@ -5,9 +5,7 @@ package db
 import (
 	"fmt"
 	"github.com/spf13/viper"
-	models "github.com/timam/uttarawave-backend/internals/models"
 	"github.com/timam/uttarawave-backend/pkg/logger"
-	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -39,26 +37,6 @@ func InitializePostgreSQL() error {
 		return fmt.Errorf("failed to connect to database: %v", err)
 	}
 
-	// Auto Migrate the schema
-	tables := []interface{}{
-		&models.Address{},
-		&models.Building{},
-		&models.Customer{},
-		&models.Package{},
-		&models.Device{},
-		&models.Subscription{},
-		&models.Payment{},
-		&models.Expense{},
-	}
-
-	for _, table := range tables {
-		if err := DB.AutoMigrate(table); err != nil {
-			logger.Error("Failed to auto migrate schema", zap.Error(err), zap.String("table", fmt.Sprintf("%T", table)))
-			return fmt.Errorf("failed to auto migrate schema for %T: %v", table, err)
-		}
-		logger.Info("Successfully migrated table", zap.String("table", fmt.Sprintf("%T", table)))
-	}
-
 	logger.Info("Successfully connected to PostgreSQL Server")
 	return nil
 }
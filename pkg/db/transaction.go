@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type txKey struct{}
+
+// Conn returns the transaction bound to the context by Transaction,
+// or the shared connection pool when the context carries none
+func Conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return DB.WithContext(ctx)
+}
+
+// InTransaction reports whether the context carries a transaction
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return ok
+}
+
+// Transaction runs fn as a single unit of work. Every repository call made with the context
+// passed to fn joins the transaction, which is committed when fn returns nil and rolled back
+// otherwise. A nested call joins the outer transaction instead of starting a new one.
+func Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if InTransaction(ctx) {
+		return fn(ctx)
+	}
+	return DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// ForUpdate locks the selected rows until the surrounding transaction ends (SELECT ... FOR UPDATE)
+func ForUpdate(ctx context.Context) *gorm.DB {
+	return Conn(ctx).Clauses(clause.Locking{Strength: "UPDATE"})
+}
@@ -0,0 +1,24 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// Fake is the name the fake gateway is registered under
+const Fake = "fake"
+
+// LoadGateways builds the gateways enabled under payments.gateways, keyed by name
+func LoadGateways() (map[string]Gateway, error) {
+	gateways := make(map[string]Gateway)
+
+	if viper.GetBool("payments.gateways.fake.enabled") {
+		secret := viper.GetString("payments.gateways.fake.secret")
+		if secret == "" {
+			return nil, fmt.Errorf("payments.gateways.fake.secret is required")
+		}
+		gateways[Fake] = NewFakeGateway(secret)
+	}
+	return gateways, nil
+}
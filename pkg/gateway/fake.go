@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FakeGateway is an in-memory provider for local development and tests. Checkouts stay
+// pending until Simulate completes or fails them.
+type FakeGateway struct {
+	secret       []byte
+	mu           sync.Mutex
+	transactions map[string]*Transaction
+}
+
+func NewFakeGateway(secret string) *FakeGateway {
+	return &FakeGateway{
+		secret:       []byte(secret),
+		transactions: make(map[string]*Transaction),
+	}
+}
+
+func (g *FakeGateway) CreateCheckout(_ context.Context, req CheckoutRequest) (*Checkout, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be greater than zero")
+	}
+
+	transaction := &Transaction{
+		TransactionID: "FAKE-" + uuid.New().String(),
+		Reference:     req.Reference,
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+		Status:        StatusPending,
+	}
+
+	g.mu.Lock()
+	g.transactions[transaction.TransactionID] = transaction
+	g.mu.Unlock()
+
+	return &Checkout{
+		TransactionID: transaction.TransactionID,
+		RedirectURL:   "fake://checkout/" + transaction.TransactionID,
+	}, nil
+}
+
+func (g *FakeGateway) VerifyWebhook(header http.Header, body []byte) (*Transaction, error) {
+	if !VerifySignature(g.secret, body, header.Get(SignatureHeader)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var transaction Transaction
+	if err := json.Unmarshal(body, &transaction); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if transaction.TransactionID == "" {
+		return nil, fmt.Errorf("%w: transactionId is required", ErrInvalidPayload)
+	}
+	return &transaction, nil
+}
+
+func (g *FakeGateway) QueryStatus(_ context.Context, transactionID string) (*Transaction, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	transaction, ok := g.transactions[transactionID]
+	if !ok {
+		return nil, ErrTransactionNotFound
+	}
+	copied := *transaction
+	return &copied, nil
+}
+
+func (g *FakeGateway) Simulate(transactionID string, status Status) (http.Header, []byte, error) {
+	if !status.IsFinal() {
+		return nil, nil, fmt.Errorf("cannot simulate a %s transaction", status)
+	}
+
+	g.mu.Lock()
+	transaction, ok := g.transactions[transactionID]
+	if ok && transaction.Status == StatusPending {
+		transaction.Status = status
+		if status == StatusCompleted {
+			now := time.Now()
+			transaction.PaidAt = &now
+		}
+	}
+	var copied Transaction
+	if ok {
+		copied = *transaction
+	}
+	g.mu.Unlock()
+
+	if !ok {
+		return nil, nil, ErrTransactionNotFound
+	}
+
+	body, err := json.Marshal(copied)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(SignatureHeader, Sign(g.secret, body))
+	return header, body, nil
+}
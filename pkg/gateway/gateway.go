@@ -0,0 +1,71 @@
+// Package gateway abstracts the mobile wallet providers customers pay through.
+// Each provider implements Gateway; the billing code never talks to a provider directly.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusCompleted Status = "COMPLETED"
+	StatusFailed    Status = "FAILED"
+	StatusCancelled Status = "CANCELLED"
+)
+
+// IsFinal reports whether the provider will not change the transaction any more
+func (s Status) IsFinal() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCancelled
+}
+
+var (
+	ErrInvalidSignature    = errors.New("invalid webhook signature")
+	ErrInvalidPayload      = errors.New("invalid webhook payload")
+	ErrTransactionNotFound = errors.New("gateway transaction not found")
+)
+
+// CheckoutRequest asks the provider to collect Amount; Reference is our own ID for the
+// checkout and comes back in every callback about it
+type CheckoutRequest struct {
+	Reference      string
+	Amount         money.Amount
+	Currency       money.Currency
+	CustomerMobile string
+	CallbackURL    string
+}
+
+// Checkout is a payment session opened at the provider. The customer completes it at RedirectURL.
+type Checkout struct {
+	TransactionID string
+	RedirectURL   string
+}
+
+// Transaction is the provider's view of a checkout
+type Transaction struct {
+	TransactionID string         `json:"transactionId"`
+	Reference     string         `json:"reference"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	Status        Status         `json:"status"`
+	PaidAt        *time.Time     `json:"paidAt,omitempty"`
+}
+
+type Gateway interface {
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error)
+	// VerifyWebhook authenticates a callback from the provider and returns the transaction it reports
+	VerifyWebhook(header http.Header, body []byte) (*Transaction, error)
+	QueryStatus(ctx context.Context, transactionID string) (*Transaction, error)
+}
+
+// Simulator is implemented by gateways that can complete their own checkouts for testing.
+// Simulate returns a signed callback exactly as the provider would deliver it.
+type Simulator interface {
+	Simulate(transactionID string, status Status) (http.Header, []byte, error)
+}
@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timam/uttarawave-backend/pkg/money"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret, body := []byte("secret"), []byte(`{"transactionId":"T1"}`)
+	signature := Sign(secret, body)
+
+	assert.True(t, VerifySignature(secret, body, signature))
+	assert.False(t, VerifySignature(secret, []byte(`{"transactionId":"T2"}`), signature))
+	assert.False(t, VerifySignature([]byte("other"), body, signature))
+	assert.False(t, VerifySignature(secret, body, "not-hex"))
+	assert.False(t, VerifySignature(nil, body, Sign(nil, body)), "an unset secret never verifies")
+}
+
+func TestFakeGateway(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeGateway("secret")
+
+	checkout, err := fake.CreateCheckout(ctx, CheckoutRequest{Reference: "ref-1", Amount: money.FromMajor(500), Currency: money.BDT})
+	require.NoError(t, err)
+
+	pending, err := fake.QueryStatus(ctx, checkout.TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, pending.Status)
+
+	header, body, err := fake.Simulate(checkout.TransactionID, StatusCompleted)
+	require.NoError(t, err)
+
+	transaction, err := fake.VerifyWebhook(header, body)
+	require.NoError(t, err)
+	assert.Equal(t, checkout.TransactionID, transaction.TransactionID)
+	assert.Equal(t, "ref-1", transaction.Reference)
+	assert.Equal(t, money.FromMajor(500), transaction.Amount)
+	assert.Equal(t, StatusCompleted, transaction.Status)
+	assert.NotNil(t, transaction.PaidAt)
+
+	_, err = fake.VerifyWebhook(http.Header{}, body)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, _, err = fake.Simulate("missing", StatusCompleted)
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+}
@@ -0,0 +1,228 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Currency string
+
+const (
+	BDT Currency = "BDT"
+
+	DefaultCurrency = BDT
+)
+
+// MinorDigits is the number of decimal places of the minor unit (poisha for BDT)
+const MinorDigits = 2
+
+const minorPerMajor = 100
+
+var ErrInvalidAmount = errors.New("invalid money amount")
+
+// Amount is an exact monetary amount held in minor units, e.g. 12345 is 123.45 BDT.
+// It is stored as a bigint and serialized to JSON as a decimal number with two places.
+type Amount int64
+
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// FromMajor converts whole currency units, e.g. FromMajor(500) is 500.00
+func FromMajor(major int64) Amount {
+	return Amount(major * minorPerMajor)
+}
+
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+func (a Amount) IsZero() bool {
+	return a == 0
+}
+
+func (a Amount) IsNegative() bool {
+	return a < 0
+}
+
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func Max(a, b Amount) Amount {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// MulRatio returns a * numerator / denominator rounded half away from zero, for prorating and percentages
+func (a Amount) MulRatio(numerator, denominator int64) Amount {
+	if denominator == 0 {
+		return 0
+	}
+	product := int64(a) * numerator
+	quotient := product / denominator
+	remainder := product % denominator
+	if remainder != 0 && 2*abs64(remainder) >= abs64(denominator) {
+		if (product < 0) != (denominator < 0) {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return Amount(quotient)
+}
+
+// Percent returns the given percentage of the amount, expressed in basis points (1250 = 12.5%)
+func (a Amount) Percent(basisPoints int64) Amount {
+	return a.MulRatio(basisPoints, 10000)
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// String formats the amount as a plain decimal, e.g. "-1234.50"
+func (a Amount) String() string {
+	sign := ""
+	minor := int64(a)
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorPerMajor, minor%minorPerMajor)
+}
+
+// Parse reads a decimal amount such as "1234", "1234.5" or "-12.05" without going through floating point
+func Parse(value string) (Amount, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch value[0] {
+	case '-':
+		negative = true
+		value = value[1:]
+	case '+':
+		value = value[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" && fraction == "" {
+		return 0, ErrInvalidAmount
+	}
+	if len(fraction) > MinorDigits {
+		return 0, fmt.Errorf("%w: at most %d decimal places are allowed", ErrInvalidAmount, MinorDigits)
+	}
+	for _, part := range []string{whole, fraction} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, ErrInvalidAmount
+			}
+		}
+	}
+
+	var major int64
+	if whole != "" {
+		var err error
+		major, err = strconv.ParseInt(whole, 10, 64)
+		if err != nil || major > math.MaxInt64/minorPerMajor {
+			return 0, ErrInvalidAmount
+		}
+	}
+
+	fraction += strings.Repeat("0", MinorDigits-len(fraction))
+	minor, _ := strconv.ParseInt(fraction, 10, 64)
+
+	total := major*minorPerMajor + minor
+	if negative {
+		total = -total
+	}
+	return Amount(total), nil
+}
+
+func MustParse(value string) Amount {
+	amount, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and strings, e.g. 500, 500.5 or "500.50"
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+
+	parsed, err := Parse(value)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, string(data))
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+	case int64:
+		*a = Amount(v)
+	case []byte:
+		minor, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot scan %q into money.Amount: %w", string(v), err)
+		}
+		*a = Amount(minor)
+	case string:
+		minor, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot scan %q into money.Amount: %w", v, err)
+		}
+		*a = Amount(minor)
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+	return nil
+}
+
+func (Amount) GormDataType() string {
+	return "bigint"
+}
+
+func (c Currency) IsValid() bool {
+	return c == BDT
+}
@@ -0,0 +1,70 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Amount
+		valid    bool
+	}{
+		{"0", 0, true},
+		{"500", 50000, true},
+		{"500.5", 50050, true},
+		{"500.05", 50005, true},
+		{"-12.30", -1230, true},
+		{".75", 75, true},
+		{"0.1", 10, true},
+		{"0.005", 0, false},
+		{"1e3", 0, false},
+		{"", 0, false},
+		{"abc", 0, false},
+		{"-", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			amount, err := Parse(tc.input)
+			if !tc.valid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, amount)
+		})
+	}
+}
+
+func TestStringAndJSON(t *testing.T) {
+	assert.Equal(t, "1234.50", FromMinor(123450).String())
+	assert.Equal(t, "-0.05", FromMinor(-5).String())
+
+	data, err := json.Marshal(struct {
+		Price Amount `json:"price"`
+	}{Price: FromMinor(99999)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"price": 999.99}`, string(data))
+
+	var decoded struct {
+		Number Amount `json:"number"`
+		Text   Amount `json:"text"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"number": 0.1, "text": "0.2"}`), &decoded))
+	assert.Equal(t, FromMinor(10), decoded.Number)
+	assert.Equal(t, FromMinor(20), decoded.Text)
+	assert.Equal(t, FromMinor(30), decoded.Number+decoded.Text)
+}
+
+func TestMulRatio(t *testing.T) {
+	assert.Equal(t, FromMinor(33333), FromMinor(100000).MulRatio(1, 3))
+	assert.Equal(t, FromMinor(66667), FromMinor(100000).MulRatio(2, 3))
+	assert.Equal(t, FromMinor(-66667), FromMinor(-100000).MulRatio(2, 3))
+	assert.Equal(t, FromMinor(125), FromMinor(1000).Percent(1250))
+	assert.Equal(t, Amount(0), FromMinor(1000).MulRatio(1, 0))
+}
@@ -0,0 +1,259 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on what an XLSX workbook may expand to, since a small compressed upload can unpack to
+// far more. Workbooks beyond them are rejected as invalid.
+const (
+	// MaxRows is the highest row number read, header included
+	MaxRows = 10000
+	// MaxColumns is the number of columns read, A to BL
+	MaxColumns = 64
+	// maxPartSize bounds the uncompressed size of each XML part
+	maxPartSize = 32 << 20
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported spreadsheet format, expected .csv or .xlsx")
+	ErrInvalidFile       = errors.New("invalid spreadsheet file")
+)
+
+// Read returns the rows of a CSV file, or of the first sheet of an XLSX workbook, as text.
+// The format is chosen by the file name's extension.
+func Read(name string, data []byte) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return ReadCSV(bytes.NewReader(data))
+	case ".xlsx":
+		return ReadXLSX(data)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+func ReadCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+	}
+	return rows, nil
+}
+
+// ReadXLSX reads the first sheet of a workbook. Cells are returned as stored: numbers, including
+// dates, come back as their raw value; see SerialDate.
+func ReadXLSX(data []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sharedStrings, err := readSharedStrings(files["xl/sharedStrings.xml"])
+	if err != nil {
+		return nil, err
+	}
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Index int `xml:"r,attr"`
+			Cells []struct {
+				Ref       string  `xml:"r,attr"`
+				Type      string  `xml:"t,attr"`
+				Value     string  `xml:"v"`
+				InlineStr xmlText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeXML(files[sheetPath], &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, xmlRow := range sheet.Rows {
+		index := xmlRow.Index
+		if index <= 0 {
+			index = len(rows) + 1
+		}
+		if index > MaxRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidFile, MaxRows)
+		}
+		for len(rows) < index {
+			rows = append(rows, nil)
+		}
+
+		var row []string
+		for i, cell := range xmlRow.Cells {
+			column := i
+			if cell.Ref != "" {
+				if column, err = columnIndex(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			if column >= MaxColumns {
+				return nil, fmt.Errorf("%w: more than %d columns", ErrInvalidFile, MaxColumns)
+			}
+			for len(row) <= column {
+				row = append(row, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(sharedStrings) {
+					return nil, fmt.Errorf("%w: bad shared string in cell %s", ErrInvalidFile, cell.Ref)
+				}
+				row[column] = sharedStrings[n]
+			case "inlineStr":
+				row[column] = cell.InlineStr.String()
+			default:
+				row[column] = cell.Value
+			}
+		}
+		rows[index-1] = row
+	}
+	return rows, nil
+}
+
+// SerialDate converts a spreadsheet date serial, the number of days since 30 December 1899, to a date
+func SerialDate(value string) (time.Time, bool) {
+	days, err := strconv.ParseFloat(value, 64)
+	if err != nil || days < 1 {
+		return time.Time{}, false
+	}
+	return time.Date(1899, time.December, 30, 0, 0, 0, 0, time.Local).AddDate(0, 0, int(days)), true
+}
+
+// xmlText is rich or plain text, made of runs or a single t element
+type xmlText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xmlText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+func readSharedStrings(file *zip.File) ([]string, error) {
+	if file == nil {
+		return nil, nil
+	}
+	var table struct {
+		Items []xmlText `xml:"si"`
+	}
+	if err := decodeXML(file, &table); err != nil {
+		return nil, err
+	}
+	values := make([]string, len(table.Items))
+	for i, item := range table.Items {
+		values[i] = item.String()
+	}
+	return values, nil
+}
+
+// firstSheetPath finds the part holding the workbook's first sheet through the workbook relationships
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			RelationID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeXML(files["xl/workbook.xml"], &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("%w: the workbook has no sheets", ErrInvalidFile)
+	}
+
+	var relationships struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeXML(files["xl/_rels/workbook.xml.rels"], &relationships); err != nil {
+		return "", err
+	}
+	for _, rel := range relationships.Items {
+		if rel.ID != workbook.Sheets[0].RelationID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", fmt.Errorf("%w: the first sheet is missing", ErrInvalidFile)
+}
+
+func decodeXML(file *zip.File, v interface{}) error {
+	if file == nil {
+		return fmt.Errorf("%w: not an xlsx workbook", ErrInvalidFile)
+	}
+	if file.UncompressedSize64 > maxPartSize {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidFile, file.Name, maxPartSize)
+	}
+	r, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	defer r.Close()
+	// The recorded size may understate the part, so the reader is capped too; a truncated part
+	// fails to decode
+	if err := xml.NewDecoder(io.LimitReader(r, maxPartSize)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidFile, file.Name, err)
+	}
+	return nil
+}
+
+// columnIndex turns the letters of a cell reference such as "AB12" into a zero-based column
+func columnIndex(ref string) (int, error) {
+	column := 0
+	for i, r := range ref {
+		if r >= 'A' && r <= 'Z' {
+			column = column*26 + int(r-'A'+1)
+			if column > MaxColumns {
+				return 0, fmt.Errorf("%w: cell %s is beyond the first %d columns", ErrInvalidFile, ref, MaxColumns)
+			}
+			continue
+		}
+		if i == 0 {
+			break
+		}
+		return column - 1, nil
+	}
+	return 0, fmt.Errorf("%w: bad cell reference %q", ErrInvalidFile, ref)
+}
@@ -0,0 +1,88 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCSV(t *testing.T) {
+	rows, err := Read("devices.CSV", []byte("\ufefftype,serial\nONU, ZTE-001\n"))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"type", "serial"}, {"ONU", "ZTE-001"}}, rows)
+
+	_, err = Read("devices.xls", nil)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestReadXLSX(t *testing.T) {
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+			xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Devices" sheetId="1" r:id="rId3"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Target="styles.xml"/>
+			<Relationship Id="rId3" Target="worksheets/devices.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>type</t></si><si><t>serial</t></si><si><r><t>ZTE-</t></r><r><t>001</t></r></si></sst>`,
+		"xl/worksheets/devices.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>date</t></is></c></row>
+			<row r="3"><c r="A3" t="inlineStr"><is><t>ONU</t></is></c><c r="B3" t="s"><v>2</v></c><c r="D3"><v>46312</v></c></row>
+		</sheetData></worksheet>`,
+	}
+
+	rows, err := Read("devices.xlsx", buildXLSX(t, parts))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"type", "serial", "date"},
+		nil,
+		{"ONU", "ZTE-001", "", "46312"},
+	}, rows)
+
+	date, ok := SerialDate(rows[2][3])
+	require.True(t, ok)
+	assert.Equal(t, "2026-10-17", date.Format(time.DateOnly))
+
+	_, err = Read("devices.xlsx", []byte("not a zip"))
+	assert.ErrorIs(t, err, ErrInvalidFile)
+
+	for _, sheet := range []string{
+		`<worksheet><sheetData><row r="1048576"><c r="A1048576"><v>1</v></c></row></sheetData></worksheet>`,
+		`<worksheet><sheetData><row r="1"><c r="XFD1"><v>1</v></c></row></sheetData></worksheet>`,
+	} {
+		parts["xl/worksheets/devices.xml"] = sheet
+		_, err = Read("devices.xlsx", buildXLSX(t, parts))
+		assert.ErrorIs(t, err, ErrInvalidFile, "rows and columns far out are rejected")
+	}
+}
+
+func buildXLSX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	return buf.Bytes()
+}
+
+func TestColumnIndex(t *testing.T) {
+	for ref, expected := range map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "AB2": 27} {
+		column, err := columnIndex(ref)
+		require.NoError(t, err)
+		assert.Equal(t, expected, column, ref)
+	}
+	_, err := columnIndex("12")
+	assert.Error(t, err)
+	_, err = columnIndex("XFD1")
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	_, err = columnIndex("AAAAAAAAAAAAAAAAAAAAAAAAAAAA1")
+	assert.ErrorIs(t, err, ErrInvalidFile)
+}